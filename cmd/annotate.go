@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/metadata"
+)
+
+var (
+	annotateFormat      string
+	annotateTitle       string
+	annotateAuthor      string
+	annotateLanguage    string
+	annotateCategory    string
+	annotateDescription string
+	annotateTags        string
+	annotateSet         []string
+)
+
+// annotateCmd represents the annotate command
+var annotateCmd = &cobra.Command{
+	Use:   "annotate <file>",
+	Short: "Write document metadata",
+	Long: `Set title, author, language, tags and custom fields on a document.
+
+Metadata is merged with what the document already contains. It is embedded
+in place when metadata.embed is enabled and the format supports it:
+  - EPUB (OPF package metadata)
+  - FB2 (<title-info>, <custom-info>)
+  - DOCX (docProps/core.xml)
+  - PDF (Info dictionary, appended as an incremental update)
+
+Otherwise, or when some fields cannot be embedded, a <file>.json sidecar
+with the full metadata record is written (metadata.sidecar). The checksum
+uses metadata.checksum (sha256, md5, or empty to disable).
+
+Examples:
+  yakateka annotate book.epub --title "Kobzar" --author "Taras Shevchenko" --language uk
+  yakateka annotate paper.pdf --tags "physics,optics" --set doi=10.1000/182
+  yakateka annotate notes.txt --title "Notes"   # sidecar only`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAnnotate,
+}
+
+func init() {
+	rootCmd.AddCommand(annotateCmd)
+
+	annotateCmd.Flags().StringVarP(&annotateFormat, "from", "f", "",
+		"document format (auto-detected from content and extension if not specified)")
+	annotateCmd.Flags().StringVar(&annotateTitle, "title", "", "document title")
+	annotateCmd.Flags().StringVar(&annotateAuthor, "author", "", "author(s), comma-separated")
+	annotateCmd.Flags().StringVar(&annotateLanguage, "language", "", "language code (e.g. en, uk)")
+	annotateCmd.Flags().StringVar(&annotateCategory, "category", "", "document category or genre")
+	annotateCmd.Flags().StringVar(&annotateDescription, "description", "", "document description")
+	annotateCmd.Flags().StringVar(&annotateTags, "tags", "", "comma-separated tags (replace existing tags)")
+	annotateCmd.Flags().StringArrayVar(&annotateSet, "set", nil, "custom field as key=value (repeatable)")
+}
+
+func runAnnotate(cmd *cobra.Command, args []string) error {
+	input := args[0]
+
+	if _, err := os.Stat(input); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", input)
+	}
+
+	updates, err := annotateUpdates()
+	if err != nil {
+		return err
+	}
+
+	format := internal.DocumentFormat(strings.ToLower(annotateFormat))
+	if format == "" {
+		format, err = detectInputFormat(input)
+		if err != nil {
+			return err
+		}
+	}
+
+	annotator := metadata.NewAnnotator(metadataConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout(cmd))
+	defer cancel()
+
+	result, err := annotator.Annotate(ctx, input, format, updates)
+	if err != nil {
+		log.Error().Err(err).Str("input", input).Msg("Failed to annotate document")
+		return fmt.Errorf("failed to annotate: %w", err)
+	}
+
+	return printOutput(result, func(w io.Writer) {
+		writeMetadataText(w, result.Metadata)
+		if result.Embedded {
+			fmt.Fprintf(w, "\n✓ Embedded in %s\n", input)
+		}
+		if len(result.Skipped) > 0 {
+			fmt.Fprintf(w, "  Not embeddable: %s\n", strings.Join(result.Skipped, ", "))
+		}
+		if result.Sidecar != "" {
+			fmt.Fprintf(w, "✓ Sidecar written: %s\n", result.Sidecar)
+		}
+	})
+}
+
+// annotateUpdates builds the metadata update from command flags
+func annotateUpdates() (*internal.DocumentMetadata, error) {
+	updates := &internal.DocumentMetadata{
+		Title:       strings.TrimSpace(annotateTitle),
+		Author:      strings.TrimSpace(annotateAuthor),
+		Language:    strings.TrimSpace(annotateLanguage),
+		Category:    strings.TrimSpace(annotateCategory),
+		Description: strings.TrimSpace(annotateDescription),
+	}
+
+	for _, tag := range strings.Split(annotateTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			updates.Tags = append(updates.Tags, tag)
+		}
+	}
+
+	for _, kv := range annotateSet {
+		key, value, ok := strings.Cut(kv, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set value %q (expected key=value)", kv)
+		}
+		if updates.Custom == nil {
+			updates.Custom = make(map[string]string)
+		}
+		updates.Custom[key] = strings.TrimSpace(value)
+	}
+
+	return updates, nil
+}
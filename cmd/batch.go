@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/converter"
+	"github.com/valpere/yakateka/internal/scheduler"
+)
+
+// batchJob is a single file conversion within a batch
+type batchJob struct {
+	Input  string // Source file path
+	Output string // Destination file path
+	Rel    string // Source path relative to the batch root (for display)
+	Opts   internal.ConversionOptions
+}
+
+// batchResult is the outcome of one batch job
+type batchResult struct {
+	Job      batchJob
+	Size     int64
+	Duration time.Duration
+	Report   *converter.ConversionReport
+	Err      error
+	Skipped  string // Why the file was not converted; not a failure
+}
+
+// runBatchConvert converts every file matched by --batch into --out-dir
+func runBatchConvert(cmd *cobra.Command, args []string) error {
+	if outDir == "" {
+		return fmt.Errorf("--batch requires --out-dir")
+	}
+	if outputFormat == "" {
+		return fmt.Errorf("--batch requires --to")
+	}
+	if strings.Contains(outputFormat, ",") {
+		return fmt.Errorf("--batch takes a single --to format")
+	}
+	to := internal.DocumentFormat(strings.ToLower(outputFormat))
+
+	root, inputs, err := collectBatchInputs(batchInput, outDir)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no input files found for %s", batchInput)
+	}
+
+	log.Info().
+		Str("batch", batchInput).
+		Str("out_dir", outDir).
+		Str("to", string(to)).
+		Int("files", len(inputs)).
+		Msg("Starting batch conversion")
+
+	// Factory and helper cache are built once for the whole batch
+	factory, err := newConverterFactory()
+	if err != nil {
+		return err
+	}
+	defer factory.Close() // Stops helper servers
+
+	// Plan jobs up front so files that cannot be converted are reported before any work starts
+	batchJobs, results := planBatchJobs(factory, root, inputs, to)
+	for _, result := range results {
+		printBatchResult(result)
+	}
+	if err := disambiguateOutputs(batchJobs); err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels the shared context: running conversions are killed and no new ones start
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool := scheduler.NewPool(jobs)
+	log.Info().Int("jobs", pool.Jobs()).Msg("Running batch conversions")
+
+	perFile := conversionTimeout(cmd)
+	jobResults := make([]batchResult, len(batchJobs))
+	var printMu sync.Mutex
+
+	startTime := time.Now()
+	started := pool.Run(ctx, len(batchJobs), func(ctx context.Context, i int) {
+		result := runBatchJob(ctx, factory, batchJobs[i], perFile)
+		jobResults[i] = result
+
+		printMu.Lock()
+		printBatchResult(result)
+		printMu.Unlock()
+	})
+
+	// Jobs that never started because of cancellation count as failures
+	for i := started; i < len(batchJobs); i++ {
+		jobResults[i] = batchResult{Job: batchJobs[i], Err: fmt.Errorf("not started: %w", ctx.Err())}
+	}
+	results = append(results, jobResults...)
+
+	return summarizeBatch(results, time.Since(startTime))
+}
+
+// planBatchJobs works out the output and formats of each input; inputs of an
+// unknown format or without a route to the target are returned as skipped results
+func planBatchJobs(factory *converter.Factory, root string, inputs []string, to internal.DocumentFormat) ([]batchJob, []batchResult) {
+	base := baseConversionOptions()
+	var batchJobs []batchJob
+	var skipped []batchResult
+	for _, input := range inputs {
+		rel, err := filepath.Rel(root, input)
+		if err != nil {
+			rel = filepath.Base(input)
+		}
+		output := filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+"."+string(to))
+		job := batchJob{Input: input, Output: output, Rel: rel, Opts: base}
+		job.Opts.OutputFormat = to
+
+		from := internal.DocumentFormat(strings.ToLower(inputFormat))
+		if from == "" {
+			from, err = detectInputFormat(input)
+			if err != nil {
+				skipped = append(skipped, batchResult{Job: job, Skipped: "unknown format"})
+				continue
+			}
+		}
+		job.Opts.InputFormat = from
+
+		if plan := factory.Plan(job.Opts); plan.Route == nil {
+			skipped = append(skipped, batchResult{Job: job, Skipped: fmt.Sprintf("no route from %s to %s", from, to)})
+			continue
+		}
+		batchJobs = append(batchJobs, job)
+	}
+	return batchJobs, skipped
+}
+
+// disambiguateOutputs gives jobs whose outputs collide (a.pdf and a.epub both
+// becoming a.txt) outputs named after the whole source name (a.pdf.txt, a.epub.txt)
+// It fails if outputs still collide
+func disambiguateOutputs(batchJobs []batchJob) error {
+	byOutput := make(map[string][]int)
+	for i, job := range batchJobs {
+		byOutput[job.Output] = append(byOutput[job.Output], i)
+	}
+	for _, indexes := range byOutput {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, i := range indexes {
+			job := &batchJobs[i]
+			job.Output = filepath.Join(filepath.Dir(job.Output), filepath.Base(job.Input)+"."+string(job.Opts.OutputFormat))
+			log.Warn().
+				Str("input", job.Rel).
+				Str("output", job.Output).
+				Msg("Inputs share an output name, keeping the source extension in it")
+		}
+	}
+
+	seen := make(map[string]string)
+	for _, job := range batchJobs {
+		if other, ok := seen[job.Output]; ok {
+			return fmt.Errorf("%s and %s would both be converted to %s", other, job.Rel, job.Output)
+		}
+		seen[job.Output] = job.Rel
+	}
+	return nil
+}
+
+// runBatchJob converts one file with its own timeout, which does not run while
+// the file waits for a slot of a capped converter or helper
+func runBatchJob(ctx context.Context, factory *converter.Factory, job batchJob, perFile time.Duration) batchResult {
+	result := batchResult{Job: job}
+
+	if err := os.MkdirAll(filepath.Dir(job.Output), 0755); err != nil {
+		result.Err = fmt.Errorf("failed to create output directory: %w", err)
+		return result
+	}
+
+	ctx, cancel := scheduler.WithBudget(ctx, perFile)
+	defer cancel()
+
+	startTime := time.Now()
+	result.Report, result.Err = factory.ConvertWithReport(ctx, job.Input, job.Output, job.Opts)
+	result.Duration = time.Since(startTime)
+
+	if result.Err != nil {
+		log.Error().
+			Err(result.Err).
+			Str("input", job.Input).
+			Str("output", job.Output).
+			Dur("duration", result.Duration).
+			Msg("Batch conversion failed for file")
+		return result
+	}
+
+	if stat, err := os.Stat(job.Output); err == nil {
+		result.Size = stat.Size()
+	}
+	return result
+}
+
+// printBatchResult prints a one-line summary for a finished file
+func printBatchResult(r batchResult) {
+	if r.Skipped != "" {
+		fmt.Printf("- %s: skipped, %s\n", r.Job.Rel, r.Skipped)
+		return
+	}
+	if r.Err != nil {
+		fmt.Printf("✗ %s: %v\n", r.Job.Rel, r.Err)
+		return
+	}
+	fmt.Printf("✓ %s → %s (%d bytes) in %v\n",
+		r.Job.Rel, r.Job.Output, r.Size, r.Duration.Round(time.Millisecond))
+	printConversionReport(os.Stdout, r.Report, "  ")
+}
+
+// summarizeBatch prints totals and returns an error if any file failed
+func summarizeBatch(results []batchResult, elapsed time.Duration) error {
+	succeeded, failed, skipped, existing := 0, 0, 0, 0
+	var totalBytes int64
+	for _, r := range results {
+		if r.Skipped != "" {
+			skipped++
+			continue
+		}
+		if r.Err != nil {
+			failed++
+			if errors.Is(r.Err, internal.ErrOutputExists) {
+				existing++
+			}
+			continue
+		}
+		succeeded++
+		totalBytes += r.Size
+	}
+
+	fmt.Println()
+	fmt.Printf("Batch complete: %d files, %d succeeded, %d failed, %d skipped (%d bytes) in %v\n",
+		len(results), succeeded, failed, skipped, totalBytes, elapsed.Round(time.Millisecond))
+	printExistingHint(existing)
+
+	log.Info().
+		Int("files", len(results)).
+		Int("succeeded", succeeded).
+		Int("failed", failed).
+		Int("skipped", skipped).
+		Int64("bytes", totalBytes).
+		Dur("duration", elapsed).
+		Msg("Batch conversion completed")
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d conversions failed", failed, len(results))
+	}
+	return nil
+}
+
+// collectBatchInputs expands a directory (recursively) or glob pattern into input files
+// Returns the root used to compute relative output paths
+func collectBatchInputs(pattern, outputDir string) (string, []string, error) {
+	absOut, _ := filepath.Abs(outputDir)
+
+	info, err := os.Stat(pattern)
+	if err == nil && info.IsDir() {
+		var files []string
+		walkErr := filepath.WalkDir(pattern, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Never descend into the output directory when it lives inside the input tree
+				if absPath, _ := filepath.Abs(path); absPath == absOut {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return "", nil, fmt.Errorf("failed to walk %s: %w", pattern, walkErr)
+		}
+		return pattern, files, nil
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid batch pattern %s: %w", pattern, err)
+	}
+
+	var files []string
+	for _, m := range matches {
+		if stat, err := os.Stat(m); err == nil && stat.Mode().IsRegular() {
+			files = append(files, m)
+		}
+	}
+	return globRoot(pattern), files, nil
+}
+
+// globRoot returns the longest leading directory of a pattern without glob metacharacters
+func globRoot(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for dir != "." && dir != string(filepath.Separator) && strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
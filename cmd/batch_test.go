@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/converter"
+	"github.com/valpere/yakateka/internal/scheduler"
+)
+
+// toTextConverter converts markdown and HTML to text by copying
+type toTextConverter struct{}
+
+func (toTextConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, data, 0644)
+}
+
+func (toTextConverter) SupportedInputFormats() []internal.DocumentFormat {
+	return []internal.DocumentFormat{internal.FormatMD, internal.FormatHTML}
+}
+
+func (toTextConverter) SupportedOutputFormats() []internal.DocumentFormat {
+	return []internal.DocumentFormat{internal.FormatTXT}
+}
+
+func TestCollectBatchInputsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"a.pdf", "sub/b.epub", "sub/deeper/c.djvu"}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Output directory inside the input tree must be skipped
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "old.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, inputs, err := collectBatchInputs(dir, out)
+	if err != nil {
+		t.Fatalf("collectBatchInputs failed: %v", err)
+	}
+	if root != dir {
+		t.Errorf("Expected root %s, got %s", dir, root)
+	}
+
+	var rels []string
+	for _, in := range inputs {
+		rel, _ := filepath.Rel(root, in)
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	sort.Strings(rels)
+	want := []string{"a.pdf", "sub/b.epub", "sub/deeper/c.djvu"}
+	if len(rels) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, rels)
+	}
+	for i := range want {
+		if rels[i] != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], rels[i])
+		}
+	}
+}
+
+func TestCollectBatchInputsGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.pdf", "b.pdf", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, inputs, err := collectBatchInputs(filepath.Join(dir, "*.pdf"), filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatalf("collectBatchInputs failed: %v", err)
+	}
+	if root != dir {
+		t.Errorf("Expected root %s, got %s", dir, root)
+	}
+	if len(inputs) != 2 {
+		t.Errorf("Expected 2 inputs, got %d: %v", len(inputs), inputs)
+	}
+}
+
+func TestGlobRoot(t *testing.T) {
+	tests := map[string]string{
+		"books/*.pdf":         "books",
+		"books/*/scans/*.pdf": "books",
+		"*.pdf":               ".",
+	}
+	for pattern, want := range tests {
+		if got := globRoot(filepath.FromSlash(pattern)); got != filepath.FromSlash(want) {
+			t.Errorf("globRoot(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestPlanBatchJobs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":      "# A\n",
+		"a.html":    "<html><body>A</body></html>",
+		"b.md":      "# B\n",
+		".DS_Store": "\x00\x00\x00\x01Bud1\x00",
+		"blob":      "\x00\x01\x02\x03",
+		"cover.jpg": "\xff\xd8\xff\xe0JFIF",
+	}
+	var inputs []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, path)
+	}
+	sort.Strings(inputs)
+
+	factory := converter.NewFactory()
+	factory.Register("totext", toTextConverter{})
+	outDir = filepath.Join(dir, "out")
+	defer func() { outDir = "" }()
+
+	batchJobs, skipped := planBatchJobs(factory, dir, inputs, internal.FormatTXT)
+
+	// Files of unknown formats or without a route are skipped, not failed
+	reasons := make(map[string]string)
+	for _, r := range skipped {
+		if r.Err != nil {
+			t.Errorf("Expected %s to be skipped, got error %v", r.Job.Rel, r.Err)
+		}
+		reasons[r.Job.Rel] = r.Skipped
+	}
+	if reasons["blob"] != "unknown format" || !strings.Contains(reasons["cover.jpg"], "no route from jpg") ||
+		!strings.Contains(reasons[".DS_Store"], "no route") || len(reasons) != 3 {
+		t.Errorf("Expected blob, .DS_Store and cover.jpg to be skipped, got %v", reasons)
+	}
+
+	// Inputs sharing an output name keep their source extension in it
+	if err := disambiguateOutputs(batchJobs); err != nil {
+		t.Fatalf("disambiguateOutputs failed: %v", err)
+	}
+	outputs := make(map[string]string)
+	for _, job := range batchJobs {
+		outputs[job.Rel] = filepath.Base(job.Output)
+	}
+	want := map[string]string{"a.md": "a.md.txt", "a.html": "a.html.txt", "b.md": "b.txt"}
+	for rel, name := range want {
+		if outputs[rel] != name {
+			t.Errorf("Expected %s to be converted to %s, got %v", rel, name, outputs)
+		}
+	}
+}
+
+func TestDisambiguateOutputsFails(t *testing.T) {
+	// After disambiguation a.md becomes a.md.txt, the output a.md.txt already has
+	batchJobs := []batchJob{
+		{Input: "in/a.md", Output: "out/a.txt", Rel: "a.md"},
+		{Input: "in/a.html", Output: "out/a.txt", Rel: "a.html"},
+		{Input: "in/a.md.txt", Output: "out/a.md.txt", Rel: "a.md.txt"},
+	}
+	for i := range batchJobs {
+		batchJobs[i].Opts.OutputFormat = internal.FormatTXT
+	}
+	if err := disambiguateOutputs(batchJobs); err == nil || !strings.Contains(err.Error(), "would both be converted to") {
+		t.Errorf("Expected a collision error, got %v", err)
+	}
+}
+
+// slowTextConverter converts markdown to text, taking delay unless cancelled first
+type slowTextConverter struct {
+	toTextConverter
+	delay time.Duration
+}
+
+func (c slowTextConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.toTextConverter.Convert(ctx, input, output, opts)
+}
+
+func TestRunBatchJobTimeoutExcludesSlotWait(t *testing.T) {
+	dir := t.TempDir()
+	factory := converter.NewFactory()
+	factory.Register("slow", slowTextConverter{delay: 40 * time.Millisecond})
+	factory.SetConcurrencyLimit("slow", 1)
+
+	// Four files through one slot take 160ms, each well within its 100ms timeout
+	var batchJobs []batchJob
+	for _, name := range []string{"a", "b", "c", "d"} {
+		input := filepath.Join(dir, name+".md")
+		if err := os.WriteFile(input, []byte("# "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := internal.ConversionOptions{InputFormat: internal.FormatMD, OutputFormat: internal.FormatTXT}
+		batchJobs = append(batchJobs, batchJob{Input: input, Output: filepath.Join(dir, "out", name+".txt"), Rel: name + ".md", Opts: opts})
+	}
+
+	results := make([]batchResult, len(batchJobs))
+	scheduler.NewPool(len(batchJobs)).Run(context.Background(), len(batchJobs), func(ctx context.Context, i int) {
+		results[i] = runBatchJob(ctx, factory, batchJobs[i], 100*time.Millisecond)
+	})
+
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("Expected %s to convert, got %v", r.Job.Rel, r.Err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/valpere/yakateka/internal/cache"
+)
+
+var (
+	pruneMaxSize   int64
+	pruneOlderThan time.Duration
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clean the conversion cache",
+	Long: `Manage the conversion output cache.
+
+Converted files are cached (in cache.dir, default ~/.yakateka/cache) keyed by
+the SHA-256 of the source, the formats, the --quality mode, the converters or
+helpers on the route (with their versions) and the options that affect the
+output. Converting the same file the same way again reuses the cached output.
+Least recently used entries are evicted beyond cache.max_size_mb.
+
+Use convert --no-cache to bypass the cache for one run, or set
+cache.enabled: false to disable it.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache size and usage",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheStats,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Evict least recently used entries",
+	Long: `Evict cache entries unused for longer than --older-than, then the least
+recently used ones until the cache fits in --max-size (default cache.max_size_mb).
+
+Examples:
+  yakateka cache prune
+  yakateka cache prune --max-size 500
+  yakateka cache prune --older-than 720h`,
+	Args: cobra.NoArgs,
+	RunE: runCachePrune,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached output",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheClear,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd, cachePruneCmd, cacheClearCmd)
+
+	cachePruneCmd.Flags().Int64Var(&pruneMaxSize, "max-size", 0,
+		"size limit in MB (default cache.max_size_mb)")
+	cachePruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 0,
+		"also evict entries unused for this long (e.g. 720h)")
+}
+
+// outputCache opens the conversion cache configured under cache.*
+func outputCache() (*cache.Cache, error) {
+	dir := os.ExpandEnv(viper.GetString("cache.dir"))
+	if dir == "" || dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+		}
+		if dir == "" {
+			dir = filepath.Join(home, ".yakateka", "cache")
+		} else {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	return cache.New(dir, viper.GetInt64("cache.max_size_mb")*megabyte), nil
+}
+
+const megabyte = 1024 * 1024
+
+func runCacheStats(cmd *cobra.Command, args []string) error {
+	c, err := outputCache()
+	if err != nil {
+		return err
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	return printOutput(stats, func(w io.Writer) {
+		fmt.Fprintf(w, "Cache: %s\n", stats.Dir)
+		fmt.Fprintf(w, "  Entries: %d\n", stats.Entries)
+		if stats.MaxSize > 0 {
+			fmt.Fprintf(w, "  Size:    %s of %s\n", formatSize(stats.Size), formatSize(stats.MaxSize))
+		} else {
+			fmt.Fprintf(w, "  Size:    %s (unlimited)\n", formatSize(stats.Size))
+		}
+		fmt.Fprintf(w, "  Hits:    %d\n", stats.Hits)
+		if stats.Entries > 0 {
+			fmt.Fprintf(w, "  Used:    %s … %s\n",
+				stats.Oldest.Format(time.DateTime), stats.Newest.Format(time.DateTime))
+		}
+	})
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	c, err := outputCache()
+	if err != nil {
+		return err
+	}
+
+	maxSize := viper.GetInt64("cache.max_size_mb") * megabyte
+	if cmd.Flags().Changed("max-size") {
+		maxSize = pruneMaxSize * megabyte
+	}
+	if maxSize <= 0 && pruneOlderThan <= 0 {
+		return fmt.Errorf("nothing to prune by: set --max-size, --older-than or cache.max_size_mb")
+	}
+
+	result, err := c.Prune(maxSize, pruneOlderThan)
+	if err != nil {
+		return fmt.Errorf("failed to prune cache: %w", err)
+	}
+	return printPruneResult(result)
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	c, err := outputCache()
+	if err != nil {
+		return err
+	}
+	result, err := c.Clear()
+	if err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return printPruneResult(result)
+}
+
+// printPruneResult reports what prune or clear removed
+func printPruneResult(result *cache.PruneResult) error {
+	return printOutput(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Removed %d entries (%s), %d left (%s)\n",
+			result.Removed, formatSize(result.Freed), result.Remaining, formatSize(result.Size))
+	})
+}
+
+// formatSize renders a byte count as B, KB, MB or GB
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1024*megabyte:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1024*megabyte))
+	case bytes >= megabyte:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/megabyte)
+	case bytes >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1024)
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -15,7 +18,10 @@ import (
 	"github.com/valpere/yakateka/internal/converter"
 	"github.com/valpere/yakateka/internal/converter/config"
 	"github.com/valpere/yakateka/internal/converter/plaintext"
+	"github.com/valpere/yakateka/internal/detect"
 	"github.com/valpere/yakateka/internal/helper"
+	"github.com/valpere/yakateka/internal/metadata"
+	"github.com/valpere/yakateka/internal/ocr"
 )
 
 var (
@@ -23,13 +29,27 @@ var (
 	outputFormat string
 	quality      string
 	dpi          int
+	pages        string
 	via          string
+	noCache      bool
+	keepDir      string
+	force        bool
+	noValidate   bool
 	timeout      int
+	batchInput   string
+	outDir       string
+	jobs         int
+
+	noPreserveMetadata bool
+	dryRun             bool
+	useOCR             bool
+	ocrLanguages       []string
+	offline            bool
 )
 
 // convertCmd represents the convert command
 var convertCmd = &cobra.Command{
-	Use:   "convert <input> <output>",
+	Use:   "convert <input> <output> | <input> --out-dir <dir> --to <formats> | --batch <input-dir|glob> --out-dir <dir> --to <format>",
 	Short: "Convert document between formats",
 	Long: `Convert documents between different formats.
 
@@ -45,9 +65,39 @@ Examples:
   # Explicitly specify formats
   yakateka convert document.pdf output.txt --from pdf --to txt
 
-  # Use specific converter
-  yakateka convert notes.md document.pdf --via pandoc`,
-	Args: cobra.ExactArgs(2),
+  # Replace an existing output (outputs are never overwritten by default)
+  yakateka convert document.pdf document.txt --force
+
+  # Read stdin and/or write stdout with "-" (--from/--to are then required)
+  cat notes.md | yakateka convert - - --from md --to html > notes.html
+  yakateka convert report.docx - --to pdf | lpr
+
+  # Use a specific converter, helper, or explicit pipeline
+  yakateka convert notes.md document.pdf --via pandoc
+  yakateka convert book.epub book.txt --via calibre-helper
+  yakateka convert report.doc report.md --via libreoffice,pandoc
+
+  # Prefer the fastest route over the most faithful one
+  yakateka convert report.docx report.md --quality fast
+
+  # Keep the intermediate files of a multi-step conversion (report.1.html, ...)
+  yakateka convert report.doc report.md --keep-intermediates ./debug
+
+  # Show which converters and pipeline would be used, without converting
+  yakateka convert report.docx report.md --dry-run
+
+  # OCR a scanned document (Tesseract; languages default to ocr.languages)
+  yakateka convert scan.pdf scan.txt --ocr --ocr-lang uk,en
+
+  # Produce several formats at once; shared intermediate steps run once
+  yakateka convert book.md --to epub,mobi,pdf --out-dir ./release
+
+  # Convert a whole directory tree (mirrored into --out-dir)
+  yakateka convert --batch ./library --out-dir ./txt --to txt
+
+  # Convert files matching a glob, four at a time
+  yakateka convert --batch './scans/*.djvu' --out-dir ./pdf --to pdf --jobs 4`,
+	Args: validateConvertArgs,
 	RunE: runConvert,
 }
 
@@ -56,61 +106,233 @@ func init() {
 
 	// Flags
 	convertCmd.Flags().StringVarP(&inputFormat, "from", "f", "",
-		"input format (auto-detected from extension if not specified)")
+		"input format (auto-detected from content and extension if not specified)")
 	convertCmd.Flags().StringVarP(&outputFormat, "to", "t", "",
-		"output format (auto-detected from extension if not specified)")
+		"output format (auto-detected from extension if not specified); several, comma-separated, with --out-dir")
 	convertCmd.Flags().StringVar(&quality, "quality", "",
-		"conversion quality (low, medium, high)")
+		"conversion objective: fast, normal, quality (low, medium, high are accepted)")
 	convertCmd.Flags().IntVar(&dpi, "dpi", 0,
 		"DPI for image conversions (default from config)")
+	convertCmd.Flags().StringVar(&pages, "pages", "",
+		"page range to convert, e.g. 1-5,8 (passed to helpers speaking protocol v2)")
 	convertCmd.Flags().StringVar(&via, "via", "",
-		"specific converter to use (pandoc, libreoffice, etc.)")
+		"converter to use: a converters.yaml name, a helper name or path, or a comma-separated pipeline (libreoffice,pandoc)")
+	convertCmd.Flags().BoolVar(&noCache, "no-cache", false,
+		"convert even if a cached output exists, and do not cache the result")
+	convertCmd.Flags().StringVar(&keepDir, "keep-intermediates", "",
+		"copy intermediate pipeline files into this directory (for debugging)")
+	convertCmd.Flags().BoolVar(&force, "force", false,
+		"overwrite existing output files")
+	convertCmd.Flags().BoolVar(&noValidate, "no-validate", false,
+		"accept outputs without checking that they parse as their format")
 	convertCmd.Flags().IntVar(&timeout, "timeout", 300,
 		"conversion timeout in seconds (default 300 = 5 minutes)")
+	convertCmd.Flags().StringVar(&batchInput, "batch", "",
+		"convert every file in a directory (recursively) or matching a glob")
+	convertCmd.Flags().StringVar(&outDir, "out-dir", "",
+		"output directory for --batch (input directory tree is mirrored) or for several --to formats")
+	convertCmd.Flags().IntVarP(&jobs, "jobs", "j", 1,
+		"number of --batch conversions to run in parallel (0 = number of CPUs)")
+	convertCmd.Flags().BoolVar(&noPreserveMetadata, "no-preserve-metadata", false,
+		"do not carry title, author and other metadata over to the output")
+	convertCmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"show the planned route (see 'yakateka plan') instead of converting")
+	convertCmd.Flags().BoolVar(&useOCR, "ocr", false,
+		"recognise text with OCR (scanned PDF/DjVu, images)")
+	convertCmd.Flags().StringSliceVar(&ocrLanguages, "ocr-lang", nil,
+		"OCR languages, comma-separated (default from ocr.languages)")
+	convertCmd.Flags().BoolVar(&offline, "offline", false,
+		"use the helper cache as it is, only warning about helpers that changed since it was generated")
 }
 
 func runConvert(cmd *cobra.Command, args []string) error {
+	if batchInput != "" {
+		if dryRun {
+			return fmt.Errorf("--dry-run is not supported with --batch")
+		}
+		return runBatchConvert(cmd, args)
+	}
+	if isMultiTarget() {
+		if dryRun {
+			return fmt.Errorf("--dry-run is not supported with --out-dir, run it for one --to format at a time")
+		}
+		return runMultiTargetConvert(cmd, args)
+	}
+
 	input := args[0]
 	output := args[1]
 
+	// "-" reads stdin / writes stdout, which carry no name to detect formats from
+	if input == converter.Stdio && inputFormat == "" {
+		return fmt.Errorf("reading stdin (-) requires --from")
+	}
+	if output == converter.Stdio && outputFormat == "" {
+		return fmt.Errorf("writing stdout (-) requires --to")
+	}
+
 	// Validate input file exists
-	if _, err := os.Stat(input); os.IsNotExist(err) {
+	if _, err := os.Stat(input); input != converter.Stdio && os.IsNotExist(err) {
 		return fmt.Errorf("input file does not exist: %s", input)
 	}
 
-	// Auto-detect formats from extensions if not specified
-	if inputFormat == "" {
-		inputFormat = strings.TrimPrefix(filepath.Ext(input), ".")
-		if inputFormat == "" {
-			return fmt.Errorf("cannot detect input format, please specify with --from")
+	// Auto-detect input format from content (falling back to extension) if not specified
+	from := inputFormat
+	if from == "" {
+		detected, err := detectInputFormat(input)
+		if err != nil {
+			return err
 		}
+		from = string(detected)
 	}
 
-	if outputFormat == "" {
-		outputFormat = strings.TrimPrefix(filepath.Ext(output), ".")
-		if outputFormat == "" {
+	// Output format can only come from the extension
+	to := outputFormat
+	if to == "" {
+		to = string(detect.FromExtension(output))
+		if to == "" {
 			return fmt.Errorf("cannot detect output format, please specify with --to")
 		}
 	}
 
 	// Normalize formats to lowercase
-	inputFormat = strings.ToLower(inputFormat)
-	outputFormat = strings.ToLower(outputFormat)
+	from = strings.ToLower(from)
+	to = strings.ToLower(to)
 
 	log.Info().
 		Str("input", input).
 		Str("output", output).
-		Str("from", inputFormat).
-		Str("to", outputFormat).
+		Str("from", from).
+		Str("to", to).
 		Msg("Starting conversion")
 
 	// Build conversion options
+	opts := baseConversionOptions()
+	opts.InputFormat = internal.DocumentFormat(from)
+	opts.OutputFormat = internal.DocumentFormat(to)
+
+	factory, err := newConverterFactory()
+	if err != nil {
+		return err
+	}
+	defer factory.Close() // Stops helper servers
+
+	if dryRun {
+		return printPlan(factory.Plan(opts))
+	}
+
+	// Perform conversion with timeout; Ctrl-C or SIGTERM cancels it so temp files are removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, conversionTimeout(cmd))
+	defer cancel()
+
+	startTime := time.Now()
+	report, err := factory.ConvertStream(ctx, input, output, os.Stdin, os.Stdout, opts)
+	duration := time.Since(startTime)
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("input", input).
+			Str("output", output).
+			Dur("duration", duration).
+			Msg("Conversion failed")
+		if errors.Is(err, internal.ErrOutputExists) {
+			return fmt.Errorf("%w (use --force to overwrite)", err)
+		}
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+
+	// Get output file size
+	stat, _ := os.Stat(output)
+	var fileSize int64
+	if stat != nil {
+		fileSize = stat.Size()
+	}
+
+	log.Info().
+		Str("output", output).
+		Int64("size", fileSize).
+		Dur("duration", duration).
+		Msg("Conversion completed successfully")
+
+	// Status goes to stderr when stdout carries the document
+	status := io.Writer(os.Stdout)
+	if output == converter.Stdio {
+		status = os.Stderr
+		fmt.Fprintf(status, "✓ Converted %s → stdout in %v\n", input, duration.Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(status, "✓ Converted %s → %s (%d bytes) in %v\n",
+			input, output, fileSize, duration.Round(time.Millisecond))
+	}
+	printConversionReport(status, report, "  ")
+
+	return nil
+}
+
+// printConversionReport prints OCR use and source metadata fields that did not make it into the output
+func printConversionReport(w io.Writer, report *converter.ConversionReport, indent string) {
+	if report == nil {
+		return
+	}
+	if report.Cached {
+		fmt.Fprintf(w, "%sReused cached output\n", indent)
+	}
+	if report.OCRFallback {
+		fmt.Fprintf(w, "%sNo text layer found, text recognised with OCR\n", indent)
+	}
+	if report.Metadata == nil || len(report.Metadata.Skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%sMetadata not carried over: %s\n", indent, strings.Join(report.Metadata.Skipped, ", "))
+	if report.Metadata.Sidecar != "" {
+		fmt.Fprintf(w, "%sFull metadata saved to %s\n", indent, report.Metadata.Sidecar)
+	}
+}
+
+// printExistingHint points at --force when outputs were kept because they exist
+func printExistingHint(existing int) {
+	if existing > 0 {
+		fmt.Printf("%d existing outputs were kept, use --force to overwrite them\n", existing)
+	}
+}
+
+// validateConvertArgs requires <input> <output>, only <input> with --out-dir,
+// and nothing with --batch
+func validateConvertArgs(cmd *cobra.Command, args []string) error {
+	if batchInput != "" {
+		if len(args) != 0 {
+			return fmt.Errorf("--batch does not accept positional arguments, got %d", len(args))
+		}
+		return nil
+	}
+	if isMultiTarget() {
+		if len(args) != 1 {
+			return fmt.Errorf("--out-dir takes the input file only, got %d arguments", len(args))
+		}
+		return nil
+	}
+	return cobra.ExactArgs(2)(cmd, args)
+}
+
+// isMultiTarget reports whether a single input is converted into --out-dir,
+// possibly to several --to formats
+func isMultiTarget() bool {
+	return batchInput == "" && (outDir != "" || strings.Contains(outputFormat, ","))
+}
+
+// baseConversionOptions builds conversion options shared by every file
+// from flags, falling back to config values
+func baseConversionOptions() internal.ConversionOptions {
 	opts := internal.ConversionOptions{
-		InputFormat:  internal.DocumentFormat(inputFormat),
-		OutputFormat: internal.DocumentFormat(outputFormat),
-		Quality:      quality,
-		DPI:          dpi,
-		Via:          via,
+		Quality:          quality,
+		DPI:              dpi,
+		Pages:            pages,
+		Via:              via,
+		PreserveMetadata: viper.GetBool("metadata.preserve") && !noPreserveMetadata,
+		OCR:              useOCR,
+		OCRLanguages:     ocrLanguages,
+		OCRFallback:      viper.GetBool("ocr.fallback"),
 	}
 
 	// Use quality from config if not specified
@@ -123,15 +345,22 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		opts.DPI = viper.GetInt("converter.pdf.dpi")
 	}
 
-	// Use timeout from config if not specified (default flag value is 300)
-	if cmd.Flags().Changed("timeout") {
-		// User explicitly set timeout via flag, use it
-	} else {
-		// Use timeout from config
-		timeout = viper.GetInt("converter.timeout")
+	return opts
+}
+
+// conversionTimeout returns the per-conversion timeout
+// The --timeout flag wins when set explicitly, otherwise the config value is used
+func conversionTimeout(cmd *cobra.Command) time.Duration {
+	seconds := timeout
+	if !cmd.Flags().Changed("timeout") {
+		seconds = viper.GetInt("converter.timeout")
 	}
+	return time.Duration(seconds) * time.Second
+}
 
-	// Create converter factory
+// newConverterFactory creates a factory with config-defined converters,
+// the plaintext converter and the helper system (pinged once)
+func newConverterFactory() (*converter.Factory, error) {
 	factory := converter.NewFactory()
 
 	// Load converters from configuration
@@ -141,58 +370,128 @@ func runConvert(cmd *cobra.Command, args []string) error {
 	} else {
 		if cfgErr := factory.LoadFromConfig(converterCfg); cfgErr != nil {
 			log.Error().Err(cfgErr).Msg("Failed to register converters from config")
-			return fmt.Errorf("failed to register converters: %w", cfgErr)
+			return nil, fmt.Errorf("failed to register converters: %w", cfgErr)
+		}
+	}
+
+	// Identical conversions reuse cached outputs
+	if viper.GetBool("cache.enabled") && !noCache {
+		outputs, err := outputCache()
+		if err != nil {
+			log.Warn().Err(err).Msg("Conversion cache disabled")
+		} else {
+			factory.SetCache(outputs, version)
 		}
 	}
 
+	factory.SetKeepIntermediates(keepDir)
+
+	// Outputs are written atomically and existing ones kept unless --force
+	factory.SetOverwrite(force)
+
+	// Outputs that do not parse as their format fail the step (or fall back)
+	factory.SetOutputValidation(viper.GetBool("converter.validate") && !noValidate)
+
+	// Source metadata is re-applied to outputs (embedded or as a sidecar)
+	factory.SetMetadataAnnotator(metadata.NewAnnotator(metadataConfig()))
+
+	// OCR engine for --ocr (binaries are only needed when OCR actually runs)
+	if engine := newOCREngine(); engine != nil {
+		factory.SetOCREngine(engine)
+	}
+
 	// Register PlainText converter (handled specially, not in config)
 	plaintextConverter := plaintext.NewConverter()
 	factory.Register("plaintext", plaintextConverter)
+	factory.SetPriority("plaintext", viper.GetInt("converter.plaintext.priority"))
 
 	// Load and register helper-based converter (with runtime ping check)
+	// Helpers that changed since the cache was generated are queried again
 	helperCtx := context.Background()
-	helperConverter, helperErr := helper.LoadAndPing(helperCtx)
+	helperWeights, discovered, helperErr := configuredHelperWeights()
+	var helperConverter *helper.HelperConverter
+	if helperErr == nil {
+		var cacheFile string
+		if cacheFile, helperErr = helperCacheFile(); helperErr == nil {
+			if discovered > 0 {
+				ensureHelperCache(helperCtx, cacheFile, helperWeights, offline)
+			}
+			helperConverter, helperErr = helper.LoadAndPing(helperCtx, cacheFile, helperWeights, offline)
+		}
+	}
 	if helperErr != nil {
 		log.Warn().Err(helperErr).Msg("Failed to load helper system")
 	} else if helperConverter != nil {
+		helperConverter.SetConcurrencyLimits(helperConcurrencyLimits())
+		helperConverter.SetRetryPolicy(viper.GetInt("helpers.retries"),
+			time.Duration(viper.GetInt("helpers.retry_backoff_ms"))*time.Millisecond)
+		helperConverter.SetCircuitBreaker(viper.GetInt("helpers.breaker.failures"),
+			time.Duration(viper.GetInt("helpers.breaker.cooldown"))*time.Second)
+		helperConverter.SetTimeoutThreshold(viper.GetInt("helpers.breaker.timeouts"))
 		factory.Register("helpers", helperConverter)
+		factory.SetPriority("helpers", viper.GetInt("helpers.priority"))
 		log.Info().Msg("Helper system enabled")
 	}
 
-	// Perform conversion with timeout
-	timeoutDuration := time.Duration(timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
-	defer cancel()
+	return factory, nil
+}
 
-	startTime := time.Now()
-	err := factory.Convert(ctx, input, output, opts)
-	duration := time.Since(startTime)
+// newOCREngine creates the OCR engine selected by ocr.engine, or nil if none is configured
+func newOCREngine() internal.OCREngine {
+	var cfg internal.OCRConfig
+	if err := viper.UnmarshalKey("ocr", &cfg); err != nil {
+		log.Warn().Err(err).Msg("Invalid OCR configuration, OCR disabled")
+		return nil
+	}
+
+	switch strings.ToLower(cfg.Engine) {
+	case "tesseract":
+		return ocr.NewTesseractEngine(cfg)
+	case "", "none":
+		return nil
+	default:
+		log.Warn().Str("engine", cfg.Engine).Msg("Unknown OCR engine, OCR disabled")
+		return nil
+	}
+}
+
+// detectInputFormat sniffs the input content and reconciles it with the file extension
+// Confident content matches win over the extension (with a warning on mismatch);
+// weak matches such as "looks like text" defer to the extension
+func detectInputFormat(input string) (internal.DocumentFormat, error) {
+	extFormat := detect.FromExtension(input)
 
+	result, err := detect.Detect(input)
 	if err != nil {
-		log.Error().
-			Err(err).
-			Str("input", input).
-			Str("output", output).
-			Dur("duration", duration).
-			Msg("Conversion failed")
-		return fmt.Errorf("conversion failed: %w", err)
+		log.Warn().Err(err).Str("input", input).Msg("Content detection failed, using file extension")
+		result = detect.Result{}
 	}
 
-	// Get output file size
-	stat, _ := os.Stat(output)
-	var fileSize int64
-	if stat != nil {
-		fileSize = stat.Size()
+	if result.Confidence >= detect.ConfidenceMedium {
+		if !detect.Agrees(extFormat, result) {
+			log.Warn().
+				Str("input", input).
+				Str("extension", string(extFormat)).
+				Str("detected", string(result.Format)).
+				Float64("confidence", result.Confidence).
+				Str("reason", result.Reason).
+				Msg("File extension does not match content, using detected format")
+		}
+		return result.Format, nil
 	}
 
-	log.Info().
-		Str("output", output).
-		Int64("size", fileSize).
-		Dur("duration", duration).
-		Msg("Conversion completed successfully")
+	if extFormat != "" {
+		return extFormat, nil
+	}
 
-	fmt.Printf("✓ Converted %s → %s (%d bytes) in %v\n",
-		input, output, fileSize, duration.Round(time.Millisecond))
+	if result.Known() {
+		log.Info().
+			Str("input", input).
+			Str("detected", string(result.Format)).
+			Float64("confidence", result.Confidence).
+			Msg("No file extension, using low-confidence detected format")
+		return result.Format, nil
+	}
 
-	return nil
+	return "", fmt.Errorf("cannot detect input format, please specify with --from")
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/extractor"
+)
+
+var (
+	extractType   string
+	extractFormat string
+)
+
+// extractCmd represents the extract command
+var extractCmd = &cobra.Command{
+	Use:   "extract <file>",
+	Short: "Extract tables, code blocks or formulas from a document",
+	Long: `Extract structured content from a document.
+
+Types:
+  tables    table rows with the header row split out (CSV in text output)
+  code      preformatted code blocks with declared or detected language
+  formulas  LaTeX ($..$, $$..$$, \(..\), \[..\]) and MathML formulas
+
+HTML and Markdown are read directly. Other formats (DOCX, EPUB, ...) are
+converted to HTML with the configured converters first.
+
+Results follow output.format (json, yaml, or text).
+
+Examples:
+  yakateka extract --type tables report.docx
+  yakateka extract --type code README.md
+  yakateka extract --type formulas paper.epub`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExtract,
+}
+
+func init() {
+	rootCmd.AddCommand(extractCmd)
+
+	extractCmd.Flags().StringVarP(&extractType, "type", "t", "", "what to extract: tables, code, formulas (required)")
+	extractCmd.Flags().StringVarP(&extractFormat, "from", "f", "",
+		"document format (auto-detected from content and extension if not specified)")
+	_ = extractCmd.MarkFlagRequired("type")
+}
+
+func runExtract(cmd *cobra.Command, args []string) error {
+	input := args[0]
+
+	if _, err := os.Stat(input); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", input)
+	}
+
+	format := internal.DocumentFormat(strings.ToLower(extractFormat))
+	if format == "" {
+		var err error
+		format, err = detectInputFormat(input)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only formats other than HTML and Markdown need the converters (and helper pings)
+	var conv extractor.DocumentConverter
+	if format != internal.FormatHTML && format != internal.FormatMD {
+		factory, err := newConverterFactory()
+		if err != nil {
+			return err
+		}
+		defer factory.Close() // Stops helper servers
+		conv = factory
+	}
+	ext := extractor.NewExtractor(conv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout(cmd))
+	defer cancel()
+
+	result, err := ext.ExtractFormat(ctx, input, format, internal.ExtractionOptions{ExtractType: extractType})
+	if err != nil {
+		log.Error().Err(err).Str("input", input).Msg("Extraction failed")
+		return fmt.Errorf("failed to extract: %w", err)
+	}
+
+	return printOutput(result, func(w io.Writer) {
+		writeExtractionText(w, result)
+	})
+}
+
+// writeExtractionText renders extracted items for output.format=text
+// Tables are written as CSV, one block per table
+func writeExtractionText(w io.Writer, result *extractor.Result) {
+	if result.Count() == 0 {
+		fmt.Fprintf(w, "No %s found in %s\n", result.Type, result.Input)
+		return
+	}
+
+	for i, table := range result.Tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if table.Caption != "" {
+			fmt.Fprintf(w, "# Table %d: %s\n", table.Index, table.Caption)
+		} else {
+			fmt.Fprintf(w, "# Table %d\n", table.Index)
+		}
+		writer := csv.NewWriter(w)
+		if len(table.Header) > 0 {
+			_ = writer.Write(table.Header)
+		}
+		_ = writer.WriteAll(table.Rows)
+	}
+
+	for i, block := range result.Code {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		lang := block.Language
+		switch {
+		case lang == "":
+			lang = "unknown"
+		case block.Detected:
+			lang += ", detected"
+		}
+		fmt.Fprintf(w, "--- Code %d (%s) ---\n%s\n", block.Index, lang, block.Code)
+	}
+
+	for _, formula := range result.Formulas {
+		kind := formula.Notation
+		if formula.Display {
+			kind += ", display"
+		}
+		fmt.Fprintf(w, "%d. [%s] %s\n", formula.Index, kind, formula.Source)
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 var (
 	showFormatsMatrix bool
+	checkHelpers      bool
 )
 
 // helpersCmd represents the helpers command
@@ -25,34 +26,48 @@ var helpersCmd = &cobra.Command{
 	Long: `Query all configured helpers and generate helpers.yaml cache file.
 
 This command:
-1. Reads helper paths and weights from config
+1. Reads helper paths and weights from config, and discovers helpers in
+   $YAKATEKA_HELPERS_PATH, ~/.yakateka/helpers.d and /usr/share/yakateka/helpers
 2. Pings each helper to check availability
 3. Queries each helper for capabilities (helper.sh info)
 4. Generates helpers.yaml with sorted helper lists
 
-The cache file is used at runtime for fast helper lookup.
+The cache file is used at runtime for fast helper lookup. Helpers that
+changed since (edited script, new weight, added or removed helper) are
+queried again automatically by convert, unless --offline.
 
-Use --formats to display a matrix of supported format conversions.`,
+Use --formats to display a matrix of supported format conversions.
+Use --check to list stale helpers without writing the cache.`,
 	RunE: runHelpers,
 }
 
 func init() {
 	rootCmd.AddCommand(helpersCmd)
 	helpersCmd.Flags().BoolVar(&showFormatsMatrix, "formats", false, "Display format conversion matrix")
+	helpersCmd.Flags().BoolVar(&checkHelpers, "check", false, "Report helpers that changed since the cache was generated, without writing it")
 }
 
 func runHelpers(cmd *cobra.Command, args []string) error {
 	// Load helper configuration
-	helperWeights := viper.GetStringMap("helpers.weights")
-	if len(helperWeights) == 0 {
-		log.Warn().Msg("No helpers configured in config file")
-		return fmt.Errorf("no helpers configured (check helpers.weights in config)")
+	helperWeights, _, err := configuredHelperWeights()
+	if err != nil {
+		return err
 	}
 
-	// Get cache file path
-	cacheFile := viper.GetString("helpers.cache_file")
-	if cacheFile == "" {
-		cacheFile = "helpers.yaml"
+	cacheFile, err := helperCacheFile()
+	if err != nil {
+		return err
+	}
+
+	if checkHelpers {
+		cmd.SilenceUsage = true // A stale cache is a result, not a usage error
+		return checkHelperCache(cacheFile, helperWeights)
+	}
+
+	if len(helperWeights) == 0 {
+		log.Warn().Msg("No helpers configured in config file or found in helper directories")
+		return fmt.Errorf("no helpers configured (check helpers.weights in config, or install helpers in %s)",
+			strings.Join(helper.DiscoveryDirs(), ", "))
 	}
 
 	log.Info().
@@ -60,84 +75,239 @@ func runHelpers(cmd *cobra.Command, args []string) error {
 		Str("cache", cacheFile).
 		Msg("Generating helper cache")
 
+	cache, err := generateHelperCache(context.Background(), cacheFile, helperWeights)
+	if err != nil {
+		return err
+	}
+
+	// Print summary
+	conversionsCount := 0
+	for _, toFormats := range cache.Conversions {
+		for _, modes := range toFormats {
+			conversionsCount += len(modes)
+		}
+	}
+
+	log.Info().
+		Str("cache", cacheFile).
+		Int("conversions", conversionsCount).
+		Msg("Successfully generated helper cache")
+
+	fmt.Printf("✓ Generated helper cache: %s\n", cacheFile)
+	fmt.Printf("  %d conversion paths available\n", conversionsCount)
+
+	// Display format matrix if requested
+	if showFormatsMatrix {
+		fmt.Println()
+		displayFormatMatrix(cache)
+	}
+
+	return nil
+}
+
+// generateHelperCache queries the helpers (path -> weight) and saves their cache
+func generateHelperCache(ctx context.Context, cacheFile string, weights map[string]float64) (*helper.HelperCache, error) {
 	// Create registry
 	registry := helper.NewRegistry()
 
 	// Register all helpers
-	for path, weight := range helperWeights {
-		// Expand environment variables in path (e.g., ${HOME}/path)
-		path = os.ExpandEnv(path)
-
-		// Convert relative paths to absolute based on current working directory
-		if !filepath.IsAbs(path) {
-			cwd, err := os.Getwd()
-			if err != nil {
-				log.Error().Err(err).Str("helper", path).Msg("Failed to get current working directory for relative helper path")
-				return fmt.Errorf("failed to get current working directory for helper %s: %w", path, err)
-			}
-			path = filepath.Join(cwd, path)
-		}
-		weightFloat, ok := weight.(float64)
-		if !ok {
-			log.Warn().
-				Str("helper", path).
-				Interface("weight", weight).
-				Msg("Invalid weight value, skipping helper")
-			continue
-		}
-
-		registry.Register(path, weightFloat)
+	for path, weight := range weights {
+		registry.Register(path, weight)
 		log.Debug().
 			Str("helper", path).
-			Float64("weight", weightFloat).
+			Float64("weight", weight).
 			Msg("Registered helper")
 	}
 
 	// Initialize helpers (ping + get info)
 	executor := helper.NewExecutor(helper.GetTimeout())
-	ctx := context.Background()
-
 	if err := registry.Initialize(ctx, executor); err != nil {
 		log.Error().Err(err).Msg("Failed to initialize helpers")
-		return fmt.Errorf("helper initialization failed: %w", err)
+		return nil, fmt.Errorf("helper initialization failed: %w", err)
 	}
 
 	// Generate cache
 	cache, err := registry.GenerateCache()
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to generate cache")
-		return fmt.Errorf("cache generation failed: %w", err)
+		return nil, fmt.Errorf("cache generation failed: %w", err)
 	}
 
 	// Save cache to file
 	if err := cache.SaveCache(cacheFile); err != nil {
 		log.Error().Err(err).Msg("Failed to save cache")
-		return fmt.Errorf("failed to save cache: %w", err)
+		return nil, fmt.Errorf("failed to save cache: %w", err)
 	}
+	return cache, nil
+}
 
-	// Print summary
-	conversionsCount := 0
-	for _, toFormats := range cache.Conversions {
-		for _, modes := range toFormats {
-			conversionsCount += len(modes)
-		}
+// ensureHelperCache generates a missing helper cache, so helpers discovered in the
+// helper directories are used without running 'yakateka helpers' first
+// Offline it only warns
+func ensureHelperCache(ctx context.Context, cacheFile string, weights map[string]float64, offline bool) {
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		return
+	}
+	if offline {
+		log.Warn().
+			Str("cache", cacheFile).
+			Msg("Helper cache not found, run 'yakateka helpers' to use the discovered helpers")
+		return
 	}
 
 	log.Info().
 		Str("cache", cacheFile).
-		Int("conversions", conversionsCount).
-		Msg("Successfully generated helper cache")
+		Int("helpers", len(weights)).
+		Msg("Helper cache not found, generating it for the discovered helpers")
+	if _, err := generateHelperCache(ctx, cacheFile, weights); err != nil {
+		log.Warn().Err(err).Msg("Failed to generate helper cache, helper system disabled")
+	}
+}
 
-	fmt.Printf("✓ Generated helper cache: %s\n", cacheFile)
-	fmt.Printf("  %d conversion paths available\n", conversionsCount)
+// checkHelperCache reports the helpers that changed since the cache was written
+// without touching it, failing if any did
+func checkHelperCache(cacheFile string, weights map[string]float64) error {
+	cache, err := helper.LoadCache(cacheFile)
+	if err != nil {
+		return err
+	}
 
-	// Display format matrix if requested
-	if showFormatsMatrix {
-		fmt.Println()
-		displayFormatMatrix(cache)
+	drifts := cache.Drift(weights)
+	if len(drifts) == 0 {
+		fmt.Printf("✓ Helper cache is up to date: %s\n", cacheFile)
+		return nil
 	}
 
-	return nil
+	fmt.Printf("✗ Helper cache is stale: %s\n", cacheFile)
+	for _, d := range drifts {
+		fmt.Printf("  %-8s %s\n", d.Reason, d.Helper)
+	}
+	return fmt.Errorf("%d helpers changed since the cache was generated (run 'yakateka helpers' to refresh it)", len(drifts))
+}
+
+// configuredHelperWeights returns the helpers to use (path -> weight): those
+// discovered in the helper directories unless helpers.discover is off, and those
+// in helpers.weights with resolved paths, whose weights win; and how many were
+// discovered. Helpers with invalid weights are skipped
+func configuredHelperWeights() (map[string]float64, int, error) {
+	weights := make(map[string]float64)
+	if viper.GetBool("helpers.discover") {
+		weights = helper.Discover(helper.DiscoveryDirs(), viper.GetStringSlice("helpers.disabled"))
+	}
+	discovered := len(weights)
+
+	raw := viper.GetStringMap("helpers.weights")
+	for path, weight := range raw {
+		path, err := resolveConfigPath(path)
+		if err != nil {
+			log.Error().Err(err).Str("helper", path).Msg("Failed to resolve relative helper path")
+			return nil, 0, err
+		}
+		weightFloat, ok := weight.(float64)
+		if !ok {
+			log.Warn().
+				Str("helper", path).
+				Interface("weight", weight).
+				Msg("Invalid weight value, skipping helper")
+			continue
+		}
+		weights[path] = weightFloat
+	}
+	return weights, discovered, nil
+}
+
+// helperCacheFile returns the path of the helper cache (helpers.cache_file)
+func helperCacheFile() (string, error) {
+	cacheFile := viper.GetString("helpers.cache_file")
+	if cacheFile == "" {
+		cacheFile = "helpers.yaml"
+	}
+	return resolveConfigPath(cacheFile)
+}
+
+// resolveConfigPath expands environment variables (e.g., ${HOME}/path) in a path
+// from the config and makes a relative one absolute against the directory of the
+// config file, or the current directory when no config file is used, so the
+// result does not depend on where yakateka runs
+func resolveConfigPath(path string) (string, error) {
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return resolvePath(".", path)
+	}
+	return resolveFromConfigDir(filepath.Dir(configFile), path)
+}
+
+// resolveFromConfigDir resolves path against configDir; a relative path that only
+// exists in the current directory, which older versions resolved against, is
+// still taken from there with a warning
+func resolveFromConfigDir(configDir, path string) (string, error) {
+	resolved, err := resolvePath(configDir, path)
+	if err != nil {
+		return resolved, err
+	}
+	if _, err := os.Stat(resolved); !os.IsNotExist(err) {
+		return resolved, nil
+	}
+
+	legacy, err := resolvePath(".", path)
+	if err != nil || legacy == resolved {
+		return resolved, nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return resolved, nil
+	}
+	log.Warn().
+		Str("path", path).
+		Str("using", legacy).
+		Str("config_dir", configDir).
+		Msg("Path not found relative to the config file, using the current directory; make it relative to the config file")
+	return legacy, nil
+}
+
+// resolvePath expands environment variables in path and makes it absolute against base
+func resolvePath(base, path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	resolved, err := filepath.Abs(filepath.Join(base, path))
+	if err != nil {
+		return path, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return resolved, nil
+}
+
+// helperConcurrencyLimits reads helpers.max_concurrent (path -> limit) from config
+// Paths are resolved the same way as helpers.weights so they match cache entries
+func helperConcurrencyLimits() map[string]int {
+	raw := viper.GetStringMap("helpers.max_concurrent")
+	limits := make(map[string]int, len(raw))
+	for path, value := range raw {
+		resolved, err := resolveConfigPath(path)
+		if err != nil {
+			log.Warn().Err(err).Str("helper", path).Msg("Skipping concurrency limit for helper")
+			continue
+		}
+
+		var limit int
+		switch v := value.(type) {
+		case int:
+			limit = v
+		case int64:
+			limit = int(v)
+		case float64:
+			limit = int(v)
+		default:
+			log.Warn().
+				Str("helper", path).
+				Interface("limit", value).
+				Msg("Invalid concurrency limit, ignoring")
+			continue
+		}
+		limits[resolved] = limit
+	}
+	return limits
 }
 
 const (
@@ -0,0 +1,101 @@
+//go:build unix || linux || darwin
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal/helper"
+)
+
+func TestResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("YAKATEKA_TEST_HELPERS", "/opt/helpers")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		base string
+		path string
+		want string
+	}{
+		{dir, "helpers.yaml", filepath.Join(dir, "helpers.yaml")},
+		{dir, "../helpers/pandoc-helper.sh", filepath.Join(filepath.Dir(dir), "helpers", "pandoc-helper.sh")},
+		{dir, "/usr/bin/helper.sh", "/usr/bin/helper.sh"},
+		{dir, "${YAKATEKA_TEST_HELPERS}/helper.sh", "/opt/helpers/helper.sh"},
+		{".", "helpers.yaml", filepath.Join(cwd, "helpers.yaml")},
+	}
+	for _, tt := range tests {
+		got, err := resolvePath(tt.base, tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("resolvePath(%q, %q) = %q, %v; want %q", tt.base, tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveFromConfigDir(t *testing.T) {
+	configDir := t.TempDir()
+	workDir := t.TempDir()
+	t.Chdir(workDir)
+	for _, path := range []string{filepath.Join(configDir, "helpers", "new.sh"), filepath.Join(workDir, "helpers", "old.sh")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"helpers/new.sh", filepath.Join(configDir, "helpers", "new.sh")},
+		// Paths of older configs, relative to the current directory, still work
+		{"helpers/old.sh", filepath.Join(workDir, "helpers", "old.sh")},
+		// Files yet to be written go next to the config
+		{"helpers.yaml", filepath.Join(configDir, "helpers.yaml")},
+	}
+	for _, tt := range tests {
+		got, err := resolveFromConfigDir(configDir, tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveFromConfigDir(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestEnsureHelperCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "found-helper.sh")
+	script := `#!/bin/sh
+case "$1" in
+ping) echo pong ;;
+info) printf 'name: found\ncapabilities:\n  md:\n    html:\n      modes:\n        normal:\n          speed: 1\n          quality: 1\n' ;;
+esac
+`
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	weights := map[string]float64{path: 0.5}
+	cacheFile := filepath.Join(dir, "helpers.yaml")
+
+	// Offline only warns
+	ensureHelperCache(context.Background(), cacheFile, weights, true)
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected no cache to be written offline, got %v", err)
+	}
+
+	ensureHelperCache(context.Background(), cacheFile, weights, false)
+	cache, err := helper.LoadCache(cacheFile)
+	if err != nil {
+		t.Fatalf("Expected the cache to be generated, got %v", err)
+	}
+	if entries := cache.Conversions["md"]["html"]["normal"]; len(entries) != 1 || entries[0].Helper != path {
+		t.Errorf("Expected the discovered helper's conversion, got %v", entries)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/parser"
+)
+
+var (
+	metadataFormat string
+)
+
+// metadataCmd represents the metadata command
+var metadataCmd = &cobra.Command{
+	Use:   "metadata <file>",
+	Short: "Read document metadata",
+	Long: `Read metadata (title, author, language, tags, description, dates,
+page count and checksum) from a document.
+
+Supported formats:
+  - EPUB (OPF package metadata)
+  - FB2 (<title-info>)
+  - DOCX (docProps/core.xml, docProps/app.xml)
+  - ODT (meta.xml)
+  - PDF (Info dictionary, XMP)
+  - DJVU (djvused print-meta, page count)
+
+Output follows output.format (json, yaml, text) and output.pretty from config.
+
+Examples:
+  yakateka metadata book.epub
+  yakateka metadata scan.bin --from pdf`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMetadata,
+}
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+
+	metadataCmd.Flags().StringVarP(&metadataFormat, "from", "f", "",
+		"document format (auto-detected from content and extension if not specified)")
+}
+
+func runMetadata(cmd *cobra.Command, args []string) error {
+	input := args[0]
+
+	if _, err := os.Stat(input); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", input)
+	}
+
+	p := parser.NewParser(viper.GetString("metadata.checksum"))
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout(cmd))
+	defer cancel()
+
+	var meta *internal.DocumentMetadata
+	var err error
+	if metadataFormat != "" {
+		meta, err = p.ParseFormat(ctx, input, internal.DocumentFormat(strings.ToLower(metadataFormat)))
+	} else {
+		meta, err = p.Parse(ctx, input)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("input", input).Msg("Failed to read metadata")
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	return printOutput(meta, func(w io.Writer) {
+		writeMetadataText(w, meta)
+	})
+}
+
+// metadataConfig reads the metadata section of the configuration
+func metadataConfig() internal.MetadataConfig {
+	return internal.MetadataConfig{
+		Checksum: viper.GetString("metadata.checksum"),
+		Sidecar:  viper.GetBool("metadata.sidecar"),
+		Embed:    viper.GetBool("metadata.embed"),
+		Preserve: viper.GetBool("metadata.preserve"),
+	}
+}
+
+// writeMetadataText renders metadata as "Field: value" lines, skipping empty fields
+func writeMetadataText(w io.Writer, meta *internal.DocumentMetadata) {
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%-12s %s\n", name+":", value)
+		}
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	field("Title", meta.Title)
+	field("Author", meta.Author)
+	field("Language", meta.Language)
+	field("Category", meta.Category)
+	field("Tags", strings.Join(meta.Tags, ", "))
+	field("Description", meta.Description)
+	field("Created", date(meta.Created))
+	field("Modified", date(meta.Modified))
+	if meta.PageCount > 0 {
+		field("Pages", fmt.Sprintf("%d", meta.PageCount))
+	}
+	field("Checksum", meta.Checksum)
+
+	keys := make([]string, 0, len(meta.Custom))
+	for k := range meta.Custom {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field(k, meta.Custom[k])
+	}
+}
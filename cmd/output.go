@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by output.format
+const (
+	outputJSON = "json"
+	outputYAML = "yaml"
+	outputText = "text"
+)
+
+// printOutput writes v to stdout according to output.format and output.pretty
+// text renders the human-readable form used for output.format=text
+func printOutput(v interface{}, text func(w io.Writer)) error {
+	return writeOutput(os.Stdout, viper.GetString("output.format"), viper.GetBool("output.pretty"), v, text)
+}
+
+// writeOutput serialises v in the given format
+func writeOutput(w io.Writer, format string, pretty bool, v interface{}, text func(w io.Writer)) error {
+	switch format {
+	case outputJSON, "":
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		if pretty {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(v)
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		defer encoder.Close()
+		if pretty {
+			encoder.SetIndent(2)
+		}
+		return encoder.Encode(v)
+	case outputText:
+		text(w)
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s (expected json, yaml or text)", format)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+func TestWriteOutput(t *testing.T) {
+	meta := &internal.DocumentMetadata{Title: "Test", PageCount: 3}
+	text := func(w io.Writer) { fmt.Fprintln(w, "Title: Test") }
+
+	tests := []struct {
+		format string
+		pretty bool
+		want   string
+	}{
+		{"json", false, `{"title":"Test","page_count":3}`},
+		{"json", true, "{\n  \"title\": \"Test\",\n  \"page_count\": 3\n}"},
+		{"yaml", true, "title: Test\npage_count: 3"},
+		{"text", true, "Title: Test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeOutput(&buf, tt.format, tt.pretty, meta, text); err != nil {
+				t.Fatalf("writeOutput failed: %v", err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if err := writeOutput(&bytes.Buffer{}, "xml", false, meta, text); err == nil {
+		t.Error("Expected error for invalid output format")
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/converter"
+	"github.com/valpere/yakateka/internal/detect"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan <from> <to>",
+	Short: "Explain how a conversion would be performed",
+	Long: `Show the route a conversion would take without running it.
+
+<from> and <to> are formats (epub, md) or file names whose format is
+detected (book.epub, notes.md). The output lists:
+  - the selected route with the cost of each step
+  - every converter and helper able to perform each step, with helper
+    weights and speed/quality metrics, and why it was not chosen
+  - alternative pipelines and why they were rejected
+
+Routes depend on --quality (fast, normal, quality), like convert. With
+--via the listed converters are checked instead of searched for.
+Results follow output.format (json, yaml, or text).
+
+Examples:
+  yakateka plan docx md
+  yakateka plan book.epub book.pdf --quality fast
+  yakateka plan doc md --via libreoffice,pandoc
+  yakateka convert report.docx report.md --dry-run   # same, for a real conversion`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPlan,
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringVar(&quality, "quality", "",
+		"conversion objective: fast, normal, quality (low, medium, high are accepted)")
+	planCmd.Flags().BoolVar(&useOCR, "ocr", false, "plan an OCR conversion")
+	planCmd.Flags().StringVar(&via, "via", "",
+		"converter to use: a converters.yaml name, a helper name or path, or a comma-separated pipeline (libreoffice,pandoc)")
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	from, err := planFormat(args[0], true)
+	if err != nil {
+		return err
+	}
+	to, err := planFormat(args[1], false)
+	if err != nil {
+		return err
+	}
+
+	opts := baseConversionOptions()
+	opts.InputFormat = from
+	opts.OutputFormat = to
+
+	factory, err := newConverterFactory()
+	if err != nil {
+		return err
+	}
+	defer factory.Close() // Stops helper servers
+	return printPlan(factory.Plan(opts))
+}
+
+// planFormat resolves a plan argument: an existing input file is sniffed,
+// other names with an extension use it, anything else is a format name
+func planFormat(arg string, input bool) (internal.DocumentFormat, error) {
+	if input {
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+			return detectInputFormat(arg)
+		}
+	}
+	if filepath.Ext(arg) != "" {
+		if format := detect.FromExtension(arg); format != "" {
+			return format, nil
+		}
+		return "", fmt.Errorf("cannot detect format of %s", arg)
+	}
+	return internal.DocumentFormat(strings.ToLower(arg)), nil
+}
+
+// printPlan writes a plan per output.format; it is an error when no route exists
+func printPlan(plan *converter.Plan) error {
+	if err := printOutput(plan, func(w io.Writer) { writePlanText(w, plan) }); err != nil {
+		return err
+	}
+	if plan.Route == nil {
+		return fmt.Errorf("%w: %s → %s: %s", internal.ErrUnsupportedConversion, plan.From, plan.To, plan.Reason)
+	}
+	return nil
+}
+
+// writePlanText renders a plan for output.format=text
+func writePlanText(w io.Writer, plan *converter.Plan) {
+	fmt.Fprintf(w, "Plan: %s → %s (mode: %s)\n", plan.From, plan.To, plan.Mode)
+	if plan.Via != "" {
+		fmt.Fprintf(w, "Forced with --via %s\n", plan.Via)
+	}
+	if plan.Route == nil {
+		fmt.Fprintf(w, "✗ No route: %s\n", plan.Reason)
+		return
+	}
+
+	fmt.Fprintf(w, "\nRoute: %s (cost %.2f)\n", routeFormats(plan.Route), plan.Route.Cost)
+	for i, step := range plan.Route.Steps {
+		fmt.Fprintf(w, "  %d. %s → %s  %s  (cost %.2f, speed %.2g, quality %.2g)\n",
+			i+1, step.From, step.To, step.Converter, step.Cost, step.Speed, step.Quality)
+	}
+
+	if len(plan.Candidates) > 0 {
+		fmt.Fprintln(w, "\nCandidates:")
+		for _, pair := range plan.Candidates {
+			fmt.Fprintf(w, "  %s → %s\n", pair.From, pair.To)
+			for _, c := range pair.Converters {
+				mark := "✗"
+				if c.Selected {
+					mark = "✓"
+				}
+				name := c.Converter
+				if c.Helper != "" {
+					name += ": " + c.Helper + fmt.Sprintf(" (weight %.2g)", c.Weight)
+				}
+				fmt.Fprintf(w, "    %s %s  priority %d, speed %.2g, quality %.2g", mark, name, c.Priority, c.Speed, c.Quality)
+				if c.Cost > 0 {
+					fmt.Fprintf(w, ", cost %.2f", c.Cost)
+				}
+				if c.Rejected != "" {
+					fmt.Fprintf(w, " — %s", c.Rejected)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+	}
+
+	if len(plan.Alternatives) > 0 {
+		fmt.Fprintln(w, "\nAlternatives:")
+		for _, route := range plan.Alternatives {
+			var names []string
+			for _, step := range route.Steps {
+				names = append(names, step.Converter)
+			}
+			fmt.Fprintf(w, "  ✗ %s (%s)  cost %.2f — %s\n",
+				routeFormats(&route), strings.Join(names, ", "), route.Cost, route.Rejected)
+		}
+	}
+}
+
+// routeFormats renders the formats a route passes through: docx → html → md
+func routeFormats(route *converter.PlanRoute) string {
+	if len(route.Steps) == 0 {
+		return ""
+	}
+	formats := []string{string(route.Steps[0].From)}
+	for _, step := range route.Steps {
+		formats = append(formats, string(step.To))
+	}
+	return strings.Join(formats, " → ")
+}
@@ -117,12 +117,14 @@ func setDefaults() {
 	viper.SetDefault("ocr.engine", "tesseract")
 	viper.SetDefault("ocr.languages", []string{"uk", "en", "ru"})
 	viper.SetDefault("ocr.dpi", 300)
+	viper.SetDefault("ocr.fallback", true)
 	viper.SetDefault("ocr.preprocess.denoise", true)
 	viper.SetDefault("ocr.preprocess.deskew", true)
 	viper.SetDefault("ocr.preprocess.threshold", "auto")
 
 	// Converter defaults
 	viper.SetDefault("converter.timeout", 300)
+	viper.SetDefault("converter.validate", true)
 	viper.SetDefault("converter.pdf.engine", "pdfcpu")
 	viper.SetDefault("converter.pdf.quality", "high")
 	viper.SetDefault("converter.pandoc.path", "/usr/bin/pandoc")
@@ -131,10 +133,27 @@ func setDefaults() {
 	viper.SetDefault("converter.image.format", "png")
 	viper.SetDefault("converter.image.dpi", 300)
 
+	// Helper discovery defaults
+	viper.SetDefault("helpers.discover", true)
+	viper.SetDefault("helpers.disabled", []string{})
+
+	// Helper failure handling defaults
+	viper.SetDefault("helpers.retries", 2)
+	viper.SetDefault("helpers.retry_backoff_ms", 500)
+	viper.SetDefault("helpers.breaker.failures", 3)
+	viper.SetDefault("helpers.breaker.timeouts", 5)
+	viper.SetDefault("helpers.breaker.cooldown", 30)
+
+	// Conversion cache defaults
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.dir", "")
+	viper.SetDefault("cache.max_size_mb", 2048)
+
 	// Metadata defaults
 	viper.SetDefault("metadata.checksum", "sha256")
 	viper.SetDefault("metadata.sidecar", true)
 	viper.SetDefault("metadata.embed", true)
+	viper.SetDefault("metadata.preserve", true)
 
 	// Output defaults
 	viper.SetDefault("output.format", "json")
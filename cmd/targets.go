@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/converter"
+)
+
+// runMultiTargetConvert converts one input to every --to format, writing
+// <out-dir>/<input name>.<format>
+func runMultiTargetConvert(cmd *cobra.Command, args []string) error {
+	input := args[0]
+	if outDir == "" {
+		return fmt.Errorf("several --to formats require --out-dir")
+	}
+	if input == converter.Stdio {
+		return fmt.Errorf("--out-dir needs an input file, stdin (-) is not supported")
+	}
+	formats, err := parseTargetFormats(outputFormat)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(input); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", input)
+	}
+	from := internal.DocumentFormat(strings.ToLower(inputFormat))
+	if from == "" {
+		from, err = detectInputFormat(input)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	base := filepath.Base(input)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	targets := make([]converter.Target, len(formats))
+	for i, format := range formats {
+		targets[i] = converter.Target{Format: format, Output: filepath.Join(outDir, stem+"."+string(format))}
+	}
+
+	log.Info().
+		Str("input", input).
+		Str("out_dir", outDir).
+		Str("from", string(from)).
+		Str("to", outputFormat).
+		Msg("Starting multi-target conversion")
+
+	opts := baseConversionOptions()
+	opts.InputFormat = from
+
+	factory, err := newConverterFactory()
+	if err != nil {
+		return err
+	}
+	defer factory.Close() // Stops helper servers
+
+	// Ctrl-C cancels every target; the timeout covers the whole run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, conversionTimeout(cmd))
+	defer cancel()
+
+	startTime := time.Now()
+	results := factory.ConvertTargets(ctx, input, targets, opts)
+	for _, result := range results {
+		printTargetResult(result)
+	}
+	return summarizeTargets(input, results, time.Since(startTime))
+}
+
+// parseTargetFormats splits a comma-separated --to value, dropping duplicates
+func parseTargetFormats(value string) ([]internal.DocumentFormat, error) {
+	var formats []internal.DocumentFormat
+	seen := make(map[internal.DocumentFormat]bool)
+	for _, name := range strings.Split(value, ",") {
+		format := internal.DocumentFormat(strings.ToLower(strings.TrimSpace(name)))
+		if format == "" {
+			return nil, fmt.Errorf("--out-dir requires --to formats, e.g. --to epub,mobi,pdf")
+		}
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+// printTargetResult prints a one-line summary for a finished target
+func printTargetResult(r converter.TargetResult) {
+	if r.Err != nil {
+		fmt.Printf("✗ %s: %v\n", r.Format, r.Err)
+		return
+	}
+	var size int64
+	if stat, err := os.Stat(r.Output); err == nil {
+		size = stat.Size()
+	}
+	fmt.Printf("✓ %s → %s (%d bytes) in %v\n",
+		r.Format, r.Output, size, r.Duration.Round(time.Millisecond))
+	fmt.Printf("  Route: %s\n", r.Route)
+	printConversionReport(os.Stdout, r.Report, "  ")
+}
+
+// summarizeTargets prints totals and returns an error if any target failed
+func summarizeTargets(input string, results []converter.TargetResult, elapsed time.Duration) error {
+	failed, existing := 0, 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+		if errors.Is(r.Err, internal.ErrOutputExists) {
+			existing++
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Converted %s to %d of %d formats in %v\n",
+		input, len(results)-failed, len(results), elapsed.Round(time.Millisecond))
+	printExistingHint(existing)
+
+	log.Info().
+		Str("input", input).
+		Int("targets", len(results)).
+		Int("failed", failed).
+		Dur("duration", elapsed).
+		Msg("Multi-target conversion completed")
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(results))
+	}
+	return nil
+}
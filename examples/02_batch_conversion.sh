@@ -31,40 +31,20 @@ if [ ! -d "$INPUT_DIR" ]; then
     exit 1
 fi
 
-# Find and convert all PDFs
-pdf_count=0
-success_count=0
-fail_count=0
-
-for pdf in "$INPUT_DIR"/*.pdf; do
-    if [ -f "$pdf" ]; then
-        pdf_count=$((pdf_count + 1))
-        filename=$(basename "$pdf" .pdf)
-        output="$OUTPUT_DIR/${filename}.txt"
-
-        echo "[$pdf_count] Converting: $(basename "$pdf")"
-
-        if $YAKATEKA convert "$pdf" "$output" --log-format text > ../tmp/conversion_${pdf_count}.log 2>&1; then
-            success_count=$((success_count + 1))
-            file_size=$(stat -f%z "$output" 2>/dev/null || stat -c%s "$output" 2>/dev/null)
-            echo "    ✓ Success: $output ($file_size bytes)"
-        else
-            fail_count=$((fail_count + 1))
-            echo "    ✗ Failed: $pdf"
-            echo "    See log: ../tmp/conversion_${pdf_count}.log"
-        fi
-        echo ""
-    fi
-done
-
-echo "=== Batch Conversion Complete ==="
-echo ""
-echo "Total PDFs: $pdf_count"
-echo "Successful: $success_count"
-echo "Failed:     $fail_count"
+# Convert all PDFs in one invocation: the converter factory and helper cache
+# are built once and the directory tree is mirrored into OUTPUT_DIR
+if $YAKATEKA convert --batch "$INPUT_DIR/*.pdf" --out-dir "$OUTPUT_DIR" --to txt \
+    --log-format text 2> ../tmp/batch_conversion.log; then
+    echo ""
+    echo "=== Batch Conversion Complete ==="
+else
+    echo ""
+    echo "=== Batch Conversion Finished With Failures ==="
+    echo "See log: ../tmp/batch_conversion.log"
+fi
 echo ""
 
-if [ $success_count -gt 0 ]; then
+if [ -n "$(ls -A "$OUTPUT_DIR" 2>/dev/null)" ]; then
     echo "Output files are in: $OUTPUT_DIR"
     ls -lh "$OUTPUT_DIR"
 fi
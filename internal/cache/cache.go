@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// New creates a cache in dir that evicts least recently used entries once
+// outputs exceed maxSize bytes (<= 0 means unlimited)
+func New(dir string, maxSize int64) *Cache {
+	return &Cache{dir: dir, maxSize: maxSize}
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// HashFile returns the hex SHA-256 of a file's content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Fingerprint identifies an executable or script by resolved path, size and
+// modification time, which change whenever the tool is upgraded
+func Fingerprint(binary string) string {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		path = binary
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return binary + "@missing"
+	}
+	return fmt.Sprintf("%s@%d-%d", path, info.Size(), info.ModTime().Unix())
+}
+
+// Hash returns the cache hash of a key
+func (k Key) Hash() string {
+	k.Version = keyVersion
+	data, _ := json.Marshal(k) // Map keys are sorted, so the encoding is stable
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// paths returns the output and entry file of a hash
+func (c *Cache) paths(hash string) (string, string) {
+	base := filepath.Join(c.dir, hash[:2], hash)
+	return base + outputSuffix, base + entrySuffix
+}
+
+// Get copies the cached output for hash to dest and marks the entry as used
+func (c *Cache) Get(hash, dest string) (*Entry, bool) {
+	outPath, entryPath := c.paths(hash)
+	entry, err := readEntry(entryPath)
+	if err != nil {
+		return nil, false
+	}
+
+	if err := CopyFile(outPath, dest); err != nil {
+		log.Debug().Err(err).Str("key", hash).Msg("Cached output unreadable, ignoring entry")
+		return nil, false
+	}
+
+	entry.LastUsed = time.Now()
+	entry.Hits++
+	if err := writeEntry(entryPath, entry); err != nil {
+		log.Debug().Err(err).Str("key", hash).Msg("Failed to update cache entry")
+	}
+	return entry, true
+}
+
+// Has reports whether an output is stored for hash, without marking it as used
+func (c *Cache) Has(hash string) bool {
+	outPath, entryPath := c.paths(hash)
+	if _, err := readEntry(entryPath); err != nil {
+		return false
+	}
+	_, err := os.Stat(outPath)
+	return err == nil
+}
+
+// Put stores a copy of src under hash, then evicts old entries beyond the size limit
+// route and meta are kept with the entry; meta is returned by later hits
+func (c *Cache) Put(hash, src, route string, meta map[string]string) error {
+	outPath, entryPath := c.paths(hash)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(outPath), hash+outputSuffix+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	tmp := tmpFile.Name()
+	tmpFile.Close()
+	if err := CopyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy output into cache: %w", err)
+	}
+	if err := os.Rename(tmp, outPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to store cached output: %w", err)
+	}
+
+	info, err := os.Stat(outPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := &Entry{
+		Key:      hash,
+		Size:     info.Size(),
+		Route:    route,
+		Meta:     meta,
+		Created:  now,
+		LastUsed: now,
+	}
+	if err := writeEntry(entryPath, entry); err != nil {
+		os.Remove(outPath)
+		return err
+	}
+
+	if c.maxSize > 0 {
+		if _, err := c.Prune(c.maxSize, 0); err != nil {
+			log.Warn().Err(err).Str("dir", c.dir).Msg("Failed to evict old cache entries")
+		}
+	}
+	return nil
+}
+
+// Stats summarises the cache contents
+func (c *Cache) Stats() (*Stats, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Dir: c.dir, Entries: len(entries), MaxSize: max(c.maxSize, 0)}
+	for _, entry := range entries {
+		stats.Size += entry.Size
+		stats.Hits += entry.Hits
+		if stats.Oldest.IsZero() || entry.LastUsed.Before(stats.Oldest) {
+			stats.Oldest = entry.LastUsed
+		}
+		if entry.LastUsed.After(stats.Newest) {
+			stats.Newest = entry.LastUsed
+		}
+	}
+	return stats, nil
+}
+
+// Prune removes entries unused for longer than olderThan (when > 0), then the
+// least recently used ones until outputs fit in maxSize bytes (when > 0)
+func (c *Cache) Prune(maxSize int64, olderThan time.Duration) (*PruneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.Before(entries[j].LastUsed) })
+
+	result := &PruneResult{}
+	for _, entry := range entries {
+		result.Size += entry.Size
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	for _, entry := range entries {
+		stale := olderThan > 0 && entry.LastUsed.Before(cutoff)
+		oversize := maxSize > 0 && result.Size > maxSize
+		if !stale && !oversize {
+			result.Remaining++
+			continue
+		}
+		if err := c.remove(entry.Key); err != nil {
+			return result, err
+		}
+		log.Debug().
+			Str("key", entry.Key).
+			Str("route", entry.Route).
+			Int64("size", entry.Size).
+			Time("last_used", entry.LastUsed).
+			Msg("Evicted cache entry")
+		result.Removed++
+		result.Freed += entry.Size
+		result.Size -= entry.Size
+	}
+	return result, nil
+}
+
+// Clear removes every cache entry, leaving unrelated files in the directory alone
+func (c *Cache) Clear() (*PruneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := &PruneResult{}
+	dirs, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || !isShard(dir.Name()) {
+			continue
+		}
+		shard := filepath.Join(c.dir, dir.Name())
+		files, err := os.ReadDir(shard)
+		if err != nil {
+			return result, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, file := range files {
+			name := file.Name()
+			// Outputs, records and their temporary files
+			if !strings.Contains(name, outputSuffix) && !strings.Contains(name, entrySuffix) {
+				continue
+			}
+			if strings.HasSuffix(name, outputSuffix) {
+				if info, err := file.Info(); err == nil {
+					result.Freed += info.Size()
+				}
+				result.Removed++
+			}
+			if err := os.Remove(filepath.Join(shard, name)); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove cache file: %w", err)
+			}
+		}
+		os.Remove(shard) // Only succeeds when empty
+	}
+	return result, nil
+}
+
+// entries reads every entry record, dropping records whose output is gone
+func (c *Cache) entries() ([]*Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "??", "*"+entrySuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if err != nil {
+			continue // Being written, or not ours
+		}
+		outPath, _ := c.paths(entry.Key)
+		if _, err := os.Stat(outPath); err != nil {
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// remove deletes an entry's output and record
+func (c *Cache) remove(hash string) error {
+	outPath, entryPath := c.paths(hash)
+	for _, path := range []string{entryPath, outPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// isShard reports whether a directory name is a two-hex-digit entry shard
+func isShard(name string) bool {
+	_, err := hex.DecodeString(name)
+	return len(name) == 2 && err == nil
+}
+
+// readEntry loads an entry record
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if len(entry.Key) < 2 {
+		return nil, fmt.Errorf("cache entry %s has no key", path)
+	}
+	return &entry, nil
+}
+
+// writeEntry saves an entry record atomically
+func writeEntry(path string, entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + fmt.Sprintf(".tmp-%d-%d", os.Getpid(), time.Now().UnixNano())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// CopyFile copies src to dst, replacing dst
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestKeyHash(t *testing.T) {
+	key := Key{Source: "abc", From: "docx", To: "md", Mode: "normal", Route: []string{"pandoc@1"},
+		Options: map[string]string{"quality": "fast", "dpi": "300"}}
+	same := Key{Source: "abc", From: "docx", To: "md", Mode: "normal", Route: []string{"pandoc@1"},
+		Options: map[string]string{"dpi": "300", "quality": "fast"}}
+	if key.Hash() != same.Hash() {
+		t.Error("equal keys must hash equally")
+	}
+
+	upgraded := same
+	upgraded.Route = []string{"pandoc@2"}
+	if key.Hash() == upgraded.Hash() {
+		t.Error("a different tool version must change the hash")
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"), 0)
+	src := filepath.Join(dir, "out.md")
+	writeFile(t, src, "# converted")
+	hash := Key{Source: "abc"}.Hash()
+
+	dest := filepath.Join(dir, "copy.md")
+	if _, ok := c.Get(hash, dest); ok || c.Has(hash) {
+		t.Fatal("empty cache returned a hit")
+	}
+
+	if err := c.Put(hash, src, "docx→md (pandoc)", map[string]string{"ocr": "false"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !c.Has(hash) {
+		t.Error("Has = false after Put")
+	}
+	entry, ok := c.Get(hash, dest)
+	if !ok {
+		t.Fatal("expected a hit after Put")
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "# converted" {
+		t.Errorf("cached content = %q", data)
+	}
+	if entry.Hits != 1 || entry.Meta["ocr"] != "false" || entry.Route != "docx→md (pandoc)" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 1 || stats.Size != int64(len("# converted")) || stats.Hits != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"), 25)
+	src := filepath.Join(dir, "out.txt")
+	writeFile(t, src, "0123456789") // 10 bytes per entry
+
+	hashes := []string{Key{Source: "a"}.Hash(), Key{Source: "b"}.Hash(), Key{Source: "c"}.Hash()}
+	for i, hash := range hashes[:2] {
+		if err := c.Put(hash, src, "", nil); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Touch the first entry so the second becomes least recently used
+	if _, ok := c.Get(hashes[0], filepath.Join(dir, "copy.txt")); !ok {
+		t.Fatal("expected a hit")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Put(hashes[2], src, "", nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for i, want := range []bool{true, false, true} {
+		if _, ok := c.Get(hashes[i], filepath.Join(dir, "copy.txt")); ok != want {
+			t.Errorf("entry %d cached = %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestCachePruneAndClear(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	c := New(cacheDir, 0)
+	src := filepath.Join(dir, "out.txt")
+	writeFile(t, src, "0123456789")
+
+	for _, source := range []string{"a", "b", "c"} {
+		if err := c.Put(Key{Source: source}.Hash(), src, "", nil); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	result, err := c.Prune(20, 0)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if result.Removed != 1 || result.Remaining != 2 || result.Size != 20 {
+		t.Errorf("prune = %+v", result)
+	}
+
+	unrelated := filepath.Join(cacheDir, "notes.txt")
+	writeFile(t, unrelated, "keep me")
+	result, err = c.Clear()
+	if err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if result.Removed != 2 || result.Freed != 20 {
+		t.Errorf("clear = %+v", result)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Error("Clear removed a file it does not own")
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("entries after clear = %d", stats.Entries)
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// keyVersion is mixed into every key; bump it when the key layout changes
+const keyVersion = 1
+
+// File name suffixes of a cache entry
+const (
+	outputSuffix = ".out"  // Cached conversion output
+	entrySuffix  = ".json" // Entry metadata (Entry)
+)
+
+// Cache stores conversion outputs on disk, keyed by Key.Hash
+// Entries live in <dir>/<hash[:2]>/<hash>.out with a <hash>.json record, and
+// the least recently used ones are evicted when the total size exceeds maxSize
+type Cache struct {
+	dir     string
+	maxSize int64 // Bytes; <= 0 means unlimited
+
+	mu sync.Mutex // Serialises eviction within this process
+}
+
+// Key identifies a conversion result: the same source converted the same way
+// by the same tools gives the same output
+type Key struct {
+	Version int               `json:"version"` // keyVersion
+	App     string            `json:"app"`     // yakateka version (built-in converters)
+	Source  string            `json:"source"`  // SHA-256 of the input file
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Mode    string            `json:"mode"`
+	Route   []string          `json:"route"`             // Converter/helper identity and version per step
+	Options map[string]string `json:"options,omitempty"` // Options affecting the output
+}
+
+// Entry describes a cached output
+type Entry struct {
+	Key      string            `json:"key" yaml:"key"`
+	Size     int64             `json:"size" yaml:"size"`
+	Route    string            `json:"route,omitempty" yaml:"route,omitempty"` // Human-readable route
+	Meta     map[string]string `json:"meta,omitempty" yaml:"meta,omitempty"`   // Caller data restored on hits
+	Created  time.Time         `json:"created" yaml:"created"`
+	LastUsed time.Time         `json:"last_used" yaml:"last_used"`
+	Hits     int               `json:"hits" yaml:"hits"`
+}
+
+// Stats summarises the cache contents
+type Stats struct {
+	Dir     string    `json:"dir" yaml:"dir"`
+	Entries int       `json:"entries" yaml:"entries"`
+	Size    int64     `json:"size" yaml:"size"`
+	MaxSize int64     `json:"max_size" yaml:"max_size"` // 0 = unlimited
+	Hits    int       `json:"hits" yaml:"hits"`         // Total reuses of current entries
+	Oldest  time.Time `json:"oldest,omitempty" yaml:"oldest,omitempty"`
+	Newest  time.Time `json:"newest,omitempty" yaml:"newest,omitempty"`
+}
+
+// PruneResult reports what Prune or Clear removed
+type PruneResult struct {
+	Removed   int   `json:"removed" yaml:"removed"`
+	Freed     int64 `json:"freed" yaml:"freed"`
+	Remaining int   `json:"remaining" yaml:"remaining"`
+	Size      int64 `json:"size" yaml:"size"`
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+)
+
+// pipelineArtifacts reuses and stores the intermediate outputs of one pipeline run
+// Artifacts are cache entries keyed like final outputs: source content, the steps
+// that produced them and the options, so any pipeline sharing a prefix can reuse them
+type pipelineArtifacts struct {
+	factory *Factory
+	source  string // SHA-256 of the pipeline input; empty disables reuse
+	opts    internal.ConversionOptions
+}
+
+// SetKeepIntermediates copies every intermediate pipeline artifact into dir
+// (as <input name>.<step>.<format>) for debugging; empty disables it
+func (f *Factory) SetKeepIntermediates(dir string) {
+	f.keepDir = dir
+}
+
+// newArtifacts prepares artifact reuse for a pipeline run on input
+func (f *Factory) newArtifacts(input string, opts internal.ConversionOptions) *pipelineArtifacts {
+	a := &pipelineArtifacts{factory: f, opts: opts}
+	if f.cache == nil {
+		return a
+	}
+	source, err := cache.HashFile(input)
+	if err != nil {
+		log.Debug().Err(err).Str("input", input).Msg("Intermediate artifacts will not be reused")
+		return a
+	}
+	a.source = source
+	return a
+}
+
+// hash returns the store key of the output of a pipeline prefix
+func (a *pipelineArtifacts) hash(prefix []ConversionStep) string {
+	return a.factory.artifactKey(a.source, prefix, a.opts).Hash()
+}
+
+// resume finds the longest stored prefix of pipeline, excluding the final step,
+// and copies its output to a temp file; it returns the prefix length and the file
+func (a *pipelineArtifacts) resume(pipeline []ConversionStep) (int, string, bool) {
+	if a.source == "" {
+		return 0, "", false
+	}
+	for n := len(pipeline) - 1; n > 0; n-- {
+		path, err := newTempFile(pipeline[n-1].ToFormat)
+		if err != nil {
+			return 0, "", false
+		}
+		if _, ok := a.factory.cache.Get(a.hash(pipeline[:n]), path); ok {
+			log.Info().
+				Str("route", routeKey(pipeline[:n])).
+				Int("steps", n).
+				Msg("Reusing stored intermediate artifact")
+			return n, path, true
+		}
+		os.Remove(path)
+	}
+	return 0, "", false
+}
+
+// stored reports whether the output of a pipeline prefix is already stored
+func (a *pipelineArtifacts) stored(prefix []ConversionStep) bool {
+	return a.source != "" && a.factory.cache.Has(a.hash(prefix))
+}
+
+// store saves the output of a pipeline prefix for later runs
+func (a *pipelineArtifacts) store(prefix []ConversionStep, path string) {
+	if a.source == "" {
+		return
+	}
+	if err := a.factory.cache.Put(a.hash(prefix), path, routeKey(prefix), nil); err != nil {
+		log.Warn().Err(err).Str("route", routeKey(prefix)).Msg("Failed to store intermediate artifact")
+	}
+}
+
+// keepReused copies the stored outputs of a reused prefix to the keep directory
+func (a *pipelineArtifacts) keepReused(input string, prefix []ConversionStep) {
+	f := a.factory
+	if f.keepDir == "" {
+		return
+	}
+	for i := range prefix {
+		dest, err := f.intermediatePath(input, i, prefix[i])
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to keep intermediate artifact")
+			return
+		}
+		if _, ok := f.cache.Get(a.hash(prefix[:i+1]), dest); !ok {
+			log.Debug().Str("route", routeKey(prefix[:i+1])).Msg("Intermediate artifact no longer stored")
+		}
+	}
+}
+
+// keepIntermediate copies the output of pipeline step i to the keep directory
+func (f *Factory) keepIntermediate(input string, i int, step ConversionStep, path string) {
+	if f.keepDir == "" {
+		return
+	}
+	dest, err := f.intermediatePath(input, i, step)
+	if err == nil {
+		err = cache.CopyFile(path, dest)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("dir", f.keepDir).Msg("Failed to keep intermediate artifact")
+		return
+	}
+	log.Info().Str("file", dest).Int("step", i+1).Msg("Kept intermediate artifact")
+}
+
+// intermediatePath names the kept output of pipeline step i: report.1.html
+func (f *Factory) intermediatePath(input string, i int, step ConversionStep) (string, error) {
+	if err := os.MkdirAll(f.keepDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create intermediates directory: %w", err)
+	}
+	base := filepath.Base(input)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	return filepath.Join(f.keepDir, fmt.Sprintf("%s.%d.%s", stem, i+1, step.ToFormat)), nil
+}
+
+// newTempFile creates an empty temp file for a pipeline artifact
+func newTempFile(format internal.DocumentFormat) (string, error) {
+	tempFile, err := os.CreateTemp("", fmt.Sprintf("yakateka-pipeline-*.%s", format))
+	if err != nil {
+		return "", err
+	}
+	tempFile.Close()
+	return tempFile.Name(), nil
+}
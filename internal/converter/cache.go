@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+	"github.com/valpere/yakateka/internal/helper"
+)
+
+// Entry metadata keys used to restore a ConversionReport on cache hits
+const (
+	metaOCR         = "ocr"
+	metaOCRFallback = "ocr_fallback"
+)
+
+// SetCache enables reusing the outputs of identical conversions
+// version identifies the built-in converters (plaintext, OCR glue) in cache keys
+func (f *Factory) SetCache(c *cache.Cache, version string) {
+	f.cache = c
+	f.version = version
+}
+
+// convertCached serves a conversion from the output cache when an identical one
+// ran before and stores fresh outputs; cache problems never fail a conversion
+func (f *Factory) convertCached(ctx context.Context, input, output string, opts internal.ConversionOptions, report *ConversionReport) error {
+	if f.cache == nil {
+		return f.convert(ctx, input, output, opts, report)
+	}
+
+	key, route, err := f.cacheKey(input, opts)
+	if err != nil {
+		log.Debug().Err(err).Str("input", input).Msg("Conversion is not cacheable")
+		return f.convert(ctx, input, output, opts, report)
+	}
+	hash := key.Hash()
+
+	if entry, ok := f.cache.Get(hash, output); ok {
+		report.Cached = true
+		report.OCR = opts.OCR || entry.Meta[metaOCR] == "true"
+		report.OCRFallback = entry.Meta[metaOCRFallback] == "true"
+		log.Info().
+			Str("input", input).
+			Str("output", output).
+			Str("route", route).
+			Int("hits", entry.Hits).
+			Msg("Reused cached conversion output")
+		return nil
+	}
+
+	if err := f.convert(ctx, input, output, opts, report); err != nil {
+		return err
+	}
+
+	meta := map[string]string{
+		metaOCR:         strconv.FormatBool(report.OCR),
+		metaOCRFallback: strconv.FormatBool(report.OCRFallback),
+	}
+	if err := f.cache.Put(hash, output, route, meta); err != nil {
+		log.Warn().Err(err).Str("output", output).Msg("Failed to cache conversion output")
+	}
+	return nil
+}
+
+// cacheKey identifies a conversion by source content, formats, mode, the tools on
+// its planned route and the options that change the output
+// Fallback converters are not part of the key: a fallback's output is stored
+// under the route that was planned
+func (f *Factory) cacheKey(input string, opts internal.ConversionOptions) (cache.Key, string, error) {
+	source, err := cache.HashFile(input)
+	if err != nil {
+		return cache.Key{}, "", err
+	}
+
+	steps, err := f.plannedRoute(opts)
+	if err != nil {
+		return cache.Key{}, "", err
+	}
+
+	key := f.artifactKey(source, steps, opts)
+	if !opts.OCR && opts.OCRFallback && isTextFormat(opts.OutputFormat) && f.ocr != nil {
+		// Empty text may be replaced by OCR output
+		key.Options["ocr_fallback"] = f.fingerprint(f.ocrStep(opts.InputFormat), helper.ModeFromQuality(opts.Quality))
+		key.Options["ocr_languages"] = strings.Join(opts.OCRLanguages, ",")
+	}
+	return key, routeKey(steps), nil
+}
+
+// artifactKey identifies the output of running steps on a source (by content hash)
+// with opts; final outputs and intermediate pipeline artifacts share this scheme
+func (f *Factory) artifactKey(source string, steps []ConversionStep, opts internal.ConversionOptions) cache.Key {
+	mode := helper.ModeFromQuality(opts.Quality)
+	key := cache.Key{
+		App:     f.version,
+		Source:  source,
+		From:    string(steps[0].FromFormat),
+		To:      string(steps[len(steps)-1].ToFormat),
+		Mode:    string(mode),
+		Options: make(map[string]string),
+	}
+
+	for _, step := range steps {
+		key.Route = append(key.Route, f.fingerprint(step, mode))
+		if step.Name == ocrConverterName {
+			key.Options["ocr_languages"] = strings.Join(opts.OCRLanguages, ",")
+		}
+	}
+	if opts.Quality != "" {
+		key.Options["quality"] = opts.Quality
+	}
+	if opts.DPI > 0 {
+		key.Options["dpi"] = strconv.Itoa(opts.DPI)
+	}
+	if opts.Pages != "" {
+		key.Options["pages"] = opts.Pages
+	}
+	for name, value := range opts.Extra {
+		key.Options["extra."+name] = value
+	}
+	return key
+}
+
+// fingerprint identifies the tool behind a step; converters that cannot tell are
+// identified by name and the application version
+func (f *Factory) fingerprint(step ConversionStep, mode helper.ConversionMode) string {
+	if fp, ok := step.Converter.(internal.Fingerprinter); ok {
+		return fp.Fingerprint(step.FromFormat, step.ToFormat, string(mode))
+	}
+	return step.Name + "@" + f.version
+}
@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+)
+
+// countingConverter writes fixed content and counts its runs
+type countingConverter struct {
+	writingConverter
+	runs int
+}
+
+func (c *countingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	c.runs++
+	return c.writingConverter.Convert(ctx, input, output, opts)
+}
+
+func TestFactoryConvertCached(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "report.docx")
+	if err := os.WriteFile(input, []byte("source"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	conv := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{
+			inputFormats:  formats(internal.FormatDOCX),
+			outputFormats: formats(internal.FormatMD),
+		},
+		content: "# Report",
+	}}
+	factory := NewFactory()
+	factory.Register("pandoc", conv)
+	factory.SetCache(cache.New(filepath.Join(dir, "cache"), 0), "test")
+
+	opts := internal.ConversionOptions{InputFormat: internal.FormatDOCX, OutputFormat: internal.FormatMD}
+	convert := func(output string, opts internal.ConversionOptions) *ConversionReport {
+		t.Helper()
+		report, err := factory.ConvertWithReport(context.Background(), input, filepath.Join(dir, output), opts)
+		if err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+		return report
+	}
+
+	if report := convert("first.md", opts); report.Cached || conv.runs != 1 {
+		t.Fatalf("first conversion: cached=%v runs=%d", report.Cached, conv.runs)
+	}
+	if report := convert("second.md", opts); !report.Cached || conv.runs != 1 {
+		t.Errorf("repeat conversion: cached=%v runs=%d, want a cache hit", report.Cached, conv.runs)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "second.md")); string(data) != "# Report" {
+		t.Errorf("cached output = %q", data)
+	}
+
+	// Options that change the output miss the cache
+	fast := opts
+	fast.Quality = "fast"
+	if report := convert("fast.md", fast); report.Cached || conv.runs != 2 {
+		t.Errorf("--quality fast: cached=%v runs=%d, want a miss", report.Cached, conv.runs)
+	}
+
+	// So does a changed source
+	if err := os.WriteFile(input, []byte("edited"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite input: %v", err)
+	}
+	if report := convert("edited.md", opts); report.Cached || conv.runs != 3 {
+		t.Errorf("edited source: cached=%v runs=%d, want a miss", report.Cached, conv.runs)
+	}
+}
+
+func TestPipelineReusesIntermediates(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.djvu")
+	if err := os.WriteFile(input, []byte("scan"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	djvups := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatDJVU), outputFormats: formats(internal.FormatPS)},
+		content:       "%!PS",
+	}}
+	ps2pdf := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatPS), outputFormats: formats(internal.FormatPDF)},
+		content:       "%PDF",
+	}}
+	calibre := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatPDF), outputFormats: formats(internal.FormatEPUB)},
+		content:       "PK",
+	}}
+	factory := NewFactory()
+	factory.Register("djvups", djvups)
+	factory.Register("ps2pdf", ps2pdf)
+	factory.Register("calibre", calibre)
+	factory.SetCache(cache.New(filepath.Join(dir, "cache"), 0), "test")
+	keep := filepath.Join(dir, "keep")
+	factory.SetKeepIntermediates(keep)
+
+	convert := func(to internal.DocumentFormat) {
+		t.Helper()
+		opts := internal.ConversionOptions{InputFormat: internal.FormatDJVU, OutputFormat: to}
+		output := filepath.Join(dir, "book."+string(to))
+		if err := factory.Convert(context.Background(), input, output, opts); err != nil {
+			t.Fatalf("Convert to %s failed: %v", to, err)
+		}
+	}
+
+	convert(internal.FormatPDF)
+	convert(internal.FormatEPUB)
+	if djvups.runs != 1 || ps2pdf.runs != 1 || calibre.runs != 1 {
+		t.Errorf("runs: djvups=%d ps2pdf=%d calibre=%d, want the djvu → ps → pdf prefix shared",
+			djvups.runs, ps2pdf.runs, calibre.runs)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "book.epub")); string(data) != "PK" {
+		t.Errorf("epub output = %q", data)
+	}
+
+	for name, want := range map[string]string{"book.1.ps": "%!PS", "book.2.pdf": "%PDF"} {
+		if data, err := os.ReadFile(filepath.Join(keep, name)); err != nil || string(data) != want {
+			t.Errorf("kept %s = %q (%v), want %q", name, data, err, want)
+		}
+	}
+}
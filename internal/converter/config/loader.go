@@ -55,6 +55,11 @@ func Validate(cfg *ConverterConfig) error {
 			return fmt.Errorf("converter %s has empty binary", name)
 		}
 
+		// Concurrency cap cannot be negative (0 = unlimited)
+		if tool.MaxConcurrent < 0 {
+			return fmt.Errorf("converter %s has negative max_concurrent: %d", name, tool.MaxConcurrent)
+		}
+
 		// Must have at least one input and output format
 		if len(tool.Formats.Input) == 0 {
 			return fmt.Errorf("converter %s has no input formats", name)
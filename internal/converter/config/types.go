@@ -18,6 +18,9 @@ type ToolConfig struct {
 	Profile             string                        `mapstructure:"profile" yaml:"profile"`
 	CommandTemplate     string                        `mapstructure:"command_template" yaml:"command_template"` // Override profile
 	Timeout             int                           `mapstructure:"timeout" yaml:"timeout"`
+	MaxConcurrent       int                           `mapstructure:"max_concurrent" yaml:"max_concurrent"` // 0 = unlimited
+	Priority            int                           `mapstructure:"priority" yaml:"priority"`             // Higher is tried first for a format pair
+	Stream              bool                          `mapstructure:"stream" yaml:"stream"`                 // Reads stdin for {input} "-" and writes stdout for {output} "-"
 	Formats             FormatConfig                  `mapstructure:"formats" yaml:"formats"`
 	FormatMapping       map[string]string             `mapstructure:"format_mapping" yaml:"format_mapping"`
 	ConversionOverrides map[string]ConversionOverride `mapstructure:"conversion_overrides" yaml:"conversion_overrides"`
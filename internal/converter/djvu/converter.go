@@ -77,8 +77,8 @@ func (c *Converter) convertToText(ctx context.Context, input, output string) err
 			Str("input", input).
 			Str("output", output).
 			Msg("DjVu text extraction produced empty output - file may not have embedded text layer (requires OCR)")
-		// Still return success, as djvutxt completed without error
-		// Future: Could trigger OCR pipeline here
+		// Still return success, as djvutxt completed without error;
+		// the factory re-runs empty text results through OCR when configured
 	} else {
 		log.Info().
 			Str("output", output).
@@ -1,8 +1,10 @@
 package generic
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,9 +13,20 @@ import (
 	"github.com/google/shlex"
 	"github.com/rs/zerolog/log"
 	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
 	"github.com/valpere/yakateka/internal/converter/config"
 )
 
+// qualityAliases maps conversion modes to the quality keys used in conversion_overrides
+var qualityAliases = map[string]string{
+	"fast":    "low",
+	"normal":  "medium",
+	"quality": "high",
+}
+
+// streamPath stands for stdin as {input} and stdout as {output} in stream mode
+const streamPath = "-"
+
 // Converter is a generic converter that executes commands based on configuration
 type Converter struct {
 	name     string
@@ -48,6 +61,13 @@ func (c *Converter) SupportedOutputFormats() []internal.DocumentFormat {
 	return formats
 }
 
+// Fingerprint identifies the tool binary and the command it would run
+// Implements internal.Fingerprinter
+func (c *Converter) Fingerprint(from, to internal.DocumentFormat, mode string) string {
+	return fmt.Sprintf("%s|%s|%s|%+v", c.name, cache.Fingerprint(c.config.Binary),
+		c.config.GetCommandTemplate(c.profiles), c.config.GetConversionOverride(string(from), string(to)))
+}
+
 // Convert performs document conversion using configured command
 func (c *Converter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
 	// Validate input file exists
@@ -73,8 +93,21 @@ func (c *Converter) Convert(ctx context.Context, input, output string, opts inte
 		return fmt.Errorf("failed to get absolute output path: %w", err)
 	}
 
+	// Tools that name outputs after the input (LibreOffice) write into a private
+	// scratch directory next to the output, removed whatever happens, so neither
+	// a failure nor a timeout leaves their files behind
+	workOutput := absOutput
+	if c.config.GetPostProcess(c.profiles) == "rename_from_basename" {
+		scratch, err := os.MkdirTemp(filepath.Dir(absOutput), ".yakateka-"+c.name+"-*")
+		if err != nil {
+			return fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		defer os.RemoveAll(scratch)
+		workOutput = filepath.Join(scratch, filepath.Base(absOutput))
+	}
+
 	// Build command
-	cmdStr, err := c.buildCommand(absInput, absOutput, opts)
+	cmdStr, err := c.buildCommand(absInput, workOutput, opts)
 	if err != nil {
 		return fmt.Errorf("failed to build command: %w", err)
 	}
@@ -101,9 +134,14 @@ func (c *Converter) Convert(ctx context.Context, input, output string, opts inte
 	}
 
 	// Post-process if needed
-	if err := c.postProcess(absInput, absOutput); err != nil {
+	if err := c.postProcess(absInput, workOutput); err != nil {
 		return fmt.Errorf("post-processing failed: %w", err)
 	}
+	if workOutput != absOutput {
+		if err := moveFromScratch(workOutput, absOutput); err != nil {
+			return fmt.Errorf("post-processing failed: %w", err)
+		}
+	}
 
 	// Verify output file was created
 	if _, err := os.Stat(absOutput); os.IsNotExist(err) {
@@ -128,6 +166,61 @@ func (c *Converter) Convert(ctx context.Context, input, output string, opts inte
 	return nil
 }
 
+// CanStream reports whether a conversion can run on streams: the tool is marked
+// stream: true (it reads stdin and writes stdout when given "-") and needs no
+// post-processing of its output file
+// Implements internal.StreamConverter
+func (c *Converter) CanStream(from, to internal.DocumentFormat) bool {
+	return c.config.Stream &&
+		c.config.SupportsConversion(string(from), string(to)) &&
+		c.config.GetPostProcess(c.profiles) == ""
+}
+
+// ConvertStream converts the document read from r, writing the result to w
+func (c *Converter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts internal.ConversionOptions) error {
+	if !c.CanStream(opts.InputFormat, opts.OutputFormat) {
+		return fmt.Errorf("%w: %s cannot stream %s -> %s",
+			internal.ErrUnsupportedConversion, c.name, opts.InputFormat, opts.OutputFormat)
+	}
+
+	cmdStr, err := c.buildCommand(streamPath, streamPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to build command: %w", err)
+	}
+
+	log.Info().
+		Str("converter", c.name).
+		Str("from", string(opts.InputFormat)).
+		Str("to", string(opts.OutputFormat)).
+		Str("command", cmdStr).
+		Msg("Converting document stream with generic converter")
+
+	cmd, err := c.newCommand(ctx, cmdStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s conversion failed: %v", internal.ErrConversionFailed, c.name, err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		log.Error().
+			Err(err).
+			Str("converter", c.name).
+			Str("output", stderr.String()).
+			Msg("Conversion failed")
+		return fmt.Errorf("%w: %s conversion failed: %v - %s",
+			internal.ErrConversionFailed, c.name, err, stderr.String())
+	}
+
+	log.Info().
+		Str("converter", c.name).
+		Str("conversion", fmt.Sprintf("%s → %s", opts.InputFormat, opts.OutputFormat)).
+		Msg("Successfully converted document stream")
+
+	return nil
+}
+
 // buildCommand constructs the command string from template
 func (c *Converter) buildCommand(input, output string, opts internal.ConversionOptions) (string, error) {
 	template := c.config.GetCommandTemplate(c.profiles)
@@ -161,7 +254,12 @@ func (c *Converter) buildCommand(input, output string, opts internal.ConversionO
 
 		// Add quality flags if specified
 		if opts.Quality != "" {
-			if qualityFlags, ok := override.Quality[opts.Quality]; ok {
+			qualityFlags, ok := override.Quality[opts.Quality]
+			if !ok {
+				// --quality fast|normal|quality selects the low|medium|high flags
+				qualityFlags, ok = override.Quality[qualityAliases[opts.Quality]]
+			}
+			if ok {
 				if replacements["{extra_args}"] != "" {
 					replacements["{extra_args}"] += " " + qualityFlags
 				} else {
@@ -223,8 +321,17 @@ func (c *Converter) validateBinaryPath(binaryPath string) error {
 }
 
 // executeCommand executes the command string
-// Uses shlex to properly handle quoted arguments like --option="value with spaces"
 func (c *Converter) executeCommand(ctx context.Context, cmdStr string) ([]byte, error) {
+	cmd, err := c.newCommand(ctx, cmdStr)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.CombinedOutput()
+}
+
+// newCommand prepares the command string for execution
+// Uses shlex to properly handle quoted arguments like --option="value with spaces"
+func (c *Converter) newCommand(ctx context.Context, cmdStr string) (*exec.Cmd, error) {
 	// Split command into parts using shell word splitting
 	// This properly handles quoted strings and escaped characters
 	parts, err := shlex.Split(cmdStr)
@@ -241,8 +348,7 @@ func (c *Converter) executeCommand(ctx context.Context, cmdStr string) ([]byte,
 		return nil, fmt.Errorf("binary validation failed: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
-	return cmd.CombinedOutput()
+	return exec.CommandContext(ctx, parts[0], parts[1:]...), nil
 }
 
 // postProcess handles post-conversion processing (e.g., file renaming)
@@ -269,3 +375,32 @@ func (c *Converter) postProcess(input, output string) error {
 
 	return nil
 }
+
+// moveFromScratch moves a finished output out of its scratch directory, along
+// with companion files the tool wrote next to it (images of an HTML export)
+// Companions never replace existing files
+func moveFromScratch(workOutput, output string) error {
+	if _, err := os.Stat(workOutput); os.IsNotExist(err) {
+		return nil // Reported as a missing output by the caller
+	}
+	if err := os.Rename(workOutput, output); err != nil {
+		return fmt.Errorf("failed to move output: %w", err)
+	}
+
+	scratch, dir := filepath.Dir(workOutput), filepath.Dir(output)
+	entries, err := os.ReadDir(scratch)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		dest := filepath.Join(dir, entry.Name())
+		if _, err := os.Lstat(dest); err == nil {
+			log.Warn().Str("file", dest).Msg("Companion output file already exists, not replacing it")
+			continue
+		}
+		if err := os.Rename(filepath.Join(scratch, entry.Name()), dest); err != nil {
+			log.Warn().Err(err).Str("file", dest).Msg("Failed to keep companion output file")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+//go:build unix || linux || darwin
+
+package generic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/converter/config"
+)
+
+// fakeOffice writes a script that behaves like soffice --convert-to: the output
+// is named after the input in --outdir, with an image next to it
+func fakeOffice(t *testing.T, dir, body string) string {
+	t.Helper()
+	script := filepath.Join(dir, "soffice")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("Failed to write fake soffice: %v", err)
+	}
+	return script
+}
+
+func officeConverter(binary string) *Converter {
+	profiles := map[string]config.ProfileConfig{
+		"libreoffice_style": {
+			CommandTemplate: "{binary} {outdir} {input}",
+			PostProcess:     "rename_from_basename",
+		},
+	}
+	return NewConverter("libreoffice", config.ToolConfig{
+		Binary:  binary,
+		Profile: "libreoffice_style",
+		Formats: config.FormatConfig{Input: []string{"docx"}, Output: []string{"html"}},
+	}, profiles)
+}
+
+func TestConvertRenameFromBasename(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "report.docx")
+	if err := os.WriteFile(input, []byte("docx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := internal.ConversionOptions{InputFormat: internal.FormatDOCX, OutputFormat: internal.FormatHTML}
+
+	// The output is renamed and its companion image kept; the scratch directory goes
+	conv := officeConverter(fakeOffice(t, dir, `echo '<img src="report_html_1.png">' > "$1/report.html"; echo png > "$1/report_html_1.png"`+"\n"))
+	output := filepath.Join(out, "final.html")
+	if err := conv.Convert(context.Background(), input, output, opts); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	entries, _ := os.ReadDir(out)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 || names[0] != "final.html" || names[1] != "report_html_1.png" {
+		t.Errorf("output directory = %v, want final.html and report_html_1.png", names)
+	}
+
+	// A failing run leaves nothing behind, not even the partial output
+	conv = officeConverter(fakeOffice(t, dir, `echo partial > "$1/report.html"; exit 1`+"\n"))
+	if err := conv.Convert(context.Background(), input, filepath.Join(out, "failed.html"), opts); err == nil {
+		t.Fatal("expected the failing tool to fail the conversion")
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 2 {
+		t.Errorf("failed conversion left files behind: %v", entries)
+	}
+}
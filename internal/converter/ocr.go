@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+)
+
+// ocrConverterName is the limiter key and step name used for OCR conversions
+const ocrConverterName = "ocr"
+
+// minTextLetters is the letter count below which extracted text counts as empty
+// Scans without a text layer typically yield nothing but page numbers and form feeds
+const minTextLetters = 16
+
+// ocrConverter adapts an OCR engine to the Converter interface (image/scan → txt)
+type ocrConverter struct {
+	engine internal.OCREngine
+}
+
+// Convert recognises the input and writes plain text to output
+func (c *ocrConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	text, err := c.engine.ExtractText(ctx, input, internal.ExtractionOptions{
+		OCR:          true,
+		OCRLanguages: opts.OCRLanguages,
+		DPI:          opts.DPI,
+		ExtractType:  "text",
+		Format:       string(internal.FormatTXT),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(output, []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	log.Info().
+		Str("input", input).
+		Str("output", output).
+		Int("bytes", len(text)).
+		Msg("Successfully extracted text with OCR")
+	return nil
+}
+
+// Fingerprint identifies the OCR engine, when it can
+// Implements internal.Fingerprinter
+func (c *ocrConverter) Fingerprint(from, to internal.DocumentFormat, mode string) string {
+	if f, ok := c.engine.(internal.Fingerprinter); ok {
+		return f.Fingerprint(from, to, mode)
+	}
+	return ocrConverterName
+}
+
+// SupportedInputFormats returns formats the engine can OCR, when it reports them
+func (c *ocrConverter) SupportedInputFormats() []internal.DocumentFormat {
+	if f, ok := c.engine.(interface {
+		SupportedInputFormats() []internal.DocumentFormat
+	}); ok {
+		return f.SupportedInputFormats()
+	}
+	return nil
+}
+
+// SupportedOutputFormats returns plain text, the only direct OCR output
+func (c *ocrConverter) SupportedOutputFormats() []internal.DocumentFormat {
+	return []internal.DocumentFormat{internal.FormatTXT}
+}
+
+// supports reports whether the engine can OCR the given input format
+func (c *ocrConverter) supports(format internal.DocumentFormat) bool {
+	return slices.Contains(c.SupportedInputFormats(), format)
+}
+
+// SetOCREngine enables OCR for conversions with opts.OCR set
+func (f *Factory) SetOCREngine(engine internal.OCREngine) {
+	if engine == nil {
+		f.ocr = nil
+		return
+	}
+	f.ocr = &ocrConverter{engine: engine}
+}
+
+// convertWithOCR recognises the input to text, then converts the text to the
+// requested format when it is not TXT
+// The recognised text is a pipeline artifact like any other, so it is stored and
+// reused when the cache is enabled
+func (f *Factory) convertWithOCR(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	pipeline, err := f.plannedRoute(opts)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().
+		Str("from", string(opts.InputFormat)).
+		Str("to", string(opts.OutputFormat)).
+		Str("route", routeKey(pipeline)).
+		Msg("Using OCR conversion")
+
+	if len(pipeline) == 1 {
+		return f.runConverter(ctx, ocrConverterName, f.ocr, input, output, opts)
+	}
+	return f.executePipeline(ctx, input, output, opts, pipeline)
+}
+
+// ocrStep is the recognition step of an OCR route
+func (f *Factory) ocrStep(from internal.DocumentFormat) ConversionStep {
+	return ConversionStep{FromFormat: from, ToFormat: internal.FormatTXT, Name: ocrConverterName, Converter: f.ocr}
+}
+
+// ocrFallback re-runs a text conversion through OCR when it produced (almost) no text
+// and the OCR engine can read the input; other inputs keep their output, as short
+// documents are legitimately short. When OCR fails or finds no text either,
+// ErrNoTextExtracted is returned
+func (f *Factory) ocrFallback(ctx context.Context, input, output string, opts internal.ConversionOptions, report *ConversionReport) error {
+	if f.ocr == nil || !f.ocr.supports(opts.InputFormat) {
+		return nil
+	}
+
+	letters, err := countLetters(output)
+	if err != nil {
+		log.Debug().Err(err).Str("output", output).Msg("Cannot inspect output for OCR fallback")
+		return nil
+	}
+	if letters >= minTextLetters {
+		return nil
+	}
+
+	log.Warn().
+		Str("input", input).
+		Str("output", output).
+		Int("letters", letters).
+		Msg("Text extraction produced no text, document may be scanned")
+
+	tempFile, err := os.CreateTemp(filepath.Dir(output), ".yakateka-ocr-*."+string(opts.OutputFormat))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for OCR output: %w", err)
+	}
+	ocrOutput := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(ocrOutput) // No-op after a successful rename
+
+	ocrOpts := opts
+	ocrOpts.OCR = true
+	if err := f.convertWithOCR(ctx, input, ocrOutput, ocrOpts); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s has no text layer and OCR failed: %w", internal.ErrNoTextExtracted, input, err)
+	}
+
+	ocrLetters, err := countLetters(ocrOutput)
+	if err != nil || ocrLetters < minTextLetters {
+		return fmt.Errorf("%w: neither text extraction nor OCR found text in %s", internal.ErrNoTextExtracted, input)
+	}
+
+	if err := os.Rename(ocrOutput, output); err != nil {
+		return fmt.Errorf("failed to replace output with OCR text: %w", err)
+	}
+
+	report.OCR = true
+	report.OCRFallback = true
+	log.Info().
+		Str("input", input).
+		Str("output", output).
+		Int("letters", ocrLetters).
+		Msg("Used OCR fallback for document without text layer")
+	return nil
+}
+
+// isTextFormat reports whether a format is plain text output that OCR can stand in for
+func isTextFormat(format internal.DocumentFormat) bool {
+	return format == internal.FormatTXT || format == internal.FormatMD
+}
+
+// countLetters counts letters in a text file, ignoring whitespace, digits and punctuation
+func countLetters(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, r := range string(data) {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// SetOverwrite controls whether conversions may replace existing output files
+// (the default); when disallowed they fail with ErrOutputExists
+func (f *Factory) SetOverwrite(allow bool) {
+	f.overwrite = allow
+}
+
+// checkOutput refuses an existing output unless overwriting is allowed
+func (f *Factory) checkOutput(output string) error {
+	if f.overwrite {
+		return nil
+	}
+	if _, err := os.Lstat(output); err == nil {
+		return fmt.Errorf("%w: %s", internal.ErrOutputExists, output)
+	}
+	return nil
+}
+
+// writeOutput runs write on a temp path next to output and renames the result
+// into place on success, so a failed, timed out or interrupted conversion never
+// leaves a truncated file under the output name
+func (f *Factory) writeOutput(output string, write func(tmp string) error) error {
+	if err := f.checkOutput(output); err != nil {
+		return err
+	}
+
+	tmp, err := newOutputTemp(output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp) // No-op after the rename
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(tmp); os.IsNotExist(err) {
+		return nil // Nothing was written; checking outputs is up to the converter
+	}
+	return f.moveOutput(tmp, output)
+}
+
+// moveOutput renames a finished temp output into place
+func (f *Factory) moveOutput(tmp, output string) error {
+	// Another process may have written the output meanwhile
+	if err := f.checkOutput(output); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		return fmt.Errorf("failed to move output into place: %w", err)
+	}
+	return nil
+}
+
+// newOutputTemp reserves a hidden temp path in output's directory, keeping the
+// extension for tools that pick the format from it: .report.pdf.yakateka-123.pdf
+// The file itself is removed again, so converters that write nothing are still
+// caught by their output checks
+func newOutputTemp(output string) (string, error) {
+	dir, base := filepath.Split(output)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, "."+base+".yakateka-*"+filepath.Ext(base))
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	file.Close()
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// truncatingConverter writes part of an output, then fails like a timed out tool
+type truncatingConverter struct {
+	mockConverter
+}
+
+func (c *truncatingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	if err := os.WriteFile(output, []byte("%PDF-1.7 trunc"), 0644); err != nil {
+		return err
+	}
+	return context.DeadlineExceeded
+}
+
+func TestConvertWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	docxToPDF := mockConverter{inputFormats: formats(internal.FormatDOCX), outputFormats: formats(internal.FormatPDF)}
+	opts := internal.ConversionOptions{InputFormat: internal.FormatDOCX, OutputFormat: internal.FormatPDF}
+	output := filepath.Join(dir, "report.pdf")
+
+	factory := NewFactory()
+	factory.Register("libreoffice", &truncatingConverter{mockConverter: docxToPDF})
+	if err := factory.Convert(context.Background(), "report.docx", output, opts); err == nil {
+		t.Fatal("expected the conversion to fail")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed conversion left %v behind", entries)
+	}
+
+	factory = NewFactory()
+	factory.Register("libreoffice", &writingConverter{mockConverter: docxToPDF, content: "%PDF-1.7"})
+	factory.SetOverwrite(false)
+	if err := factory.Convert(context.Background(), "report.docx", output, opts); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "%PDF-1.7" {
+		t.Errorf("output = %q", data)
+	}
+
+	// An existing output is kept unless overwriting is allowed
+	factory.Register("libreoffice", &writingConverter{mockConverter: docxToPDF, content: "%PDF-2.0"})
+	if err := factory.Convert(context.Background(), "report.docx", output, opts); !errors.Is(err, internal.ErrOutputExists) {
+		t.Errorf("err = %v, want ErrOutputExists", err)
+	}
+	factory.SetOverwrite(true)
+	if err := factory.Convert(context.Background(), "report.docx", output, opts); err != nil {
+		t.Fatalf("Convert with overwrite failed: %v", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "%PDF-2.0" {
+		t.Errorf("overwritten output = %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"html"
+	"io"
 	"os"
 	"strings"
 
@@ -25,16 +26,9 @@ func (c *Converter) Convert(ctx context.Context, input, output string, opts inte
 		return fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	text := string(content)
-
-	var result string
-	switch opts.OutputFormat {
-	case internal.FormatHTML:
-		result = convertToHTML(text)
-	case internal.FormatMD:
-		result = convertToMarkdown(text)
-	default:
-		return fmt.Errorf("%w: plaintext converter only supports HTML and MD output", internal.ErrUnsupportedFormat)
+	result, err := render(string(content), opts.OutputFormat)
+	if err != nil {
+		return err
 	}
 
 	// Write output
@@ -54,6 +48,48 @@ func (c *Converter) Convert(ctx context.Context, input, output string, opts inte
 	return nil
 }
 
+// CanStream reports whether a conversion can run on streams
+// Implements internal.StreamConverter
+func (c *Converter) CanStream(from, to internal.DocumentFormat) bool {
+	return from == internal.FormatTXT && (to == internal.FormatHTML || to == internal.FormatMD)
+}
+
+// ConvertStream converts plain text read from r to HTML or Markdown written to w
+func (c *Converter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts internal.ConversionOptions) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	result, err := render(string(content), opts.OutputFormat)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, result); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	log.Info().
+		Str("from", "txt").
+		Str("to", string(opts.OutputFormat)).
+		Int("bytes", len(result)).
+		Msg("Successfully converted plain text stream")
+
+	return nil
+}
+
+// render converts plain text to the requested output format
+func render(text string, format internal.DocumentFormat) (string, error) {
+	switch format {
+	case internal.FormatHTML:
+		return convertToHTML(text), nil
+	case internal.FormatMD:
+		return convertToMarkdown(text), nil
+	default:
+		return "", fmt.Errorf("%w: plaintext converter only supports HTML and MD output", internal.ErrUnsupportedFormat)
+	}
+}
+
 // convertToHTML wraps plain text in basic HTML structure
 func convertToHTML(text string) string {
 	// Escape HTML entities
@@ -0,0 +1,289 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/helper"
+)
+
+// maxAlternatives caps how many rejected routes a plan lists
+const maxAlternatives = 10
+
+// Plan explains how a conversion would be performed without running it
+type Plan struct {
+	From         internal.DocumentFormat `json:"from" yaml:"from"`
+	To           internal.DocumentFormat `json:"to" yaml:"to"`
+	Mode         string                  `json:"mode" yaml:"mode"`
+	Via          string                  `json:"via,omitempty" yaml:"via,omitempty"` // Converters forced with --via
+	Route        *PlanRoute              `json:"route,omitempty" yaml:"route,omitempty"`
+	Reason       string                  `json:"reason,omitempty" yaml:"reason,omitempty"` // Why no route was found
+	Candidates   []PlanCandidates        `json:"candidates,omitempty" yaml:"candidates,omitempty"`
+	Alternatives []PlanRoute             `json:"alternatives,omitempty" yaml:"alternatives,omitempty"`
+}
+
+// PlanRoute is a sequence of conversion steps with its total cost
+type PlanRoute struct {
+	Steps    []PlanStep `json:"steps" yaml:"steps"`
+	Cost     float64    `json:"cost" yaml:"cost"`
+	Rejected string     `json:"rejected,omitempty" yaml:"rejected,omitempty"`
+}
+
+// PlanStep is one step of a planned route
+type PlanStep struct {
+	From      internal.DocumentFormat `json:"from" yaml:"from"`
+	To        internal.DocumentFormat `json:"to" yaml:"to"`
+	Converter string                  `json:"converter" yaml:"converter"`
+	Priority  int                     `json:"priority" yaml:"priority"`
+	Speed     float64                 `json:"speed" yaml:"speed"`
+	Quality   float64                 `json:"quality" yaml:"quality"`
+	Cost      float64                 `json:"cost" yaml:"cost"`
+}
+
+// PlanCandidates lists everything able to perform one format pair of the route
+type PlanCandidates struct {
+	From       internal.DocumentFormat `json:"from" yaml:"from"`
+	To         internal.DocumentFormat `json:"to" yaml:"to"`
+	Converters []PlanCandidate         `json:"converters" yaml:"converters"`
+}
+
+// PlanCandidate is a converter or helper considered for a step
+type PlanCandidate struct {
+	Converter string  `json:"converter" yaml:"converter"`
+	Helper    string  `json:"helper,omitempty" yaml:"helper,omitempty"`
+	Weight    float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Priority  int     `json:"priority" yaml:"priority"`
+	Speed     float64 `json:"speed" yaml:"speed"`
+	Quality   float64 `json:"quality" yaml:"quality"`
+	Cost      float64 `json:"cost" yaml:"cost"`
+	Selected  bool    `json:"selected" yaml:"selected"`
+	Rejected  string  `json:"rejected,omitempty" yaml:"rejected,omitempty"`
+}
+
+// helperFinder is implemented by the helpers converter to expose individual helpers
+type helperFinder interface {
+	FindHelpers(from, to internal.DocumentFormat, mode string) []helper.CacheEntry
+}
+
+// Plan works out the route Convert would take for opts, along with every
+// candidate converter for its steps and the alternative routes it beat
+func (f *Factory) Plan(opts internal.ConversionOptions) *Plan {
+	from, to := opts.InputFormat, opts.OutputFormat
+	mode := helper.ModeFromQuality(opts.Quality)
+	g := f.graph(mode)
+	plan := &Plan{From: from, To: to, Mode: string(mode), Via: opts.Via}
+
+	if opts.OCR {
+		return f.planOCR(g, plan, opts.Quality)
+	}
+	if opts.Via != "" {
+		return f.planVia(plan, from, opts.Quality)
+	}
+
+	pipeline, cost, ok := g.shortestPath(from, to, maxPipelineSteps)
+	if !ok {
+		plan.Reason = g.unreachableReason(from, to)
+		return plan
+	}
+	plan.Route = planRoute(pipeline, cost)
+	plan.Candidates = g.candidates(pipeline, f.converters)
+	plan.Alternatives = g.alternatives(from, to, pipeline, cost)
+	return plan
+}
+
+// planVia reports the route forced by --via after any prefix steps (OCR);
+// there are no candidates or alternatives
+func (f *Factory) planVia(plan *Plan, from internal.DocumentFormat, quality string, prefix ...ConversionStep) *Plan {
+	pipeline, reason := f.viaRoute(from, plan.To, plan.Via, quality)
+	if reason != "" {
+		plan.Reason = "--via " + plan.Via + ": " + reason
+		return plan
+	}
+	plan.Route = planRoute(append(prefix, pipeline...), routeCost(pipeline))
+	return plan
+}
+
+// planOCR plans OCR to text followed by the cheapest (or --via) text → target route
+func (f *Factory) planOCR(g *conversionGraph, plan *Plan, quality string) *Plan {
+	if f.ocr == nil {
+		plan.Reason = "OCR requested but no OCR engine is configured"
+		return plan
+	}
+	if !f.ocr.supports(plan.From) {
+		plan.Reason = fmt.Sprintf("OCR engine cannot read %s", plan.From)
+		return plan
+	}
+
+	ocrStep := ConversionStep{FromFormat: plan.From, ToFormat: internal.FormatTXT, Name: ocrConverterName, Speed: 1, Quality: 1}
+	if plan.To == internal.FormatTXT {
+		plan.Route = planRoute([]ConversionStep{ocrStep}, 0)
+		return plan
+	}
+	if plan.Via != "" {
+		return f.planVia(plan, internal.FormatTXT, quality, ocrStep)
+	}
+
+	pipeline, cost, ok := g.shortestPath(internal.FormatTXT, plan.To, maxPipelineSteps)
+	if !ok {
+		plan.Reason = "OCR produces txt, but " + g.unreachableReason(internal.FormatTXT, plan.To)
+		return plan
+	}
+	plan.Route = planRoute(append([]ConversionStep{ocrStep}, pipeline...), cost)
+	plan.Candidates = g.candidates(pipeline, f.converters)
+	return plan
+}
+
+// planRoute converts planner steps to their reported form
+func planRoute(pipeline []ConversionStep, cost float64) *PlanRoute {
+	route := &PlanRoute{Cost: cost}
+	for _, step := range pipeline {
+		route.Steps = append(route.Steps, PlanStep{
+			From:      step.FromFormat,
+			To:        step.ToFormat,
+			Converter: step.Name,
+			Priority:  step.Priority,
+			Speed:     step.Speed,
+			Quality:   step.Quality,
+			Cost:      step.Cost,
+		})
+	}
+	return route
+}
+
+// candidates lists every converter (and helper) able to perform each step of the
+// route in the order they are tried
+func (g *conversionGraph) candidates(pipeline []ConversionStep, converters map[string]internal.Converter) []PlanCandidates {
+	var result []PlanCandidates
+	for _, selected := range pipeline {
+		pair := PlanCandidates{From: selected.FromFormat, To: selected.ToFormat}
+		for rank, step := range g.edges[formatPair{selected.FromFormat, selected.ToFormat}] {
+			rejected := ""
+			if rank > 0 {
+				rejected = fmt.Sprintf("fallback %d: %s", rank, rankReason(step, selected))
+			}
+
+			finder, ok := converters[step.Name].(helperFinder)
+			if !ok {
+				pair.Converters = append(pair.Converters, PlanCandidate{
+					Converter: step.Name,
+					Priority:  step.Priority,
+					Speed:     step.Speed,
+					Quality:   step.Quality,
+					Cost:      step.Cost,
+					Selected:  rejected == "",
+					Rejected:  rejected,
+				})
+				continue
+			}
+
+			// Helpers are tried in weight order; later ones are fallbacks
+			helpers := finder.FindHelpers(step.FromFormat, step.ToFormat, string(g.mode))
+			for i, entry := range helpers {
+				metrics := entry.Metrics()
+				candidate := PlanCandidate{
+					Converter: step.Name,
+					Helper:    entry.Helper,
+					Weight:    entry.Weight,
+					Priority:  step.Priority,
+					Speed:     metrics.Speed,
+					Quality:   metrics.Quality,
+					Cost:      step.Cost,
+					Selected:  rejected == "" && i == 0,
+					Rejected:  rejected,
+				}
+				if rejected == "" && i > 0 {
+					candidate.Cost = 0
+					candidate.Rejected = fmt.Sprintf("fallback if %s fails (weight %.2f)", helpers[0].Helper, helpers[0].Weight)
+				}
+				pair.Converters = append(pair.Converters, candidate)
+			}
+		}
+		result = append(result, pair)
+	}
+	return result
+}
+
+// rankReason explains why step ranks below the selected converter for a pair
+func rankReason(step, selected ConversionStep) string {
+	switch {
+	case step.Priority < selected.Priority:
+		return fmt.Sprintf("lower priority than %s (%d < %d)", selected.Name, step.Priority, selected.Priority)
+	case step.Cost > selected.Cost:
+		return fmt.Sprintf("costlier than %s (%.2f > %.2f)", selected.Name, step.Cost, selected.Cost)
+	default:
+		return fmt.Sprintf("same priority and cost as %s, which sorts first", selected.Name)
+	}
+}
+
+// alternatives enumerates other loop-free routes within the step limit, cheapest first
+func (g *conversionGraph) alternatives(from, to internal.DocumentFormat, selected []ConversionStep, selectedCost float64) []PlanRoute {
+	selectedKey := routeKey(selected)
+	var routes []PlanRoute
+
+	visited := map[internal.DocumentFormat]bool{from: true}
+	var path []ConversionStep
+	var walk func(format internal.DocumentFormat, cost float64)
+	walk = func(format internal.DocumentFormat, cost float64) {
+		if format == to {
+			if routeKey(path) != selectedKey {
+				route := planRoute(path, cost)
+				route.Rejected = fmt.Sprintf("costlier than the selected route (%.2f > %.2f)", cost, selectedCost)
+				if cost == selectedCost {
+					route.Rejected = "same cost as the selected route, which sorts first"
+				}
+				routes = append(routes, *route)
+			}
+			return
+		}
+		if len(path) >= maxPipelineSteps {
+			return
+		}
+		for _, pair := range g.out[format] {
+			if visited[pair.to] {
+				continue
+			}
+			step, _ := g.best(pair.from, pair.to)
+			visited[pair.to] = true
+			path = append(path, step)
+			walk(pair.to, cost+step.Cost)
+			path = path[:len(path)-1]
+			visited[pair.to] = false
+		}
+	}
+	if from != to {
+		walk(from, 0)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Cost != routes[j].Cost {
+			return routes[i].Cost < routes[j].Cost
+		}
+		return len(routes[i].Steps) < len(routes[j].Steps)
+	})
+	if len(routes) > maxAlternatives {
+		routes = routes[:maxAlternatives]
+	}
+	return routes
+}
+
+// unreachableReason explains why no route exists between two formats
+func (g *conversionGraph) unreachableReason(from, to internal.DocumentFormat) string {
+	if from == to {
+		return fmt.Sprintf("no converter rewrites %s in place", from)
+	}
+	if len(g.out[from]) == 0 {
+		return fmt.Sprintf("no converter reads %s", from)
+	}
+	writable := false
+	for pair := range g.edges {
+		if pair.to == to && pair.from != to {
+			writable = true
+			break
+		}
+	}
+	if !writable {
+		return fmt.Sprintf("no converter writes %s", to)
+	}
+	return fmt.Sprintf("no route from %s to %s within %d steps", from, to, maxPipelineSteps)
+}
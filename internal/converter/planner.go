@@ -0,0 +1,282 @@
+package converter
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/helper"
+)
+
+// Planner settings
+const (
+	maxPipelineSteps = 4   // Longest pipeline the planner will build
+	stepCost         = 0.5 // Fixed cost of running one more tool (process start, temp file)
+)
+
+// costWeights balances speed, quality and lossiness for an objective
+type costWeights struct {
+	speed   float64
+	quality float64
+	loss    float64
+}
+
+// objectiveWeights maps conversion modes (from --quality) to cost weights
+var objectiveWeights = map[helper.ConversionMode]costWeights{
+	helper.ModeFast:    {speed: 1, quality: 0.25, loss: 0.5},
+	helper.ModeNormal:  {speed: 0.5, quality: 0.5, loss: 1},
+	helper.ModeQuality: {speed: 0.25, quality: 1, loss: 2},
+}
+
+// layoutFormats fix text on pages; getting reflowable text back out of them is lossy
+var layoutFormats = map[internal.DocumentFormat]bool{
+	internal.FormatPDF:  true,
+	internal.FormatPS:   true,
+	internal.FormatDJVU: true,
+}
+
+// lossiness estimates how much structure a conversion step discards (0 = none)
+func lossiness(from, to internal.DocumentFormat) float64 {
+	switch {
+	case from == to, from == internal.FormatTXT:
+		return 0
+	case to == internal.FormatTXT:
+		return 2 // All structure is gone
+	case layoutFormats[from] && layoutFormats[to]:
+		return 0.2 // Re-rendering pages
+	case layoutFormats[from]:
+		return 1.5 // Paragraphs, headings and tables are reconstructed from page layout
+	case to == internal.FormatMD, to == internal.FormatRST, to == internal.FormatFB2:
+		return 0.3 // Styling and complex layout are simplified
+	case to == internal.FormatCSV:
+		return 1 // Only tabular data survives
+	default:
+		return 0
+	}
+}
+
+// stepCostFor rates one conversion step; lower is better
+// Speed and quality are relative metrics (> 0, higher = better), so their
+// reciprocals grow as a tool gets slower or worse
+func stepCostFor(weights costWeights, from, to internal.DocumentFormat, speed, quality float64) float64 {
+	if speed <= 0 {
+		speed = 1
+	}
+	if quality <= 0 {
+		quality = 1
+	}
+	return stepCost + weights.speed/speed + weights.quality/quality + weights.loss*lossiness(from, to)
+}
+
+// formatPair is a directed edge of the conversion graph
+type formatPair struct {
+	from internal.DocumentFormat
+	to   internal.DocumentFormat
+}
+
+// conversionGraph holds every converter able to perform each format pair,
+// ranked by priority, then cost, then name
+type conversionGraph struct {
+	mode  helper.ConversionMode
+	edges map[formatPair][]ConversionStep
+	out   map[internal.DocumentFormat][]formatPair // Adjacency, sorted for determinism
+}
+
+// graph builds the capability graph of all registered converters for a mode
+// Converters implementing internal.CapabilityProvider contribute their listed
+// pairs; others contribute every input × output combination
+func (f *Factory) graph(mode helper.ConversionMode) *conversionGraph {
+	weights, ok := objectiveWeights[mode]
+	if !ok {
+		mode, weights = helper.ModeNormal, objectiveWeights[helper.ModeNormal]
+	}
+	g := &conversionGraph{
+		mode:  mode,
+		edges: make(map[formatPair][]ConversionStep),
+		out:   make(map[internal.DocumentFormat][]formatPair),
+	}
+
+	for _, name := range f.names() {
+		conv := f.converters[name]
+		for _, capability := range capabilities(conv, mode) {
+			pair := formatPair{capability.From, capability.To}
+			// Same-format edges (pdf → pdf rewrites) serve direct requests only
+			if _, seen := g.edges[pair]; !seen && pair.from != pair.to {
+				g.out[pair.from] = append(g.out[pair.from], pair)
+			}
+			g.edges[pair] = append(g.edges[pair], ConversionStep{
+				FromFormat: capability.From,
+				ToFormat:   capability.To,
+				Name:       name,
+				Converter:  conv,
+				Priority:   f.priorities[name],
+				Speed:      capability.Speed,
+				Quality:    capability.Quality,
+				Cost:       stepCostFor(weights, capability.From, capability.To, capability.Speed, capability.Quality),
+			})
+		}
+	}
+
+	for pair, steps := range g.edges {
+		sort.SliceStable(steps, func(i, j int) bool {
+			if steps[i].Priority != steps[j].Priority {
+				return steps[i].Priority > steps[j].Priority
+			}
+			return steps[i].Cost < steps[j].Cost
+		})
+		g.edges[pair] = steps
+	}
+	for format, pairs := range g.out {
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].to < pairs[j].to })
+		g.out[format] = pairs
+	}
+	return g
+}
+
+// capabilities lists the conversions a converter offers in mode
+func capabilities(conv internal.Converter, mode helper.ConversionMode) []internal.ConversionCapability {
+	if provider, ok := conv.(internal.CapabilityProvider); ok {
+		return provider.Capabilities(string(mode))
+	}
+
+	inputs, outputs := conv.SupportedInputFormats(), conv.SupportedOutputFormats()
+	result := make([]internal.ConversionCapability, 0, len(inputs)*len(outputs))
+	for _, from := range inputs {
+		for _, to := range outputs {
+			result = append(result, internal.ConversionCapability{From: from, To: to, Speed: 1, Quality: 1})
+		}
+	}
+	return result
+}
+
+// best returns the top-ranked converter for a pair
+func (g *conversionGraph) best(from, to internal.DocumentFormat) (ConversionStep, bool) {
+	steps := g.edges[formatPair{from, to}]
+	if len(steps) == 0 {
+		return ConversionStep{}, false
+	}
+	return steps[0], true
+}
+
+// planState is a Dijkstra search state: a format reached after some steps
+type planState struct {
+	format internal.DocumentFormat
+	steps  int
+}
+
+// planEntry is a queued partial route
+type planEntry struct {
+	state planState
+	cost  float64
+	path  []ConversionStep
+}
+
+// planQueue is a min-heap of partial routes by cost, then length, then route
+type planQueue []*planEntry
+
+func (q planQueue) Len() int { return len(q) }
+func (q planQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	if len(q[i].path) != len(q[j].path) {
+		return len(q[i].path) < len(q[j].path)
+	}
+	return routeKey(q[i].path) < routeKey(q[j].path)
+}
+func (q planQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *planQueue) Push(x any)   { *q = append(*q, x.(*planEntry)) }
+func (q *planQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// shortestPath finds the cheapest route from → to with at most maxSteps steps
+func (g *conversionGraph) shortestPath(from, to internal.DocumentFormat, maxSteps int) ([]ConversionStep, float64, bool) {
+	if from == to {
+		step, ok := g.best(from, to)
+		return []ConversionStep{step}, step.Cost, ok
+	}
+
+	queue := &planQueue{{state: planState{format: from}}}
+	settled := make(map[planState]bool)
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(*planEntry)
+		if current.state.format == to {
+			return current.path, current.cost, true
+		}
+		if settled[current.state] {
+			continue
+		}
+		settled[current.state] = true
+		if current.state.steps >= maxSteps {
+			continue
+		}
+
+		for _, pair := range g.out[current.state.format] {
+			if pair.to == from {
+				continue // Never return to the source format
+			}
+			step, _ := g.best(pair.from, pair.to)
+			next := planState{format: pair.to, steps: current.state.steps + 1}
+			if settled[next] {
+				continue
+			}
+			path := make([]ConversionStep, len(current.path), len(current.path)+1)
+			copy(path, current.path)
+			heap.Push(queue, &planEntry{
+				state: next,
+				cost:  current.cost + step.Cost,
+				path:  append(path, step),
+			})
+		}
+	}
+	return nil, 0, false
+}
+
+// buildPipeline plans the cheapest conversion route for the objective selected
+// by quality (fast, normal, quality), weighing converter speed and quality and
+// penalising lossy steps such as anything → TXT or PDF → anything
+func (f *Factory) buildPipeline(from, to internal.DocumentFormat, quality string) ([]ConversionStep, error) {
+	mode := helper.ModeFromQuality(quality)
+	g := f.graph(mode)
+	pipeline, cost, ok := g.shortestPath(from, to, maxPipelineSteps)
+	if !ok {
+		return nil, fmt.Errorf("%w: no conversion pipeline found for %s → %s (requires additional converters)",
+			internal.ErrUnsupportedConversion, from, to)
+	}
+	for i, step := range pipeline {
+		pipeline[i].Fallbacks = g.edges[formatPair{step.FromFormat, step.ToFormat}][1:]
+	}
+
+	log.Debug().
+		Str("from", string(from)).
+		Str("to", string(to)).
+		Str("mode", string(mode)).
+		Str("route", routeKey(pipeline)).
+		Float64("cost", cost).
+		Int("steps", len(pipeline)).
+		Msg("Planned conversion route")
+
+	return pipeline, nil
+}
+
+// routeKey renders a route as pdf→html→md (pandoc, pandoc)
+func routeKey(path []ConversionStep) string {
+	if len(path) == 0 {
+		return ""
+	}
+	formats := []string{string(path[0].FromFormat)}
+	names := make([]string, 0, len(path))
+	for _, step := range path {
+		formats = append(formats, string(step.ToFormat))
+		names = append(names, step.Name)
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(formats, "→"), strings.Join(names, ", "))
+}
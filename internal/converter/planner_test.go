@@ -0,0 +1,252 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// ratedConverter lists explicit format pairs with per-mode metrics, like the helpers converter
+type ratedConverter struct {
+	mockConverter
+	capabilities map[string][]internal.ConversionCapability
+}
+
+func (r *ratedConverter) Capabilities(mode string) []internal.ConversionCapability {
+	if caps, ok := r.capabilities[mode]; ok {
+		return caps
+	}
+	return r.capabilities["normal"]
+}
+
+func formats(f ...internal.DocumentFormat) []internal.DocumentFormat { return f }
+
+// routeOf summarises a pipeline as its route key
+func routeOf(t *testing.T, factory *Factory, from, to internal.DocumentFormat, quality string) string {
+	t.Helper()
+	pipeline, err := factory.buildPipeline(from, to, quality)
+	if err != nil {
+		t.Fatalf("buildPipeline(%s → %s, %q) failed: %v", from, to, quality, err)
+	}
+	return routeKey(pipeline)
+}
+
+func TestPlannerAvoidsLossyIntermediates(t *testing.T) {
+	factory := NewFactory()
+	factory.Register("office", &mockConverter{
+		inputFormats:  formats(internal.FormatDOCX),
+		outputFormats: formats(internal.FormatPDF, internal.FormatHTML),
+	})
+	factory.Register("pdftool", &mockConverter{
+		inputFormats:  formats(internal.FormatPDF),
+		outputFormats: formats(internal.FormatMD),
+	})
+	factory.Register("markup", &mockConverter{
+		inputFormats:  formats(internal.FormatHTML),
+		outputFormats: formats(internal.FormatMD),
+	})
+
+	for _, quality := range []string{"fast", "normal", "quality"} {
+		if got, want := routeOf(t, factory, internal.FormatDOCX, internal.FormatMD, quality),
+			"docx→html→md (office, markup)"; got != want {
+			t.Errorf("%s: route = %s, want %s", quality, got, want)
+		}
+	}
+}
+
+func TestPlannerObjective(t *testing.T) {
+	factory := NewFactory()
+	factory.Register("careful", &ratedConverter{capabilities: map[string][]internal.ConversionCapability{
+		"normal": {{From: internal.FormatEPUB, To: internal.FormatHTML, Speed: 0.2, Quality: 3}},
+	}})
+	factory.Register("quick", &ratedConverter{capabilities: map[string][]internal.ConversionCapability{
+		"normal": {{From: internal.FormatEPUB, To: internal.FormatHTML, Speed: 5, Quality: 0.4}},
+	}})
+
+	if got := routeOf(t, factory, internal.FormatEPUB, internal.FormatHTML, "fast"); got != "epub→html (quick)" {
+		t.Errorf("fast route = %s", got)
+	}
+	if got := routeOf(t, factory, internal.FormatEPUB, internal.FormatHTML, "quality"); got != "epub→html (careful)" {
+		t.Errorf("quality route = %s", got)
+	}
+	if got := routeOf(t, factory, internal.FormatEPUB, internal.FormatHTML, "high"); got != "epub→html (careful)" {
+		t.Errorf("high route = %s", got)
+	}
+}
+
+func TestPlannerUsesCapabilityPairs(t *testing.T) {
+	// A union of formats would claim md → txt; the listed pairs do not
+	factory := NewFactory()
+	factory.Register("helpers", &ratedConverter{
+		mockConverter: mockConverter{
+			inputFormats:  formats(internal.FormatMD, internal.FormatPDF),
+			outputFormats: formats(internal.FormatHTML, internal.FormatTXT),
+		},
+		capabilities: map[string][]internal.ConversionCapability{
+			"normal": {
+				{From: internal.FormatMD, To: internal.FormatHTML, Speed: 1, Quality: 1},
+				{From: internal.FormatPDF, To: internal.FormatTXT, Speed: 1, Quality: 1},
+			},
+		},
+	})
+
+	if _, err := factory.buildPipeline(internal.FormatMD, internal.FormatTXT, ""); !errors.Is(err, internal.ErrUnsupportedConversion) {
+		t.Errorf("md → txt: err = %v, want ErrUnsupportedConversion", err)
+	}
+	if _, err := factory.GetConverter(internal.FormatMD, internal.FormatTXT); err == nil {
+		t.Error("GetConverter should not offer md → txt")
+	}
+}
+
+func TestPlannerDeterministic(t *testing.T) {
+	factory := NewFactory()
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		factory.Register(name, &mockConverter{
+			inputFormats:  formats(internal.FormatEPUB),
+			outputFormats: formats(internal.FormatHTML),
+		})
+	}
+
+	for i := 0; i < 20; i++ {
+		if got := routeOf(t, factory, internal.FormatEPUB, internal.FormatHTML, ""); got != "epub→html (alpha)" {
+			t.Fatalf("run %d: route = %s, want alpha on ties", i, got)
+		}
+	}
+}
+
+func TestPlannerStepLimit(t *testing.T) {
+	// a → b → c → d → e → f needs five steps, one more than allowed
+	chain := []internal.DocumentFormat{"a", "b", "c", "d", "e", "f"}
+	factory := NewFactory()
+	for i := 0; i+1 < len(chain); i++ {
+		factory.Register(string(chain[i]), &mockConverter{
+			inputFormats:  formats(chain[i]),
+			outputFormats: formats(chain[i+1]),
+		})
+	}
+
+	if got := routeOf(t, factory, "a", "e", ""); got != "a→b→c→d→e (a, b, c, d)" {
+		t.Errorf("route = %s", got)
+	}
+	if _, err := factory.buildPipeline("a", "f", ""); err == nil {
+		t.Error("expected no route beyond the step limit")
+	}
+}
+
+func TestFactoryPlan(t *testing.T) {
+	factory := NewFactory()
+	factory.Register("office", &mockConverter{
+		inputFormats:  formats(internal.FormatDOCX),
+		outputFormats: formats(internal.FormatPDF, internal.FormatHTML),
+	})
+	factory.Register("pdftool", &mockConverter{
+		inputFormats:  formats(internal.FormatPDF),
+		outputFormats: formats(internal.FormatMD),
+	})
+	factory.Register("markup", &mockConverter{
+		inputFormats:  formats(internal.FormatHTML),
+		outputFormats: formats(internal.FormatMD),
+	})
+	factory.Register("backup", &mockConverter{
+		inputFormats:  formats(internal.FormatHTML),
+		outputFormats: formats(internal.FormatMD),
+	})
+
+	plan := factory.Plan(internal.ConversionOptions{InputFormat: internal.FormatDOCX, OutputFormat: internal.FormatMD})
+	if plan.Route == nil || len(plan.Route.Steps) != 2 || plan.Route.Steps[1].Converter != "backup" {
+		t.Fatalf("route = %+v, want docx→html→md ending with backup (alphabetical tie)", plan.Route)
+	}
+	if plan.Mode != "normal" {
+		t.Errorf("mode = %s, want normal", plan.Mode)
+	}
+
+	if len(plan.Candidates) != 2 {
+		t.Fatalf("candidates = %+v, want one entry per step", plan.Candidates)
+	}
+	htmlToMD := plan.Candidates[1].Converters
+	if len(htmlToMD) != 2 || !htmlToMD[0].Selected || htmlToMD[1].Selected || htmlToMD[1].Rejected == "" {
+		t.Errorf("html → md candidates = %+v", htmlToMD)
+	}
+
+	if len(plan.Alternatives) != 1 || plan.Alternatives[0].Steps[0].To != internal.FormatPDF ||
+		plan.Alternatives[0].Cost <= plan.Route.Cost || plan.Alternatives[0].Rejected == "" {
+		t.Errorf("alternatives = %+v, want the costlier route via pdf", plan.Alternatives)
+	}
+
+	none := factory.Plan(internal.ConversionOptions{InputFormat: internal.FormatEPUB, OutputFormat: internal.FormatMD})
+	if none.Route != nil || none.Reason != "no converter reads epub" {
+		t.Errorf("unreachable plan = %+v", none)
+	}
+}
+
+// failingConverter records calls and fails every conversion
+type failingConverter struct {
+	mockConverter
+	calls int
+}
+
+func (f *failingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	f.calls++
+	return fmt.Errorf("%w: tool crashed", internal.ErrConversionFailed)
+}
+
+func TestPlannerPriority(t *testing.T) {
+	factory := NewFactory()
+	for _, name := range []string{"calibre", "helpers", "pandoc"} {
+		factory.Register(name, &mockConverter{
+			inputFormats:  formats(internal.FormatEPUB),
+			outputFormats: formats(internal.FormatHTML),
+		})
+	}
+	factory.SetPriority("pandoc", 30)
+	factory.SetPriority("calibre", 10)
+
+	pipeline, err := factory.buildPipeline(internal.FormatEPUB, internal.FormatHTML, "")
+	if err != nil {
+		t.Fatalf("buildPipeline failed: %v", err)
+	}
+	var ranked []string
+	ranked = append(ranked, pipeline[0].Name)
+	for _, fallback := range pipeline[0].Fallbacks {
+		ranked = append(ranked, fallback.Name)
+	}
+	if got := strings.Join(ranked, ","); got != "pandoc,calibre,helpers" {
+		t.Errorf("ranking = %s, want pandoc,calibre,helpers", got)
+	}
+
+	if name, _, _ := factory.findConverter(internal.FormatEPUB, internal.FormatHTML); name != "pandoc" {
+		t.Errorf("findConverter = %s, want pandoc", name)
+	}
+}
+
+func TestFactoryFallsBackToNextRanked(t *testing.T) {
+	factory := NewFactory()
+	broken := &failingConverter{mockConverter: mockConverter{
+		inputFormats:  formats(internal.FormatEPUB),
+		outputFormats: formats(internal.FormatHTML),
+	}}
+	factory.Register("pandoc", broken)
+	factory.Register("calibre", &mockConverter{
+		inputFormats:  formats(internal.FormatEPUB),
+		outputFormats: formats(internal.FormatHTML),
+	})
+	factory.SetPriority("pandoc", 30)
+
+	opts := internal.ConversionOptions{InputFormat: internal.FormatEPUB, OutputFormat: internal.FormatHTML}
+	if err := factory.Convert(context.Background(), "in.epub", "out.html", opts); err != nil {
+		t.Fatalf("Expected calibre to take over, got %v", err)
+	}
+	if broken.calls != 1 {
+		t.Errorf("pandoc called %d times, want 1", broken.calls)
+	}
+
+	// With every converter failing, the last error is returned
+	factory.Register("calibre", &failingConverter{mockConverter: broken.mockConverter})
+	if err := factory.Convert(context.Background(), "in.epub", "out.html", opts); !errors.Is(err, internal.ErrConversionFailed) {
+		t.Errorf("err = %v, want ErrConversionFailed", err)
+	}
+}
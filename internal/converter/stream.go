@@ -0,0 +1,272 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+)
+
+// Stdio as an input or output path stands for the stdin or stdout stream
+const Stdio = "-"
+
+// ConvertStream converts like ConvertWithReport, reading the source from stdin
+// when input is Stdio and writing the result to stdout when output is Stdio
+// A single-step route whose converter streams (and that needs no file for OCR
+// or metadata embedding) runs directly on the streams; anything else is spooled
+// through temp files that are removed when the conversion ends, fails or is
+// cancelled. Output streamed straight to stdout cannot be validated
+func (f *Factory) ConvertStream(ctx context.Context, input, output string, stdin io.Reader, stdout io.Writer, opts internal.ConversionOptions) (*ConversionReport, error) {
+	if input != Stdio && output != Stdio {
+		return f.ConvertWithReport(ctx, input, output, opts)
+	}
+	if step, ok := f.streamStep(opts); ok {
+		return f.convertStreamDirect(ctx, step, input, output, stdin, stdout, opts)
+	}
+	return f.convertSpooled(ctx, input, output, stdin, stdout, opts)
+}
+
+// streamStep returns the converter that can run a conversion directly on streams
+func (f *Factory) streamStep(opts internal.ConversionOptions) (ConversionStep, bool) {
+	if opts.OCR {
+		return ConversionStep{}, false
+	}
+	// The OCR fallback re-reads the source and measures the output
+	if opts.OCRFallback && isTextFormat(opts.OutputFormat) && f.ocr != nil && f.ocr.supports(opts.InputFormat) {
+		return ConversionStep{}, false
+	}
+	// Embedding metadata rewrites the output file
+	if opts.PreserveMetadata && f.metadata != nil && f.metadata.CanEmbed(opts.OutputFormat) {
+		return ConversionStep{}, false
+	}
+
+	pipeline, err := f.route(opts.InputFormat, opts.OutputFormat, opts)
+	if err != nil || len(pipeline) != 1 {
+		return ConversionStep{}, false
+	}
+	step := pipeline[0]
+	streamer, ok := step.Converter.(internal.StreamConverter)
+	if !ok || !streamer.CanStream(step.FromFormat, step.ToFormat) {
+		return ConversionStep{}, false
+	}
+	return step, true
+}
+
+// convertStreamDirect runs a streaming converter, opening whichever side is a file
+// When the step has fallbacks, stdin is also copied to a temp file so they can
+// take over if the converter fails before writing anything to stdout, or at any
+// point (including an invalid output) when the output is a file
+func (f *Factory) convertStreamDirect(ctx context.Context, step ConversionStep, input, output string, stdin io.Reader, stdout io.Writer, opts internal.ConversionOptions) (*ConversionReport, error) {
+	r := stdin
+	var spooled *os.File
+	if input != Stdio {
+		in, err := os.Open(input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", internal.ErrInvalidInput, input)
+		}
+		defer in.Close()
+		r = in
+	} else if len(step.Fallbacks) > 0 {
+		var err error
+		if spooled, err = os.CreateTemp("", "yakateka-stdin-*."+string(opts.InputFormat)); err != nil {
+			return nil, fmt.Errorf("failed to create spool file: %w", err)
+		}
+		defer os.Remove(spooled.Name())
+		defer spooled.Close()
+		r = io.TeeReader(stdin, spooled)
+	}
+
+	// A file output is written next to it and renamed into place on success
+	w := &countingWriter{w: stdout}
+	var out *os.File
+	target := output
+	if output != Stdio {
+		if err := f.checkOutput(output); err != nil {
+			return nil, err
+		}
+		var err error
+		if target, err = newOutputTemp(output); err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer os.Remove(target) // No-op after the rename
+		if out, err = os.Create(target); err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		w.w = out
+	}
+
+	log.Debug().
+		Str("from", string(opts.InputFormat)).
+		Str("to", string(opts.OutputFormat)).
+		Str("converter", step.Name).
+		Msg("Streaming conversion without temp files")
+
+	err := f.runStreamConverter(ctx, step, r, w, opts)
+	if out != nil {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil && output != Stdio {
+		err = f.checkStepOutput(step, target)
+	}
+	if err == nil {
+		return &ConversionReport{Streamed: true}, f.finishStream(target, output)
+	}
+	// What already reached stdout cannot be taken back; a file output can be redone
+	if (output == Stdio && w.n > 0) || len(step.Fallbacks) == 0 || ctx.Err() != nil {
+		return nil, err
+	}
+
+	log.Warn().
+		Err(err).
+		Str("converter", step.Name).
+		Str("fallback", step.Fallbacks[0].Name).
+		Str("conversion", fmt.Sprintf("%s → %s", step.FromFormat, step.ToFormat)).
+		Msg("Streaming converter failed, trying next-ranked converter")
+
+	if spooled != nil {
+		// The rest of stdin, which the failed converter did not read
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		input = spooled.Name()
+	}
+	if output != Stdio {
+		os.Remove(target)
+	}
+	fallback := step.Fallbacks[0]
+	fallback.Fallbacks = step.Fallbacks[1:]
+	if err := f.runSpooledStep(ctx, fallback, input, target, stdout, opts); err != nil {
+		return nil, err
+	}
+	return &ConversionReport{}, f.finishStream(target, output)
+}
+
+// finishStream moves a streamed file output into place
+func (f *Factory) finishStream(target, output string) error {
+	if output == Stdio {
+		return nil
+	}
+	return f.moveOutput(target, output)
+}
+
+// runSpooledStep runs a step on an input file, through a temp output when
+// the result goes to stdout
+func (f *Factory) runSpooledStep(ctx context.Context, step ConversionStep, input, output string, stdout io.Writer, opts internal.ConversionOptions) error {
+	if output != Stdio {
+		return f.runStep(ctx, step, input, output, opts)
+	}
+
+	spooled, err := newTempFile(opts.OutputFormat)
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(spooled)
+
+	if err := f.runStep(ctx, step, input, spooled, opts); err != nil {
+		return err
+	}
+	if err := copyTo(stdout, spooled); err != nil {
+		return fmt.Errorf("failed to write stdout: %w", err)
+	}
+	return nil
+}
+
+// convertSpooled converts through temp files standing in for stdin and stdout
+func (f *Factory) convertSpooled(ctx context.Context, input, output string, stdin io.Reader, stdout io.Writer, opts internal.ConversionOptions) (*ConversionReport, error) {
+	dir, err := os.MkdirTemp("", "yakateka-stream-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if input == Stdio {
+		input = filepath.Join(dir, "stdin."+string(opts.InputFormat))
+		if err := spool(ctx, stdin, input); err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+	spooled := output
+	if output == Stdio {
+		spooled = filepath.Join(dir, "stdout."+string(opts.OutputFormat))
+	}
+
+	log.Debug().Str("dir", dir).Msg("Spooling streamed conversion through temp files")
+	report, err := f.ConvertWithReport(ctx, input, spooled, opts)
+	if err != nil || output != Stdio {
+		return report, err
+	}
+
+	// A sidecar next to the spooled output is removed with it
+	if report.Metadata != nil {
+		report.Metadata.Sidecar = ""
+	}
+	if err := copyTo(stdout, spooled); err != nil {
+		return report, fmt.Errorf("failed to write stdout: %w", err)
+	}
+	return report, nil
+}
+
+// runStreamConverter runs a streaming conversion, waiting for a free slot if the converter is capped
+func (f *Factory) runStreamConverter(ctx context.Context, step ConversionStep, r io.Reader, w io.Writer, opts internal.ConversionOptions) error {
+	release, err := f.limiter.Acquire(ctx, step.Name)
+	if err != nil {
+		return fmt.Errorf("waiting for converter %s: %w", step.Name, err)
+	}
+	defer release()
+
+	return step.Converter.(internal.StreamConverter).ConvertStream(ctx, r, w, opts)
+}
+
+// spool copies r into a new file at path, giving up when ctx is cancelled
+// (reads from a terminal or pipe cannot be interrupted otherwise)
+func spool(ctx context.Context, r io.Reader, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(file, r)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyTo writes the content of a file to w
+func copyTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
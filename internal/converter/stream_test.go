@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// streamingConverter upper-cases its input, on streams or files
+type streamingConverter struct {
+	mockConverter
+	fail bool // Fail without writing anything
+}
+
+func (s *streamingConverter) CanStream(from, to internal.DocumentFormat) bool {
+	return true
+}
+
+func (s *streamingConverter) ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts internal.ConversionOptions) error {
+	data, err := io.ReadAll(io.LimitReader(r, 2)) // Fail part way through the input
+	if err != nil {
+		return err
+	}
+	if s.fail {
+		return errors.New("stream broke")
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, strings.ToUpper(string(data)+string(rest)))
+	return err
+}
+
+func (s *streamingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, bytes.ToUpper(data), 0644)
+}
+
+// copyingConverter copies its input file, marking the copy
+type copyingConverter struct {
+	mockConverter
+}
+
+func (c *copyingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append([]byte("copy:"), data...), 0644)
+}
+
+func TestConvertStream(t *testing.T) {
+	textToHTML := mockConverter{inputFormats: formats(internal.FormatTXT), outputFormats: formats(internal.FormatHTML)}
+	opts := internal.ConversionOptions{InputFormat: internal.FormatTXT, OutputFormat: internal.FormatHTML}
+
+	tests := []struct {
+		name      string
+		streaming *streamingConverter
+		fallback  bool
+		want      string
+		streamed  bool
+	}{
+		{"streams directly", &streamingConverter{mockConverter: textToHTML}, false, "HELLO STREAMS", true},
+		{"falls back on the spooled input", &streamingConverter{mockConverter: textToHTML, fail: true}, true, "copy:hello streams", false},
+	}
+	for _, tt := range tests {
+		factory := NewFactory()
+		factory.Register("plaintext", tt.streaming)
+		factory.SetPriority("plaintext", 10)
+		if tt.fallback {
+			factory.Register("calibre", &copyingConverter{mockConverter: textToHTML})
+		}
+
+		var stdout bytes.Buffer
+		report, err := factory.ConvertStream(context.Background(), Stdio, Stdio, strings.NewReader("hello streams"), &stdout, opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if stdout.String() != tt.want || report.Streamed != tt.streamed {
+			t.Errorf("%s: stdout = %q, streamed = %v; want %q, %v", tt.name, stdout.String(), report.Streamed, tt.want, tt.streamed)
+		}
+	}
+
+	// Converters that need paths get spooled files
+	factory := NewFactory()
+	factory.Register("calibre", &copyingConverter{mockConverter: textToHTML})
+	output := filepath.Join(t.TempDir(), "out.html")
+	report, err := factory.ConvertStream(context.Background(), Stdio, output, strings.NewReader("spooled"), nil, opts)
+	if err != nil {
+		t.Fatalf("spooled conversion: %v", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "copy:spooled" || report.Streamed {
+		t.Errorf("spooled output = %q, streamed = %v", data, report.Streamed)
+	}
+}
+
+func TestSpoolCancelled(t *testing.T) {
+	r, w := io.Pipe() // Never written: a read blocks like an idle terminal
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := spool(ctx, r, filepath.Join(t.TempDir(), "stdin.txt"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,269 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+)
+
+// Target is one requested output of a multi-target conversion
+type Target struct {
+	Format internal.DocumentFormat
+	Output string
+}
+
+// TargetResult is the outcome of one target of ConvertTargets
+type TargetResult struct {
+	Target
+	Route    string            // Planned route, e.g. md→epub→mobi (pandoc, calibre)
+	Report   *ConversionReport // nil when the target failed before converting
+	Duration time.Duration     // Time spent on this target after shared steps ran
+	Err      error
+}
+
+// ConvertTargets converts one input to several formats
+// Routes are planned together: intermediate steps that several targets pass
+// through run once, then every target is finished concurrently (per-converter
+// concurrency limits still apply). Shared outputs go to the cache, or to a temp
+// store for this call when the cache is disabled
+func (f *Factory) ConvertTargets(ctx context.Context, input string, targets []Target, opts internal.ConversionOptions) []TargetResult {
+	results := make([]TargetResult, len(targets))
+	routes := make([][]ConversionStep, len(targets))
+	for i, target := range targets {
+		results[i].Target = target
+		if results[i].Err = f.checkOutput(target.Output); results[i].Err != nil {
+			continue
+		}
+		targetOpts := opts
+		targetOpts.OutputFormat = target.Format
+		routes[i], results[i].Err = f.plannedRoute(targetOpts)
+		if results[i].Err == nil {
+			results[i].Route = routeKey(routes[i])
+		}
+	}
+
+	scope, cleanup, err := f.sharedScope()
+	if err != nil {
+		log.Warn().Err(err).Msg("Intermediate steps will not be shared between targets")
+		scope, cleanup = f, func() {}
+	}
+	defer cleanup()
+
+	var planned [][]ConversionStep
+	for i := range routes {
+		if results[i].Err == nil {
+			planned = append(planned, routes[i])
+		}
+	}
+	failed, fresh := scope.runShared(ctx, input, opts, sharedPrefixes(planned))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		if results[i].Err != nil {
+			continue
+		}
+		if prefix, err := firstFailure(routes[i], failed); err != nil {
+			results[i].Err = fmt.Errorf("shared steps %s failed: %w", prefix, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			targetOpts := opts
+			targetOpts.OutputFormat = target.Format
+
+			startTime := time.Now()
+			results[i].Report, results[i].Err = scope.ConvertWithReport(ctx, input, target.Output, targetOpts)
+			results[i].Duration = time.Since(startTime)
+
+			// A target whose whole route was shared was produced by this call, not reused
+			if results[i].Report != nil && fresh[results[i].Route] {
+				results[i].Report.Cached = false
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		event := log.Info()
+		if result.Err != nil {
+			event = log.Error().Err(result.Err)
+		}
+		event.
+			Str("to", string(result.Format)).
+			Str("output", result.Output).
+			Str("route", result.Route).
+			Dur("duration", result.Duration).
+			Msg("Target finished")
+	}
+	return results
+}
+
+// sharedScope returns a factory whose pipeline artifacts outlive one conversion:
+// this one when the cache is enabled, else a copy backed by a temp store that
+// cleanup removes
+func (f *Factory) sharedScope() (*Factory, func(), error) {
+	if f.cache != nil {
+		return f, func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "yakateka-targets-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	scope := *f
+	scope.cache = cache.New(dir, 0)
+	return &scope, func() { os.RemoveAll(dir) }, nil
+}
+
+// sharedPrefixes returns the longest route prefixes that more than one route
+// passes through and at least one continues from, sorted by route key
+func sharedPrefixes(routes [][]ConversionStep) [][]ConversionStep {
+	users := make(map[string]int)
+	continued := make(map[string]bool)
+	for _, route := range routes {
+		for n := 1; n <= len(route); n++ {
+			key := routeKey(route[:n])
+			users[key]++
+			continued[key] = continued[key] || n < len(route)
+		}
+	}
+
+	// The longest shared prefix of each route; shorter ones are part of it
+	longest := make(map[string][]ConversionStep)
+	for _, route := range routes {
+		var prefix []ConversionStep
+		for n := 1; n <= len(route); n++ {
+			key := routeKey(route[:n])
+			if users[key] < 2 || !continued[key] {
+				break
+			}
+			prefix = route[:n]
+		}
+		if prefix != nil {
+			longest[routeKey(prefix)] = prefix
+		}
+	}
+
+	var shared [][]ConversionStep
+	for key, prefix := range longest {
+		covered := false
+		for other, longer := range longest {
+			if other != key && len(longer) > len(prefix) && routeKey(longer[:len(prefix)]) == key {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			shared = append(shared, prefix)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return routeKey(shared[i]) < routeKey(shared[j]) })
+	return shared
+}
+
+// runShared stores the output of every shared prefix before targets start
+// Prefixes starting with the same step run one after another, so later ones
+// resume from what earlier ones stored; the rest run concurrently. It returns
+// the prefixes that failed and the ones (including their own prefixes) that
+// actually ran rather than being found in the cache, by route key
+func (f *Factory) runShared(ctx context.Context, input string, opts internal.ConversionOptions, shared [][]ConversionStep) (map[string]error, map[string]bool) {
+	groups := make(map[string][][]ConversionStep)
+	var order []string
+	for _, prefix := range shared {
+		first := routeKey(prefix[:1])
+		if _, ok := groups[first]; !ok {
+			order = append(order, first)
+		}
+		groups[first] = append(groups[first], prefix)
+	}
+
+	failed := make(map[string]error)
+	fresh := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, first := range order {
+		wg.Add(1)
+		go func(prefixes [][]ConversionStep) {
+			defer wg.Done()
+			for _, prefix := range prefixes {
+				reused, err := f.storePrefix(ctx, input, opts, prefix)
+
+				mu.Lock()
+				if err != nil {
+					failed[routeKey(prefix)] = err
+				} else {
+					for n := reused + 1; n <= len(prefix); n++ {
+						fresh[routeKey(prefix[:n])] = true
+					}
+				}
+				mu.Unlock()
+			}
+		}(groups[first])
+	}
+	wg.Wait()
+	return failed, fresh
+}
+
+// storePrefix runs a route prefix and stores its output as a pipeline artifact,
+// resuming after the longest part of it that is already stored
+// It returns the number of leading steps that were already stored
+func (f *Factory) storePrefix(ctx context.Context, input string, opts internal.ConversionOptions, prefix []ConversionStep) (int, error) {
+	artifacts := f.newArtifacts(input, opts)
+	if artifacts.source == "" {
+		return 0, fmt.Errorf("cannot read %s", input)
+	}
+	reused := len(prefix)
+	for reused > 0 && !artifacts.stored(prefix[:reused]) {
+		reused--
+	}
+	if reused == len(prefix) {
+		return reused, nil
+	}
+
+	last := prefix[len(prefix)-1]
+	output, err := newTempFile(last.ToFormat)
+	if err != nil {
+		return reused, fmt.Errorf("failed to create temp file for shared steps: %w", err)
+	}
+	defer os.Remove(output)
+
+	log.Info().
+		Str("route", routeKey(prefix)).
+		Int("steps", len(prefix)).
+		Msg("Running intermediate steps shared by several targets")
+
+	prefixOpts := opts
+	prefixOpts.InputFormat = prefix[0].FromFormat
+	prefixOpts.OutputFormat = last.ToFormat
+	if len(prefix) == 1 {
+		err = f.runStep(ctx, last, input, output, prefixOpts)
+	} else {
+		err = f.executePipeline(ctx, input, output, prefixOpts, prefix)
+	}
+	if err != nil {
+		return reused, err
+	}
+
+	artifacts.store(prefix, output)
+	f.keepIntermediate(input, len(prefix)-1, last, output)
+	return reused, nil
+}
+
+// firstFailure returns the failed shared prefix a route passes through, if any
+func firstFailure(route []ConversionStep, failed map[string]error) (string, error) {
+	for n := 1; n <= len(route); n++ {
+		key := routeKey(route[:n])
+		if err, ok := failed[key]; ok {
+			return key, err
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+func TestConvertTargetsSharesSteps(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.djvu")
+	if err := os.WriteFile(input, []byte("scan"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	djvups := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatDJVU), outputFormats: formats(internal.FormatPS)},
+		content:       "%!PS",
+	}}
+	ps2pdf := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatPS), outputFormats: formats(internal.FormatPDF)},
+		content:       "%PDF",
+	}}
+	calibre := &countingConverter{writingConverter: writingConverter{
+		mockConverter: mockConverter{inputFormats: formats(internal.FormatPDF), outputFormats: formats(internal.FormatEPUB)},
+		content:       "PK",
+	}}
+	factory := NewFactory()
+	factory.Register("djvups", djvups)
+	factory.Register("ps2pdf", ps2pdf)
+	factory.Register("calibre", calibre)
+
+	var targets []Target
+	for _, format := range []internal.DocumentFormat{internal.FormatPS, internal.FormatPDF, internal.FormatEPUB} {
+		targets = append(targets, Target{Format: format, Output: filepath.Join(dir, "book."+string(format))})
+	}
+	opts := internal.ConversionOptions{InputFormat: internal.FormatDJVU}
+
+	// No cache is configured: shared steps still run once
+	results := factory.ConvertTargets(context.Background(), input, targets, opts)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Format, result.Err)
+		}
+		if result.Report.Cached {
+			t.Errorf("%s: reported as cached, but it was produced by this call", result.Format)
+		}
+	}
+	if djvups.runs != 1 || ps2pdf.runs != 1 || calibre.runs != 1 {
+		t.Errorf("runs: djvups=%d ps2pdf=%d calibre=%d, want every step once",
+			djvups.runs, ps2pdf.runs, calibre.runs)
+	}
+	if results[2].Route != "djvu→ps→pdf→epub (djvups, ps2pdf, calibre)" {
+		t.Errorf("epub route = %s", results[2].Route)
+	}
+	for format, want := range map[string]string{"ps": "%!PS", "pdf": "%PDF", "epub": "PK"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "book."+format)); string(data) != want {
+			t.Errorf("book.%s = %q, want %q", format, data, want)
+		}
+	}
+
+	// A failed shared step fails the targets that depend on it, not the others
+	factory.Register("ps2pdf", &failingConverter{mockConverter: ps2pdf.mockConverter})
+	results = factory.ConvertTargets(context.Background(), input, targets, opts)
+	if results[0].Err != nil {
+		t.Errorf("ps: %v", results[0].Err)
+	}
+	for _, result := range results[1:] {
+		if result.Err == nil || !strings.Contains(result.Err.Error(), "shared steps djvu→ps→pdf") {
+			t.Errorf("%s: err = %v, want the shared step failure", result.Format, result.Err)
+		}
+	}
+}
+
+func TestSharedPrefixes(t *testing.T) {
+	step := func(from, to internal.DocumentFormat, name string) ConversionStep {
+		return ConversionStep{FromFormat: from, ToFormat: to, Name: name}
+	}
+	toEPUB := step(internal.FormatMD, internal.FormatEPUB, "pandoc")
+	toHTML := step(internal.FormatMD, internal.FormatHTML, "pandoc")
+	toMOBI := step(internal.FormatEPUB, internal.FormatMOBI, "calibre")
+	toPDF := step(internal.FormatHTML, internal.FormatPDF, "wkhtmltopdf")
+
+	tests := []struct {
+		name   string
+		routes [][]ConversionStep
+		want   []string
+	}{
+		{"direct targets share nothing", [][]ConversionStep{{toEPUB}, {toHTML}}, nil},
+		{"a target continued by another", [][]ConversionStep{{toEPUB}, {toEPUB, toMOBI}}, []string{"md→epub (pandoc)"}},
+		{"separate prefixes", [][]ConversionStep{{toEPUB}, {toEPUB, toMOBI}, {toHTML}, {toHTML, toPDF}},
+			[]string{"md→epub (pandoc)", "md→html (pandoc)"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, prefix := range sharedPrefixes(tt.routes) {
+			got = append(got, routeKey(prefix))
+		}
+		if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+			t.Errorf("%s: shared = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
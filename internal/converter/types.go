@@ -2,41 +2,118 @@ package converter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
 	"github.com/valpere/yakateka/internal/converter/config"
 	"github.com/valpere/yakateka/internal/converter/generic"
+	"github.com/valpere/yakateka/internal/helper"
+	"github.com/valpere/yakateka/internal/metadata"
+	"github.com/valpere/yakateka/internal/scheduler"
+	"github.com/valpere/yakateka/internal/validate"
 )
 
 // Factory creates converters based on input/output formats
 type Factory struct {
 	converters map[string]internal.Converter
+	priorities map[string]int      // Converter rank for a format pair; higher wins (default 0)
+	limiter    *scheduler.Limiter  // Per-converter concurrency caps
+	metadata   *metadata.Annotator // Carries source metadata to outputs (nil disables)
+	ocr        *ocrConverter       // OCR path for opts.OCR (nil when no engine is configured)
+	cache      *cache.Cache        // Reuses outputs of identical conversions (nil disables)
+	version    string              // Application version, part of cache keys
+	keepDir    string              // Copies of intermediate pipeline artifacts (empty disables)
+	overwrite  bool                // Existing outputs may be replaced
+	validate   bool                // Each step's output must parse as its format
+}
+
+// ConversionReport describes a finished conversion
+type ConversionReport struct {
+	Metadata    *metadata.Result // Source metadata applied to the output; nil when none was preserved
+	OCR         bool             // Text was recognised with OCR
+	OCRFallback bool             // OCR ran because regular extraction produced no text
+	Cached      bool             // Output was reused from the conversion cache
+	Streamed    bool             // Converter read and wrote the streams directly, without temp files
 }
 
 // ConversionStep represents one step in a conversion pipeline
 type ConversionStep struct {
 	FromFormat internal.DocumentFormat
 	ToFormat   internal.DocumentFormat
+	Name       string // Registered converter name
 	Converter  internal.Converter
+	Priority   int              // Configured converter priority
+	Speed      float64          // Relative speed for the planning mode (1 when unrated)
+	Quality    float64          // Relative quality for the planning mode (1 when unrated)
+	Cost       float64          // Planner cost of this step; lower is better
+	Fallbacks  []ConversionStep // Next-ranked converters for the same pair, tried in order if this one fails
 }
 
 // NewFactory creates a new converter factory
 func NewFactory() *Factory {
 	return &Factory{
 		converters: make(map[string]internal.Converter),
+		priorities: make(map[string]int),
+		limiter:    scheduler.NewLimiter(nil),
+		overwrite:  true,
 	}
 }
 
+// SetConcurrencyLimit caps how many conversions a named converter may run at once
+// limit <= 0 means unlimited
+func (f *Factory) SetConcurrencyLimit(name string, limit int) {
+	f.limiter.SetLimit(name, limit)
+}
+
+// SetMetadataAnnotator enables carrying source metadata over to converted outputs
+// for conversions with PreserveMetadata set
+func (f *Factory) SetMetadataAnnotator(annotator *metadata.Annotator) {
+	f.metadata = annotator
+}
+
+// SetPriority ranks a named converter against others handling the same format pair
+// Higher priorities are tried first; equal priorities are ranked by planner cost, then name
+func (f *Factory) SetPriority(name string, priority int) {
+	f.priorities[name] = priority
+}
+
 // Register registers a converter for specific formats
 func (f *Factory) Register(name string, converter internal.Converter) {
 	f.converters[name] = converter
 }
 
+// Close releases what registered converters hold between conversions, such as
+// helper processes running in serve mode
+func (f *Factory) Close() error {
+	var errs []error
+	for _, name := range f.names() {
+		if closer, ok := f.converters[name].(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// names lists the registered converter names, sorted
+func (f *Factory) names() []string {
+	names := make([]string, 0, len(f.converters))
+	for name := range f.converters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // LoadFromConfig loads converters from configuration
 func (f *Factory) LoadFromConfig(cfg *config.ConverterConfig) error {
 	for name, tool := range cfg.Converters {
@@ -47,11 +124,15 @@ func (f *Factory) LoadFromConfig(cfg *config.ConverterConfig) error {
 
 		converter := generic.NewConverter(name, tool, cfg.Profiles)
 		f.Register(name, converter)
+		f.SetConcurrencyLimit(name, tool.MaxConcurrent)
+		f.SetPriority(name, tool.Priority)
 
 		log.Debug().
 			Str("converter", name).
 			Strs("input", tool.Formats.Input).
 			Strs("output", tool.Formats.Output).
+			Int("max_concurrent", tool.MaxConcurrent).
+			Int("priority", tool.Priority).
 			Msg("Registered converter from config")
 	}
 	return nil
@@ -59,191 +140,233 @@ func (f *Factory) LoadFromConfig(cfg *config.ConverterConfig) error {
 
 // GetConverter returns a converter that supports the given formats
 func (f *Factory) GetConverter(inputFormat, outputFormat internal.DocumentFormat) (internal.Converter, error) {
-	// Try to find a converter that supports both formats
-	for _, converter := range f.converters {
-		canConvert := false
-		for _, inFmt := range converter.SupportedInputFormats() {
-			if inFmt == inputFormat {
-				for _, outFmt := range converter.SupportedOutputFormats() {
-					if outFmt == outputFormat {
-						canConvert = true
-						break
-					}
-				}
-				break
-			}
-		}
-		if canConvert {
-			return converter, nil
-		}
+	_, converter, err := f.findConverter(inputFormat, outputFormat)
+	return converter, err
+}
+
+// findConverter returns the name and converter best suited to a direct conversion
+func (f *Factory) findConverter(inputFormat, outputFormat internal.DocumentFormat) (string, internal.Converter, error) {
+	step, ok := f.graph(helper.ModeNormal).best(inputFormat, outputFormat)
+	if !ok {
+		return "", nil, internal.ErrUnsupportedConversion
 	}
+	return step.Name, step.Converter, nil
+}
 
-	return nil, internal.ErrUnsupportedConversion
+// SetOutputValidation enables checking that every step's output parses as its
+// format (see validate.Output); an invalid output counts as a failed step
+func (f *Factory) SetOutputValidation(enabled bool) {
+	f.validate = enabled
 }
 
-// Convert performs document conversion using the appropriate converter
-// If no direct converter is available, it will attempt a pipeline conversion via HTML
-func (f *Factory) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
-	// Try direct conversion first
-	converter, err := f.GetConverter(opts.InputFormat, opts.OutputFormat)
-	if err == nil {
-		log.Debug().
-			Str("from", string(opts.InputFormat)).
-			Str("to", string(opts.OutputFormat)).
-			Msg("Using direct conversion")
-		return converter.Convert(ctx, input, output, opts)
+// runStep executes a conversion step, falling back to the next-ranked converter
+// for the same format pair when one fails or, with validation, writes an invalid output
+func (f *Factory) runStep(ctx context.Context, step ConversionStep, input, output string, opts internal.ConversionOptions) error {
+	err := f.runChecked(ctx, step, input, output, opts)
+	for _, fallback := range step.Fallbacks {
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		log.Warn().
+			Err(err).
+			Str("converter", step.Name).
+			Str("fallback", fallback.Name).
+			Str("conversion", fmt.Sprintf("%s → %s", step.FromFormat, step.ToFormat)).
+			Msg("Converter failed, trying next-ranked converter")
+		step = fallback
+		err = f.runChecked(ctx, step, input, output, opts)
+	}
+	return err
+}
+
+// runChecked runs the converter of a step and validates its output when enabled
+// An invalid output is removed so that it cannot pass for a result
+func (f *Factory) runChecked(ctx context.Context, step ConversionStep, input, output string, opts internal.ConversionOptions) error {
+	if err := f.runConverter(ctx, step.Name, step.Converter, input, output, opts); err != nil {
+		return err
 	}
+	return f.checkStepOutput(step, output)
+}
 
-	// No direct converter found, try pipeline via HTML
-	log.Debug().
-		Str("from", string(opts.InputFormat)).
-		Str("to", string(opts.OutputFormat)).
-		Msg("No direct converter found, attempting pipeline via HTML")
+// checkStepOutput validates the output of a finished step when validation is enabled
+func (f *Factory) checkStepOutput(step ConversionStep, output string) error {
+	if !f.validate {
+		return nil
+	}
+	if err := validate.Output(output, step.ToFormat); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("%s produced %w", step.Name, err)
+	}
+	return nil
+}
 
-	pipeline, err := f.buildPipeline(opts.InputFormat, opts.OutputFormat)
+// runConverter executes one conversion, waiting for a free slot if the converter is capped
+func (f *Factory) runConverter(ctx context.Context, name string, converter internal.Converter, input, output string, opts internal.ConversionOptions) error {
+	release, err := f.limiter.Acquire(ctx, name)
 	if err != nil {
-		return err
+		return fmt.Errorf("waiting for converter %s: %w", name, err)
 	}
+	defer release()
 
-	return f.executePipeline(ctx, input, output, opts, pipeline)
+	return converter.Convert(ctx, input, output, opts)
 }
 
-// buildPipeline attempts to build a conversion pipeline via intermediate formats
-func (f *Factory) buildPipeline(from, to internal.DocumentFormat) ([]ConversionStep, error) {
-	// Try different intermediate formats in order of preference
-	// Priority: PDF/PS (preserves structure) > HTML
-	// NOTE: TXT is NOT included as it loses all document structure
-	intermediateFormats := []internal.DocumentFormat{
-		internal.FormatPDF,  // Best: Preserves full document structure
-		internal.FormatPS,   // PostScript: Good structure preservation
-		internal.FormatHTML, // Good: HTML preserves structure
+// Convert performs document conversion using the appropriate converter
+// When no single converter can do it, a multi-step pipeline is planned
+func (f *Factory) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	_, err := f.ConvertWithReport(ctx, input, output, opts)
+	return err
+}
+
+// ConvertWithReport converts like Convert and reports what happened to the source metadata
+// The output is written to a temp file next to it and renamed into place on success.
+// Metadata is read before conversion, since intermediate tools routinely drop it, and
+// re-applied to the final output (or its sidecar); failing to do so is not fatal
+func (f *Factory) ConvertWithReport(ctx context.Context, input, output string, opts internal.ConversionOptions) (*ConversionReport, error) {
+	report := &ConversionReport{}
+	if err := f.checkOutput(output); err != nil {
+		return report, err
 	}
 
-	// Try direct 2-step conversion
-	for _, intermediate := range intermediateFormats {
-		pipeline, err := f.try2StepPipeline(from, to, intermediate)
-		if err == nil {
-			log.Info().
-				Str("from", string(from)).
-				Str("to", string(to)).
-				Str("via", string(intermediate)).
-				Int("steps", len(pipeline)).
-				Msg("Built 2-step conversion pipeline")
-			return pipeline, nil
-		}
+	var source *internal.DocumentMetadata
+	if opts.PreserveMetadata && f.metadata != nil {
+		source = f.metadata.Read(ctx, input, opts.InputFormat)
 	}
 
-	// Try multi-step conversion (up to 4 steps)
-	pipeline, err := f.tryMultiStepPipeline(from, to, intermediateFormats, 4)
-	if err == nil {
-		// Build via description
-		var via []string
-		for _, step := range pipeline {
-			via = append(via, string(step.ToFormat))
+	err := f.writeOutput(output, func(tmp string) error {
+		return f.convertCached(ctx, input, tmp, opts, report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if source != nil {
+		result, err := f.metadata.Preserve(ctx, output, opts.OutputFormat, source)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("output", output).
+				Msg("Failed to carry metadata over to output")
+			return report, nil
+		}
+		report.Metadata = result
+		if len(result.Skipped) > 0 {
+			log.Info().
+				Str("output", output).
+				Strs("fields", result.Skipped).
+				Str("sidecar", result.Sidecar).
+				Msg("Some metadata could not be embedded in output")
 		}
-		log.Info().
-			Str("from", string(from)).
-			Str("to", string(to)).
-			Str("via", strings.Join(via[:len(via)-1], "→")).
-			Int("steps", len(pipeline)).
-			Msg("Built multi-step conversion pipeline")
-		return pipeline, nil
 	}
 
-	return nil, fmt.Errorf("no conversion pipeline found for %s → %s (requires additional converters)", from, to)
+	return report, nil
 }
 
-// try2StepPipeline attempts to build a 2-step pipeline via an intermediate format
-func (f *Factory) try2StepPipeline(from, to, intermediate internal.DocumentFormat) ([]ConversionStep, error) {
-	// Step 1: from → intermediate
-	converter1, err := f.GetConverter(from, intermediate)
-	if err != nil {
-		return nil, err
+// convert runs OCR when requested, otherwise a regular conversion that falls back
+// to OCR when a text target comes out empty
+func (f *Factory) convert(ctx context.Context, input, output string, opts internal.ConversionOptions, report *ConversionReport) error {
+	if opts.OCR {
+		report.OCR = true
+		return f.convertWithOCR(ctx, input, output, opts)
 	}
 
-	// Step 2: intermediate → to
-	converter2, err := f.GetConverter(intermediate, to)
-	if err != nil {
-		return nil, err
+	if err := f.convertDocument(ctx, input, output, opts); err != nil {
+		return err
 	}
 
-	return []ConversionStep{
-		{FromFormat: from, ToFormat: intermediate, Converter: converter1},
-		{FromFormat: intermediate, ToFormat: to, Converter: converter2},
-	}, nil
+	if opts.OCRFallback && isTextFormat(opts.OutputFormat) {
+		return f.ocrFallback(ctx, input, output, opts, report)
+	}
+	return nil
 }
 
-// tryMultiStepPipeline attempts to build a multi-step pipeline using BFS
-// maxSteps limits the search depth (e.g., 4 for up to 4-step pipelines)
-func (f *Factory) tryMultiStepPipeline(from, to internal.DocumentFormat, intermediates []internal.DocumentFormat, maxSteps int) ([]ConversionStep, error) {
-	// Use breadth-first search to find shortest path
-	type node struct {
-		format   internal.DocumentFormat
-		path     []ConversionStep
-		distance int
+// convertDocument plans the cheapest route (a single converter or a pipeline), or
+// takes the one forced by opts.Via, and runs it
+func (f *Factory) convertDocument(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	pipeline, err := f.route(opts.InputFormat, opts.OutputFormat, opts)
+	if err != nil {
+		return err
 	}
 
-	queue := []node{{format: from, path: nil, distance: 0}}
-	visited := make(map[internal.DocumentFormat]bool)
-	visited[from] = true
-
-	for len(queue) > 0 {
-		current := queue[0]
-		queue = queue[1:]
-
-		// Stop if we've exceeded max steps
-		if current.distance >= maxSteps {
-			continue
-		}
+	if len(pipeline) == 1 {
+		step := pipeline[0]
+		log.Debug().
+			Str("from", string(opts.InputFormat)).
+			Str("to", string(opts.OutputFormat)).
+			Str("converter", step.Name).
+			Msg("Using direct conversion")
+		return f.runStep(ctx, step, input, output, opts)
+	}
 
-		// Try all possible next formats (intermediates + target)
-		candidates := append([]internal.DocumentFormat{to}, intermediates...)
-		for _, nextFormat := range candidates {
-			// Skip if already visited
-			if visited[nextFormat] {
-				continue
-			}
+	var via []string
+	for _, step := range pipeline[:len(pipeline)-1] {
+		via = append(via, string(step.ToFormat))
+	}
+	log.Info().
+		Str("from", string(opts.InputFormat)).
+		Str("to", string(opts.OutputFormat)).
+		Str("via", strings.Join(via, "→")).
+		Int("steps", len(pipeline)).
+		Msg("Built conversion pipeline")
 
-			// Try to find converter for current → next
-			converter, err := f.GetConverter(current.format, nextFormat)
-			if err != nil {
-				continue // No converter available
-			}
+	return f.executePipeline(ctx, input, output, opts, pipeline)
+}
 
-			// Build new path
-			newPath := make([]ConversionStep, len(current.path))
-			copy(newPath, current.path)
-			newPath = append(newPath, ConversionStep{
-				FromFormat: current.format,
-				ToFormat:   nextFormat,
-				Converter:  converter,
-			})
-
-			// Check if we reached the target
-			if nextFormat == to {
-				return newPath, nil
-			}
+// route returns the pipeline forced by opts.Via, or else the cheapest one
+func (f *Factory) route(from, to internal.DocumentFormat, opts internal.ConversionOptions) ([]ConversionStep, error) {
+	if opts.Via != "" {
+		return f.viaPipeline(from, to, opts.Via, opts.Quality)
+	}
+	return f.buildPipeline(from, to, opts.Quality)
+}
 
-			// Add to queue for further exploration
-			visited[nextFormat] = true
-			queue = append(queue, node{
-				format:   nextFormat,
-				path:     newPath,
-				distance: current.distance + 1,
-			})
-		}
+// plannedRoute returns every step a conversion will run: the OCR step followed by
+// the route from its text when opts.OCR is set, else the route itself
+func (f *Factory) plannedRoute(opts internal.ConversionOptions) ([]ConversionStep, error) {
+	if !opts.OCR {
+		return f.route(opts.InputFormat, opts.OutputFormat, opts)
+	}
+	if f.ocr == nil {
+		return nil, fmt.Errorf("%w: OCR requested but no OCR engine is configured", internal.ErrUnsupportedConversion)
 	}
 
-	return nil, fmt.Errorf("no multi-step pipeline found")
+	steps := []ConversionStep{f.ocrStep(opts.InputFormat)}
+	if opts.OutputFormat == internal.FormatTXT {
+		return steps, nil
+	}
+	rest, err := f.route(internal.FormatTXT, opts.OutputFormat, opts)
+	if err != nil {
+		return nil, fmt.Errorf("converting OCR text to %s: %w", opts.OutputFormat, err)
+	}
+	return append(steps, rest...), nil
 }
 
 // executePipeline executes a multi-step conversion pipeline
+// With the cache enabled, intermediate outputs are stored by content and route,
+// and the pipeline resumes after the longest prefix that is already stored, so
+// conversions of one source to several targets share their common steps
 func (f *Factory) executePipeline(ctx context.Context, input, output string, opts internal.ConversionOptions, pipeline []ConversionStep) error {
 	currentInput := input
 	var tempFiles []string
+	defer func() {
+		// Clean up intermediate temp files (but not the final output)
+		for _, tempFile := range tempFiles {
+			log.Debug().Str("file", tempFile).Msg("Removing intermediate temp file")
+			os.Remove(tempFile)
+		}
+	}()
+
+	artifacts := f.newArtifacts(input, opts)
+	start := 0
+	if reused, path, ok := artifacts.resume(pipeline); ok {
+		start, currentInput = reused, path
+		tempFiles = append(tempFiles, path)
+		artifacts.keepReused(input, pipeline[:reused])
+	}
 
 	// Execute each step in the pipeline
-	for i, step := range pipeline {
+	for i := start; i < len(pipeline); i++ {
+		step := pipeline[i]
 		var currentOutput string
 
 		if i == len(pipeline)-1 {
@@ -251,12 +374,11 @@ func (f *Factory) executePipeline(ctx context.Context, input, output string, opt
 			currentOutput = output
 		} else {
 			// Intermediate step: create temp file
-			tempFile, err := os.CreateTemp("", fmt.Sprintf("yakateka-pipeline-*.%s", step.ToFormat))
+			tempFile, err := newTempFile(step.ToFormat)
 			if err != nil {
 				return fmt.Errorf("failed to create temp file for pipeline step %d: %w", i+1, err)
 			}
-			currentOutput = tempFile.Name()
-			tempFile.Close()
+			currentOutput = tempFile
 			tempFiles = append(tempFiles, currentOutput)
 		}
 
@@ -275,28 +397,24 @@ func (f *Factory) executePipeline(ctx context.Context, input, output string, opt
 		stepOpts.OutputFormat = step.ToFormat
 
 		// Execute conversion
-		err := step.Converter.Convert(ctx, currentInput, currentOutput, stepOpts)
+		err := f.runStep(ctx, step, currentInput, currentOutput, stepOpts)
 		if err != nil {
-			// Clean up temp files on error
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
 			return fmt.Errorf("pipeline step %d failed (%s → %s): %w",
 				i+1, step.FromFormat, step.ToFormat, err)
 		}
 
+		if i < len(pipeline)-1 {
+			artifacts.store(pipeline[:i+1], currentOutput)
+			f.keepIntermediate(input, i, step, currentOutput)
+		}
+
 		// Next step's input is current output
 		currentInput = currentOutput
 	}
 
-	// Clean up intermediate temp files (but not the final output)
-	for _, tempFile := range tempFiles {
-		log.Debug().Str("file", tempFile).Msg("Removing intermediate temp file")
-		os.Remove(tempFile)
-	}
-
 	log.Info().
 		Int("steps", len(pipeline)).
+		Int("reused", start).
 		Str("input", input).
 		Str("output", output).
 		Msg("Pipeline conversion completed successfully")
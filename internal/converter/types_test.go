@@ -2,9 +2,18 @@ package converter
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/metadata"
 )
 
 // mockConverter is a mock converter for testing
@@ -112,3 +121,347 @@ func TestFactoryConvert(t *testing.T) {
 		t.Error("Expected error for unsupported conversion")
 	}
 }
+
+// slowConverter tracks how many conversions run at once
+type slowConverter struct {
+	mockConverter
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (s *slowConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	atomic.AddInt32(&s.inFlight, -1)
+	return nil
+}
+
+func TestFactoryConcurrencyLimit(t *testing.T) {
+	factory := NewFactory()
+
+	slow := &slowConverter{mockConverter: mockConverter{
+		inputFormats:  []internal.DocumentFormat{internal.FormatDOCX},
+		outputFormats: []internal.DocumentFormat{internal.FormatPDF},
+	}}
+	factory.Register("libreoffice", slow)
+	factory.SetConcurrencyLimit("libreoffice", 1)
+
+	opts := internal.ConversionOptions{
+		InputFormat:  internal.FormatDOCX,
+		OutputFormat: internal.FormatPDF,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := factory.Convert(context.Background(), "in.docx", "out.pdf", opts); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if slow.maxInFlight != 1 {
+		t.Errorf("Expected at most 1 concurrent conversion, got %d", slow.maxInFlight)
+	}
+}
+
+// writingConverter writes fixed content to the output, like a tool that drops metadata
+type writingConverter struct {
+	mockConverter
+	content string
+}
+
+func (w *writingConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	return os.WriteFile(output, []byte(w.content), 0644)
+}
+
+func TestFactoryValidatesOutput(t *testing.T) {
+	textToHTML := mockConverter{inputFormats: formats(internal.FormatTXT), outputFormats: formats(internal.FormatHTML)}
+	opts := internal.ConversionOptions{InputFormat: internal.FormatTXT, OutputFormat: internal.FormatHTML}
+	dir := t.TempDir()
+
+	factory := NewFactory()
+	factory.SetOutputValidation(true)
+	factory.Register("pandoc", &writingConverter{mockConverter: textToHTML, content: "no markup at all"})
+	factory.SetPriority("pandoc", 10)
+	factory.Register("calibre", &writingConverter{mockConverter: textToHTML, content: "<p>Hello</p>"})
+
+	// The invalid output of the preferred converter is replaced by the fallback's
+	output := filepath.Join(dir, "out.html")
+	if err := factory.Convert(context.Background(), "in.txt", output, opts); err != nil {
+		t.Fatalf("Expected the fallback to succeed, got %v", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "<p>Hello</p>" {
+		t.Errorf("Expected the fallback's output, got %q", data)
+	}
+
+	// Without a valid fallback the conversion fails with the reason, leaving no output
+	factory.Register("calibre", &writingConverter{mockConverter: textToHTML, content: ""})
+	output = filepath.Join(dir, "failed.html")
+	err := factory.Convert(context.Background(), "in.txt", output, opts)
+	if !errors.Is(err, internal.ErrInvalidOutput) || !strings.Contains(err.Error(), "empty html file") {
+		t.Errorf("Expected an invalid output error with its reason, got %v", err)
+	}
+	if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+		t.Error("Expected no output after a failed validation")
+	}
+}
+
+func TestFactoryPreserveMetadata(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.fb2")
+	source := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook><description><title-info>
+  <author><first-name>Lesya</first-name><last-name>Ukrainka</last-name></author>
+  <book-title>Forest Song</book-title>
+  <lang>uk</lang>
+</title-info></description><body/></FictionBook>`
+	if err := os.WriteFile(input, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	factory := NewFactory()
+	factory.SetMetadataAnnotator(metadata.NewAnnotator(internal.MetadataConfig{Embed: true, Sidecar: true}))
+	factory.Register("fb2", &writingConverter{
+		mockConverter: mockConverter{
+			inputFormats:  []internal.DocumentFormat{internal.FormatFB2},
+			outputFormats: []internal.DocumentFormat{internal.FormatFB2, internal.FormatTXT},
+		},
+		content: "<FictionBook><description><title-info>\n  <lang>en</lang>\n</title-info></description><body/></FictionBook>",
+	})
+
+	opts := internal.ConversionOptions{
+		InputFormat:      internal.FormatFB2,
+		OutputFormat:     internal.FormatFB2,
+		PreserveMetadata: true,
+	}
+
+	// Embeddable target: everything is carried over in place
+	output := filepath.Join(dir, "out.fb2")
+	report, err := factory.ConvertWithReport(context.Background(), input, output, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Metadata == nil || !report.Metadata.Embedded || len(report.Metadata.Skipped) != 0 {
+		t.Fatalf("Expected metadata to be embedded, got %+v", report.Metadata)
+	}
+	data, _ := os.ReadFile(output)
+	for _, want := range []string{"<book-title>Forest Song</book-title>", "<lang>uk</lang>", "<last-name>Ukrainka</last-name>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected output to contain %s:\n%s", want, data)
+		}
+	}
+
+	// Plain text holds nothing: fields are reported and saved to a sidecar
+	opts.OutputFormat = internal.FormatTXT
+	output = filepath.Join(dir, "out.txt")
+	report, err = factory.ConvertWithReport(context.Background(), input, output, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Metadata == nil || report.Metadata.Sidecar != metadata.SidecarPath(output) {
+		t.Fatalf("Expected a sidecar, got %+v", report.Metadata)
+	}
+	if !slices.Equal(report.Metadata.Skipped, []string{"title", "author", "language"}) {
+		t.Errorf("Unexpected skipped fields: %v", report.Metadata.Skipped)
+	}
+
+	// Opting out leaves the output alone
+	opts.PreserveMetadata = false
+	output = filepath.Join(dir, "plain.txt")
+	report, err = factory.ConvertWithReport(context.Background(), input, output, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Metadata != nil {
+		t.Errorf("Expected no metadata handling, got %+v", report.Metadata)
+	}
+	if _, err := os.Stat(metadata.SidecarPath(output)); !os.IsNotExist(err) {
+		t.Error("Expected no sidecar when metadata preservation is disabled")
+	}
+}
+
+// mockOCREngine returns fixed text (or err) and records the options it was called with
+type mockOCREngine struct {
+	text  string
+	err   error
+	opts  internal.ExtractionOptions
+	calls int
+}
+
+func (m *mockOCREngine) ExtractText(ctx context.Context, input string, opts internal.ExtractionOptions) (string, error) {
+	m.opts = opts
+	m.calls++
+	return m.text, m.err
+}
+
+func (m *mockOCREngine) SupportedLanguages() []string {
+	return []string{"eng"}
+}
+
+func (m *mockOCREngine) SupportedInputFormats() []internal.DocumentFormat {
+	return []internal.DocumentFormat{internal.FormatPDF, internal.FormatDJVU}
+}
+
+func TestFactoryConvertOCR(t *testing.T) {
+	dir := t.TempDir()
+	factory := NewFactory()
+
+	opts := internal.ConversionOptions{
+		InputFormat:  internal.FormatPDF,
+		OutputFormat: internal.FormatTXT,
+		OCR:          true,
+		OCRLanguages: []string{"uk"},
+	}
+
+	// Without an engine OCR requests fail clearly
+	if err := factory.Convert(context.Background(), "scan.pdf", filepath.Join(dir, "a.txt"), opts); err == nil {
+		t.Error("Expected error when no OCR engine is configured")
+	}
+
+	engine := &mockOCREngine{text: "recognised text\n"}
+	factory.SetOCREngine(engine)
+
+	output := filepath.Join(dir, "scan.txt")
+	if err := factory.Convert(context.Background(), "scan.pdf", output, opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, _ := os.ReadFile(output)
+	if string(data) != "recognised text\n" {
+		t.Errorf("Unexpected OCR output %q", data)
+	}
+	if len(engine.opts.OCRLanguages) != 1 || engine.opts.OCRLanguages[0] != "uk" {
+		t.Errorf("Expected OCR languages to be passed through, got %v", engine.opts.OCRLanguages)
+	}
+
+	// Other targets are reached by converting the recognised text
+	factory.Register("text-to-html", &writingConverter{
+		mockConverter: mockConverter{
+			inputFormats:  []internal.DocumentFormat{internal.FormatTXT},
+			outputFormats: []internal.DocumentFormat{internal.FormatHTML},
+		},
+		content: "<p>recognised text</p>",
+	})
+	opts.OutputFormat = internal.FormatHTML
+	output = filepath.Join(dir, "scan.html")
+	if err := factory.Convert(context.Background(), "scan.pdf", output, opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "<p>recognised text</p>" {
+		t.Errorf("Unexpected HTML output %q", data)
+	}
+}
+
+func TestFactoryOCRFallback(t *testing.T) {
+	dir := t.TempDir()
+	scanned := &writingConverter{
+		mockConverter: mockConverter{
+			inputFormats:  []internal.DocumentFormat{internal.FormatPDF, internal.FormatEPUB},
+			outputFormats: []internal.DocumentFormat{internal.FormatTXT},
+		},
+		content: "\f1\f2\f3\f", // Page numbers only, as pdftotext emits for scans
+	}
+	opts := internal.ConversionOptions{
+		InputFormat:  internal.FormatPDF,
+		OutputFormat: internal.FormatTXT,
+		OCRFallback:  true,
+	}
+	output := filepath.Join(dir, "scan.txt")
+
+	// Without an engine the extracted output is kept
+	factory := NewFactory()
+	factory.Register("pdftotext", scanned)
+	report, err := factory.ConvertWithReport(context.Background(), "scan.pdf", output, opts)
+	if err != nil || report.OCR {
+		t.Errorf("Expected extracted output without OCR engine, got err=%v report=%+v", err, report)
+	}
+
+	// With an engine the output is replaced by recognised text
+	engine := &mockOCREngine{text: "Recognised paragraph from the scanned page\n"}
+	factory.SetOCREngine(engine)
+	report, err = factory.ConvertWithReport(context.Background(), "scan.pdf", output, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !report.OCR || !report.OCRFallback {
+		t.Errorf("Expected report to note OCR fallback, got %+v", report)
+	}
+	if data, _ := os.ReadFile(output); string(data) != engine.text {
+		t.Errorf("Expected OCR text in output, got %q", data)
+	}
+
+	// OCR finding nothing either is a distinct failure
+	engine.text = ""
+	_, err = factory.ConvertWithReport(context.Background(), "scan.pdf", output, opts)
+	if !errors.Is(err, internal.ErrNoTextExtracted) {
+		t.Errorf("Expected ErrNoTextExtracted when OCR finds nothing, got %v", err)
+	}
+
+	// So is OCR failing
+	engine.err = errors.New("tesseract crashed")
+	_, err = factory.ConvertWithReport(context.Background(), "scan.pdf", output, opts)
+	if !errors.Is(err, internal.ErrNoTextExtracted) {
+		t.Errorf("Expected ErrNoTextExtracted when OCR fails, got %v", err)
+	}
+	engine.err = nil
+
+	// Inputs the engine cannot read are not sent to OCR
+	engine.calls = 0
+	opts.InputFormat = internal.FormatEPUB
+	_, err = factory.ConvertWithReport(context.Background(), "book.epub", output, opts)
+	if err != nil || engine.calls != 0 {
+		t.Errorf("Expected no OCR attempt for EPUB, got err=%v calls=%d", err, engine.calls)
+	}
+
+	// Real text is left alone
+	scanned.content = "This document has a proper text layer."
+	engine.calls = 0
+	opts.InputFormat = internal.FormatPDF
+	report, err = factory.ConvertWithReport(context.Background(), "doc.pdf", output, opts)
+	if err != nil || report.OCR || engine.calls != 0 {
+		t.Errorf("Expected no OCR for text output, got err=%v report=%+v calls=%d", err, report, engine.calls)
+	}
+}
+
+func TestFactoryOCRFallbackShortText(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "hello.md")
+	if err := os.WriteFile(input, []byte("Hello world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := NewFactory()
+	factory.Register("md-to-txt", &writingConverter{
+		mockConverter: mockConverter{
+			inputFormats:  []internal.DocumentFormat{internal.FormatMD},
+			outputFormats: []internal.DocumentFormat{internal.FormatTXT},
+		},
+		content: "Hello world\n",
+	})
+	engine := &mockOCREngine{text: "Much longer recognised text\n"}
+	factory.SetOCREngine(engine)
+
+	// A short document the engine cannot read is converted as is
+	output := filepath.Join(dir, "hello.txt")
+	opts := internal.ConversionOptions{
+		InputFormat:  internal.FormatMD,
+		OutputFormat: internal.FormatTXT,
+		OCRFallback:  true,
+	}
+	report, err := factory.ConvertWithReport(context.Background(), input, output, opts)
+	if err != nil || report.OCR || engine.calls != 0 {
+		t.Errorf("Expected plain conversion, got err=%v report=%+v calls=%d", err, report, engine.calls)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "Hello world\n" {
+		t.Errorf("Unexpected output %q", data)
+	}
+}
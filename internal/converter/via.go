@@ -0,0 +1,158 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/helper"
+)
+
+// helperSelector is implemented by the helpers converter to force a single helper
+type helperSelector interface {
+	SelectHelper(name string) (internal.Converter, string, bool)
+}
+
+// viaTool is a converter named in ConversionOptions.Via
+type viaTool struct {
+	name      string // As given by the user
+	stepName  string // Name used for logging and concurrency limits
+	converter internal.Converter
+}
+
+// viaPartial is the cheapest forced route found to a format so far
+type viaPartial struct {
+	cost float64
+	path []ConversionStep
+}
+
+// viaPipeline builds the route forced by opts.Via: a converter name from
+// converters.yaml, a helper name or path, or a comma-separated list of those,
+// one step per tool in the given order
+func (f *Factory) viaPipeline(from, to internal.DocumentFormat, via, quality string) ([]ConversionStep, error) {
+	pipeline, reason := f.viaRoute(from, to, via, quality)
+	if reason != "" {
+		return nil, fmt.Errorf("%w: --via %s: %s", internal.ErrUnsupportedConversion, via, reason)
+	}
+	return pipeline, nil
+}
+
+// viaRoute plans a forced route, or explains why the listed tools cannot do it
+// Intermediate formats are whatever one tool writes and the next reads, picked
+// by planner cost when there are several
+func (f *Factory) viaRoute(from, to internal.DocumentFormat, via, quality string) ([]ConversionStep, string) {
+	var tools []viaTool
+	for _, name := range strings.Split(via, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, "empty converter name"
+		}
+		tool, ok := f.resolveVia(name)
+		if !ok {
+			return nil, fmt.Sprintf("no converter or helper named %s (converters: %s)",
+				name, strings.Join(f.names(), ", "))
+		}
+		tools = append(tools, tool)
+	}
+
+	mode := helper.ModeFromQuality(quality)
+	weights := objectiveWeights[mode]
+	reached := map[internal.DocumentFormat]viaPartial{from: {}}
+
+	for i, tool := range tools {
+		last := i == len(tools)-1
+		next := make(map[internal.DocumentFormat]viaPartial)
+		reads := false
+
+		for _, capability := range capabilities(tool.converter, mode) {
+			prev, ok := reached[capability.From]
+			if !ok {
+				continue
+			}
+			reads = true
+			if last && capability.To != to {
+				continue
+			}
+			// Same-format rewrites serve direct requests only
+			if capability.From == capability.To && from != to {
+				continue
+			}
+
+			step := ConversionStep{
+				FromFormat: capability.From,
+				ToFormat:   capability.To,
+				Name:       tool.stepName,
+				Converter:  tool.converter,
+				Priority:   f.priorities[tool.stepName],
+				Speed:      capability.Speed,
+				Quality:    capability.Quality,
+				Cost:       stepCostFor(weights, capability.From, capability.To, capability.Speed, capability.Quality),
+			}
+			path := append(append([]ConversionStep(nil), prev.path...), step)
+			candidate := viaPartial{cost: prev.cost + step.Cost, path: path}
+			if current, ok := next[capability.To]; ok && (current.cost < candidate.cost ||
+				current.cost == candidate.cost && routeKey(current.path) <= routeKey(candidate.path)) {
+				continue
+			}
+			next[capability.To] = candidate
+		}
+
+		if len(next) == 0 {
+			inputs := sortedFormats(reached)
+			switch {
+			case len(tools) == 1:
+				return nil, fmt.Sprintf("%s cannot convert %s → %s", tool.name, from, to)
+			case !reads && i == 0:
+				return nil, fmt.Sprintf("step 1: %s cannot read %s", tool.name, from)
+			case !reads:
+				return nil, fmt.Sprintf("step %d: %s cannot read what %s writes (%s)",
+					i+1, tool.name, tools[i-1].name, strings.Join(inputs, ", "))
+			case last:
+				return nil, fmt.Sprintf("step %d: %s cannot write %s from %s", i+1, tool.name, to, strings.Join(inputs, ", "))
+			default:
+				return nil, fmt.Sprintf("step %d: %s only rewrites %s in place", i+1, tool.name, strings.Join(inputs, ", "))
+			}
+		}
+		reached = next
+	}
+
+	return reached[to].path, ""
+}
+
+// resolveVia looks a --via name up among registered converters, then among helpers
+func (f *Factory) resolveVia(name string) (viaTool, bool) {
+	if conv, ok := f.converters[name]; ok {
+		return viaTool{name: name, stepName: name, converter: conv}, true
+	}
+	for _, registered := range f.names() {
+		selector, ok := f.converters[registered].(helperSelector)
+		if !ok {
+			continue
+		}
+		if conv, path, ok := selector.SelectHelper(name); ok {
+			return viaTool{name: name, stepName: registered + ":" + filepath.Base(path), converter: conv}, true
+		}
+	}
+	return viaTool{}, false
+}
+
+// sortedFormats lists the formats of a forced-route frontier
+func sortedFormats(reached map[internal.DocumentFormat]viaPartial) []string {
+	formats := make([]string, 0, len(reached))
+	for format := range reached {
+		formats = append(formats, string(format))
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// routeCost sums the planner cost of a route
+func routeCost(pipeline []ConversionStep) float64 {
+	var cost float64
+	for _, step := range pipeline {
+		cost += step.Cost
+	}
+	return cost
+}
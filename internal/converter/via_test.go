@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// selectingConverter stands in for the helpers converter, offering one named helper
+type selectingConverter struct {
+	mockConverter
+	helper internal.Converter
+}
+
+func (s *selectingConverter) SelectHelper(name string) (internal.Converter, string, bool) {
+	if name != "calibre-helper" && name != "/opt/helpers/calibre-helper.sh" {
+		return nil, "", false
+	}
+	return s.helper, "/opt/helpers/calibre-helper.sh", true
+}
+
+func viaFactory() *Factory {
+	factory := NewFactory()
+	factory.Register("libreoffice", &mockConverter{
+		inputFormats:  formats(internal.FormatDOC, internal.FormatDOCX),
+		outputFormats: formats(internal.FormatPDF, internal.FormatHTML, internal.FormatDOCX),
+	})
+	factory.Register("pandoc", &mockConverter{
+		inputFormats:  formats(internal.FormatHTML, internal.FormatDOCX, internal.FormatMD),
+		outputFormats: formats(internal.FormatMD, internal.FormatHTML, internal.FormatPDF),
+	})
+	factory.Register("helpers", &selectingConverter{helper: &mockConverter{
+		inputFormats:  formats(internal.FormatEPUB),
+		outputFormats: formats(internal.FormatTXT),
+	}})
+	factory.SetPriority("libreoffice", 20)
+	return factory
+}
+
+func TestViaPipeline(t *testing.T) {
+	factory := viaFactory()
+
+	tests := []struct {
+		from, to internal.DocumentFormat
+		via      string
+		want     string
+	}{
+		// pandoc is forced although libreoffice ranks higher for docx → pdf
+		{internal.FormatDOCX, internal.FormatPDF, "pandoc", "docx→pdf (pandoc)"},
+		{internal.FormatDOC, internal.FormatMD, "libreoffice,pandoc", "doc→docx→md (libreoffice, pandoc)"},
+		{internal.FormatDOC, internal.FormatMD, " libreoffice , pandoc ", "doc→docx→md (libreoffice, pandoc)"},
+		{internal.FormatEPUB, internal.FormatTXT, "calibre-helper", "epub→txt (helpers:calibre-helper.sh)"},
+		{internal.FormatEPUB, internal.FormatTXT, "/opt/helpers/calibre-helper.sh", "epub→txt (helpers:calibre-helper.sh)"},
+	}
+	for _, tt := range tests {
+		pipeline, err := factory.viaPipeline(tt.from, tt.to, tt.via, "")
+		if err != nil {
+			t.Errorf("--via %s: %v", tt.via, err)
+			continue
+		}
+		if got := routeKey(pipeline); got != tt.want {
+			t.Errorf("--via %s: route = %s, want %s", tt.via, got, tt.want)
+		}
+		if len(pipeline[0].Fallbacks) != 0 {
+			t.Errorf("--via %s: forced steps must not fall back", tt.via)
+		}
+	}
+}
+
+func TestViaPipelineErrors(t *testing.T) {
+	factory := viaFactory()
+
+	tests := []struct {
+		from, to internal.DocumentFormat
+		via      string
+		want     string
+	}{
+		{internal.FormatDOC, internal.FormatMD, "calibre", "no converter or helper named calibre"},
+		{internal.FormatDOC, internal.FormatMD, "pandoc", "pandoc cannot convert doc → md"},
+		{internal.FormatDOC, internal.FormatMD, "pandoc,libreoffice", "step 1: pandoc cannot read doc"},
+		{internal.FormatDOC, internal.FormatMD, "libreoffice,calibre-helper", "step 2: calibre-helper cannot read what libreoffice writes (docx, html, pdf)"},
+		{internal.FormatDOC, internal.FormatEPUB, "libreoffice,pandoc", "step 2: pandoc cannot write epub from docx, html, pdf"},
+		{internal.FormatDOC, internal.FormatMD, "libreoffice,,pandoc", "empty converter name"},
+	}
+	for _, tt := range tests {
+		_, err := factory.viaPipeline(tt.from, tt.to, tt.via, "")
+		if !errors.Is(err, internal.ErrUnsupportedConversion) {
+			t.Errorf("--via %s: err = %v, want ErrUnsupportedConversion", tt.via, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("--via %s: err = %q, want it to mention %q", tt.via, err, tt.want)
+		}
+	}
+}
+
+func TestPlanVia(t *testing.T) {
+	factory := viaFactory()
+
+	plan := factory.Plan(internal.ConversionOptions{
+		InputFormat:  internal.FormatDOC,
+		OutputFormat: internal.FormatMD,
+		Via:          "libreoffice,pandoc",
+	})
+	if plan.Route == nil || len(plan.Route.Steps) != 2 || plan.Via != "libreoffice,pandoc" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if len(plan.Candidates) != 0 || len(plan.Alternatives) != 0 {
+		t.Errorf("forced plans list no candidates or alternatives: %+v", plan)
+	}
+
+	plan = factory.Plan(internal.ConversionOptions{
+		InputFormat:  internal.FormatDOC,
+		OutputFormat: internal.FormatMD,
+		Via:          "pandoc",
+	})
+	if plan.Route != nil || plan.Reason != "--via pandoc: pandoc cannot convert doc → md" {
+		t.Errorf("plan = %+v", plan)
+	}
+}
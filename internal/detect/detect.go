@@ -0,0 +1,269 @@
+package detect
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"golang.org/x/net/html/charset"
+)
+
+// Detect sniffs the content of a file and returns its most likely format
+// A zero Result (Known() == false) means the content was not recognised
+func Detect(path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to open file for detection: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Result{}, fmt.Errorf("failed to read file header: %w", err)
+	}
+	header = header[:n]
+
+	// ZIP containers need the whole central directory, not just the header
+	if bytes.HasPrefix(header, []byte("PK\x03\x04")) {
+		return detectZip(path)
+	}
+
+	size := int64(-1)
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	result := detectBytes(header, size)
+
+	log.Debug().
+		Str("file", path).
+		Str("format", string(result.Format)).
+		Float64("confidence", result.Confidence).
+		Str("reason", result.Reason).
+		Msg("Detected format from content")
+
+	return result, nil
+}
+
+// DetectBytes detects a format from the leading bytes of a document
+// ZIP-based formats are reported as unknown since they require container inspection
+func DetectBytes(header []byte) Result {
+	return detectBytes(header, -1)
+}
+
+// detectBytes detects a format from the leading bytes of a document of size
+// bytes (-1 if unknown)
+func detectBytes(header []byte, size int64) Result {
+	for _, sig := range signatures {
+		end := sig.offset + len(sig.magic)
+		if len(header) >= end && bytes.Equal(header[sig.offset:end], sig.magic) {
+			return Result{Format: sig.format, Confidence: sig.confidence, Reason: sig.reason}
+		}
+	}
+
+	// RIFF container with WEBP form type
+	if len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")) {
+		return Result{Format: internal.FormatWEBP, Confidence: ConfidenceExact, Reason: "RIFF WEBP header"}
+	}
+
+	if result := detectBMP(header, size); result.Known() {
+		return result
+	}
+
+	if result := detectMarkup(header); result.Known() {
+		return result
+	}
+
+	// Fallback: anything that decodes as UTF-8 without NUL bytes looks like text
+	if len(header) > 0 && isText(header) {
+		return Result{Format: internal.FormatTXT, Confidence: ConfidenceLow, Reason: "valid UTF-8 text"}
+	}
+
+	return Result{}
+}
+
+// detectBMP checks the BITMAPFILEHEADER: "BM" alone is too common a start for
+// text ("BMW ..."), so the reserved fields must be zero and the DIB header size
+// a known one; the file size field must match too for an exact match
+func detectBMP(header []byte, size int64) Result {
+	if len(header) < 18 || !bytes.HasPrefix(header, []byte("BM")) {
+		return Result{}
+	}
+	if binary.LittleEndian.Uint32(header[6:10]) != 0 || !bmpInfoHeaderSizes[binary.LittleEndian.Uint32(header[14:18])] {
+		return Result{}
+	}
+
+	fileSize := int64(binary.LittleEndian.Uint32(header[2:6]))
+	switch {
+	case size >= 0 && fileSize == size:
+		return Result{Format: internal.FormatBMP, Confidence: ConfidenceExact, Reason: "BMP header"}
+	case size < 0 || fileSize == 0:
+		// Unknown file size, or a writer that left the field out
+		return Result{Format: internal.FormatBMP, Confidence: ConfidenceHigh, Reason: "BMP header"}
+	default:
+		return Result{Format: internal.FormatBMP, Confidence: ConfidenceLow, Reason: "BMP header with a wrong file size"}
+	}
+}
+
+// rootElement returns the local name of the first element of a markup document,
+// skipping its declaration, comments and DOCTYPE; "" if the header has none
+func rootElement(text []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(text))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// detectMarkup checks XML root elements and HTML markers
+func detectMarkup(header []byte) Result {
+	text := bytes.TrimPrefix(header, []byte("\xEF\xBB\xBF")) // UTF-8 BOM
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if len(text) == 0 || text[0] != '<' {
+		return Result{}
+	}
+
+	lower := strings.ToLower(string(text))
+
+	// FB2 root element may follow an XML declaration and comments
+	if rootElement(text) == "FictionBook" {
+		return Result{Format: internal.FormatFB2, Confidence: ConfidenceExact, Reason: "<FictionBook> root element"}
+	}
+
+	if strings.HasPrefix(lower, "<!doctype html") || strings.Contains(lower, "<html") {
+		return Result{Format: internal.FormatHTML, Confidence: ConfidenceHigh, Reason: "<html> element"}
+	}
+
+	return Result{}
+}
+
+// detectZip inspects a ZIP container for EPUB/ODT mimetype entries or an OOXML document part
+func detectZip(path string) (Result, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		// Truncated or corrupted archive - the header was still a ZIP signature
+		log.Debug().Err(err).Str("file", path).Msg("Failed to open ZIP container")
+		return Result{}, nil
+	}
+	defer r.Close()
+
+	hasContentTypes := false
+	for _, file := range r.File {
+		switch file.Name {
+		case "mimetype":
+			mimetype, err := readZipEntry(file, 256)
+			if err != nil {
+				continue
+			}
+			if format, ok := zipMimetypes[strings.TrimSpace(mimetype)]; ok {
+				return Result{Format: format, Confidence: ConfidenceExact, Reason: "mimetype entry " + strings.TrimSpace(mimetype)}, nil
+			}
+		case "[Content_Types].xml":
+			hasContentTypes = true
+		}
+	}
+
+	if hasContentTypes {
+		for _, file := range r.File {
+			if file.Name == "word/document.xml" {
+				return Result{Format: internal.FormatDOCX, Confidence: ConfidenceHigh, Reason: "OOXML word/document.xml part"}, nil
+			}
+		}
+	}
+
+	// EPUBs occasionally lack a proper mimetype entry but always ship container.xml
+	for _, file := range r.File {
+		if file.Name == "META-INF/container.xml" {
+			return Result{Format: internal.FormatEPUB, Confidence: ConfidenceMedium, Reason: "META-INF/container.xml entry"}, nil
+		}
+	}
+
+	return Result{}, nil
+}
+
+// readZipEntry reads at most limit bytes of a ZIP entry
+func readZipEntry(file *zip.File, limit int64) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, limit))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// isText reports whether data looks like UTF-8 text
+// A multi-byte rune cut at the end of the buffer is tolerated
+func isText(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 {
+			return len(data) < utf8.UTFMax && !utf8.FullRune(data)
+		}
+		data = data[size:]
+	}
+	return true
+}
+
+// FromExtension returns the format implied by a file extension
+// Common aliases (htm, markdown, tif, jpeg) are normalised
+func FromExtension(path string) internal.DocumentFormat {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch ext {
+	case "htm", "xhtml":
+		return internal.FormatHTML
+	case "markdown":
+		return internal.FormatMD
+	case "tif":
+		return internal.FormatTIFF
+	case "jpeg":
+		return internal.FormatJPG
+	case "eps":
+		return internal.FormatPS
+	}
+	return internal.DocumentFormat(ext)
+}
+
+// Agrees reports whether an extension-derived format is consistent with a detected result
+// Text-based formats (md, csv, rst, ...) can only be sniffed as plain text, so any
+// extension agrees with a low-confidence text match
+func Agrees(ext internal.DocumentFormat, result Result) bool {
+	if !result.Known() || ext == "" {
+		return true
+	}
+	if normalize(ext) == normalize(result.Format) {
+		return true
+	}
+	return result.Format == internal.FormatTXT && result.Confidence <= ConfidenceLow
+}
+
+// normalize folds format aliases together for comparison
+func normalize(format internal.DocumentFormat) internal.DocumentFormat {
+	if format == internal.FormatJPEG {
+		return internal.FormatJPG
+	}
+	return format
+}
@@ -0,0 +1,201 @@
+package detect
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// writeZip creates a ZIP file with the given entries in order
+func writeZip(t *testing.T, path string, entries [][2]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		fw, err := w.Create(e[0])
+		if err != nil {
+			t.Fatalf("Failed to add zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(e[1])); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+}
+
+func TestDetectBytes(t *testing.T) {
+	mobi := make([]byte, 68)
+	copy(mobi[60:], "BOOKMOBI")
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   internal.DocumentFormat
+	}{
+		{"pdf", []byte("%PDF-1.7\n"), internal.FormatPDF},
+		{"postscript", []byte("%!PS-Adobe-3.0\n"), internal.FormatPS},
+		{"djvu", []byte("AT&TFORM\x00\x00\x00\x00DJVU"), internal.FormatDJVU},
+		{"rtf", []byte(`{\rtf1\ansi`), internal.FormatRTF},
+		{"mobi", mobi, internal.FormatMOBI},
+		{"fb2", []byte(`<?xml version="1.0" encoding="utf-8"?>` + "\n<FictionBook xmlns=\"http://www.gribuser.ru/xml/fictionbook/2.0\">"), internal.FormatFB2},
+		{"fb2 in windows-1251 after a comment", []byte(`<?xml version="1.0" encoding="windows-1251"?>` + "\n<!-- \xea\xed\xe8\xe3\xe0 -->\n<FictionBook>"), internal.FormatFB2},
+		{"html", []byte("\xEF\xBB\xBF<!DOCTYPE html><html><body></body></html>"), internal.FormatHTML},
+		{"html mentioning fb2 in a comment", []byte("<!-- converted from <FictionBook> --><!DOCTYPE html><html><body><p>&lt;FictionBook&gt;</p></body></html>"), internal.FormatHTML},
+		{"png", []byte("\x89PNG\r\n\x1a\n...."), internal.FormatPNG},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), internal.FormatWEBP},
+		{"text", []byte("Привіт, світ!\nHello"), internal.FormatTXT},
+		{"binary", []byte{0x00, 0x01, 0x02, 0x03}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectBytes(tt.header)
+			if got.Format != tt.want {
+				t.Errorf("Expected %q, got %q (reason %q)", tt.want, got.Format, got.Reason)
+			}
+		})
+	}
+}
+
+func TestDetectBytesFB2NeedsRootElement(t *testing.T) {
+	header := []byte(`<?xml version="1.0"?>` + "\n<!-- Catalog of <FictionBook> files -->\n<catalog><book>a.fb2</book></catalog>")
+	if got := DetectBytes(header); got.Format == internal.FormatFB2 {
+		t.Errorf("Expected XML with another root not to be FB2, got %q (reason %q)", got.Format, got.Reason)
+	}
+}
+
+func TestDetectBytesTextIsLowConfidence(t *testing.T) {
+	got := DetectBytes([]byte("# Heading\n\nSome markdown"))
+	if got.Confidence != ConfidenceLow {
+		t.Errorf("Expected low confidence for plain text, got %v", got.Confidence)
+	}
+}
+
+func TestDetectZipContainers(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		entries [][2]string
+		want    internal.DocumentFormat
+	}{
+		{"epub", [][2]string{{"mimetype", "application/epub+zip"}, {"META-INF/container.xml", "<container/>"}}, internal.FormatEPUB},
+		{"odt", [][2]string{{"mimetype", "application/vnd.oasis.opendocument.text"}, {"content.xml", "<office/>"}}, internal.FormatODT},
+		{"docx", [][2]string{{"[Content_Types].xml", "<Types/>"}, {"word/document.xml", "<w:document/>"}}, internal.FormatDOCX},
+		{"epub_without_mimetype", [][2]string{{"META-INF/container.xml", "<container/>"}}, internal.FormatEPUB},
+		{"plain_zip", [][2]string{{"readme.txt", "hello"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Deliberately misleading extension
+			path := filepath.Join(dir, tt.name+".zip")
+			writeZip(t, path, tt.entries)
+
+			got, err := Detect(path)
+			if err != nil {
+				t.Fatalf("Detect failed: %v", err)
+			}
+			if got.Format != tt.want {
+				t.Errorf("Expected %q, got %q (reason %q)", tt.want, got.Format, got.Reason)
+			}
+		})
+	}
+}
+
+// bmpContent builds a 1x1 24-bit BMP with a BITMAPINFOHEADER
+func bmpContent() []byte {
+	data := make([]byte, 58)
+	copy(data, "BM")
+	binary.LittleEndian.PutUint32(data[2:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[10:], 54) // Pixel data offset
+	binary.LittleEndian.PutUint32(data[14:], 40) // DIB header size
+	binary.LittleEndian.PutUint32(data[18:], 1)
+	binary.LittleEndian.PutUint32(data[22:], 1)
+	binary.LittleEndian.PutUint16(data[26:], 1)
+	binary.LittleEndian.PutUint16(data[28:], 24)
+	return data
+}
+
+func TestDetectBMP(t *testing.T) {
+	dir := t.TempDir()
+	truncated := bmpContent()[:40]
+
+	tests := []struct {
+		name       string
+		content    []byte
+		want       internal.DocumentFormat
+		confidence float64
+	}{
+		{"bmp", bmpContent(), internal.FormatBMP, ConfidenceExact},
+		{"bmp with a wrong file size", truncated, internal.FormatBMP, ConfidenceLow},
+		{"text starting with BM", []byte("BMW quarterly report\n\nRevenue grew by 4%.\n"), internal.FormatTXT, ConfidenceLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Detect(path)
+			if err != nil {
+				t.Fatalf("Detect failed: %v", err)
+			}
+			if got.Format != tt.want || got.Confidence != tt.confidence {
+				t.Errorf("Expected %q at %v, got %q at %v (reason %q)", tt.want, tt.confidence, got.Format, got.Confidence, got.Reason)
+			}
+		})
+	}
+}
+
+func TestDetectMissingFile(t *testing.T) {
+	if _, err := Detect(filepath.Join(t.TempDir(), "missing.pdf")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestFromExtension(t *testing.T) {
+	tests := map[string]internal.DocumentFormat{
+		"book.EPUB":      internal.FormatEPUB,
+		"page.htm":       internal.FormatHTML,
+		"notes.markdown": internal.FormatMD,
+		"scan.tif":       internal.FormatTIFF,
+		"photo.jpeg":     internal.FormatJPG,
+		"noext":          "",
+	}
+	for path, want := range tests {
+		if got := FromExtension(path); got != want {
+			t.Errorf("FromExtension(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAgrees(t *testing.T) {
+	pdf := Result{Format: internal.FormatPDF, Confidence: ConfidenceExact}
+	text := Result{Format: internal.FormatTXT, Confidence: ConfidenceLow}
+	jpg := Result{Format: internal.FormatJPG, Confidence: ConfidenceExact}
+
+	if !Agrees(internal.FormatPDF, pdf) {
+		t.Error("Expected pdf extension to agree with pdf content")
+	}
+	if Agrees(internal.FormatDOC, pdf) {
+		t.Error("Expected doc extension to disagree with pdf content")
+	}
+	if !Agrees(internal.FormatMD, text) {
+		t.Error("Expected md extension to agree with plain text content")
+	}
+	if !Agrees(internal.FormatJPEG, jpg) {
+		t.Error("Expected jpeg alias to agree with jpg content")
+	}
+}
@@ -0,0 +1,60 @@
+package detect
+
+import (
+	"github.com/valpere/yakateka/internal"
+)
+
+// Confidence levels reported by the detector
+const (
+	ConfidenceNone   = 0.0  // Nothing recognised
+	ConfidenceLow    = 0.3  // Heuristic guess (e.g. looks like plain text)
+	ConfidenceMedium = 0.6  // Structural hint (e.g. HTML tag near the start)
+	ConfidenceHigh   = 0.9  // Container inspection (e.g. ZIP entry names)
+	ConfidenceExact  = 1.0  // Unambiguous signature (magic bytes, mimetype entry)
+	sniffSize        = 4096 // Number of leading bytes inspected for signatures
+)
+
+// Result describes the outcome of content-based format detection
+type Result struct {
+	Format     internal.DocumentFormat `json:"format"`
+	Confidence float64                 `json:"confidence"`
+	Reason     string                  `json:"reason,omitempty"` // Which signature matched
+}
+
+// Known reports whether a format was recognised
+func (r Result) Known() bool {
+	return r.Format != "" && r.Confidence > ConfidenceNone
+}
+
+// signature is a fixed byte sequence at a fixed offset
+type signature struct {
+	offset     int
+	magic      []byte
+	format     internal.DocumentFormat
+	confidence float64
+	reason     string
+}
+
+// signatures are checked in order; first match wins
+var signatures = []signature{
+	{0, []byte("%PDF-"), internal.FormatPDF, ConfidenceExact, "%PDF- header"},
+	{0, []byte("%!PS"), internal.FormatPS, ConfidenceExact, "%!PS header"},
+	{0, []byte("AT&TFORM"), internal.FormatDJVU, ConfidenceExact, "AT&TFORM header"},
+	{0, []byte(`{\rtf`), internal.FormatRTF, ConfidenceExact, `{\rtf header`},
+	{60, []byte("BOOKMOBI"), internal.FormatMOBI, ConfidenceExact, "BOOKMOBI PDB type"},
+	{0, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, internal.FormatDOC, ConfidenceExact, "OLE2 compound document"},
+	{0, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}, internal.FormatPNG, ConfidenceExact, "PNG signature"},
+	{0, []byte{0xFF, 0xD8, 0xFF}, internal.FormatJPG, ConfidenceExact, "JPEG SOI marker"},
+	{0, []byte("II*\x00"), internal.FormatTIFF, ConfidenceExact, "TIFF little-endian header"},
+	{0, []byte("MM\x00*"), internal.FormatTIFF, ConfidenceExact, "TIFF big-endian header"},
+}
+
+// bmpInfoHeaderSizes are the DIB header sizes of known BMP versions
+// (OS/2 core, BITMAPINFOHEADER, V2/V3 info, V4, V5)
+var bmpInfoHeaderSizes = map[uint32]bool{12: true, 40: true, 56: true, 108: true, 124: true}
+
+// zipMimetypes maps the stored "mimetype" entry of OCF/ODF containers to formats
+var zipMimetypes = map[string]internal.DocumentFormat{
+	"application/epub+zip":                    internal.FormatEPUB,
+	"application/vnd.oasis.opendocument.text": internal.FormatODT,
+}
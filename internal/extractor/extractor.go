@@ -0,0 +1,124 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/detect"
+)
+
+// NewExtractor creates an extractor
+// conv converts non-HTML inputs to HTML; pass nil to accept only HTML and Markdown
+func NewExtractor(conv DocumentConverter) *Extractor {
+	return &Extractor{converter: conv}
+}
+
+// SupportedTypes returns extraction types this extractor supports
+func (e *Extractor) SupportedTypes() []string {
+	return []string{TypeTables, TypeCode, TypeFormulas}
+}
+
+// Extract extracts opts.ExtractType items from a document, detecting its format from content
+func (e *Extractor) Extract(ctx context.Context, input string, opts internal.ExtractionOptions) (interface{}, error) {
+	format := detect.FromExtension(input)
+	if result, err := detect.Detect(input); err == nil && result.Confidence >= detect.ConfidenceMedium {
+		format = result.Format
+	}
+	return e.ExtractFormat(ctx, input, format, opts)
+}
+
+// ExtractFormat extracts opts.ExtractType items from a document of a known format
+func (e *Extractor) ExtractFormat(ctx context.Context, input string, format internal.DocumentFormat, opts internal.ExtractionOptions) (*Result, error) {
+	if _, err := os.Stat(input); err != nil {
+		return nil, fmt.Errorf("%w: %s", internal.ErrInvalidInput, input)
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(opts.ExtractType))
+	switch kind {
+	case TypeTables, TypeCode, TypeFormulas:
+	default:
+		return nil, fmt.Errorf("%w: unknown extraction type %q (use %s)",
+			internal.ErrInvalidInput, opts.ExtractType, strings.Join(e.SupportedTypes(), ", "))
+	}
+
+	result := &Result{Input: input, Format: string(format), Type: kind}
+
+	if format == internal.FormatMD {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", input, err)
+		}
+		doc := parseMarkdown(string(data))
+		switch kind {
+		case TypeTables:
+			result.Tables = doc.tables
+		case TypeCode:
+			result.Code = doc.code
+		case TypeFormulas:
+			result.Formulas = doc.formulas
+		}
+	} else {
+		root, err := e.loadHTML(ctx, input, format)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case TypeTables:
+			result.Tables = htmlTables(root)
+		case TypeCode:
+			result.Code = htmlCode(root)
+		case TypeFormulas:
+			result.Formulas = htmlFormulas(root)
+		}
+	}
+
+	log.Info().
+		Str("input", input).
+		Str("format", string(format)).
+		Str("type", kind).
+		Int("items", result.Count()).
+		Msg("Extraction complete")
+
+	return result, nil
+}
+
+// Count returns the number of extracted items
+func (r *Result) Count() int {
+	return len(r.Tables) + len(r.Code) + len(r.Formulas)
+}
+
+// htmlPath returns an HTML rendition of input, converting it when needed
+// cleanup removes any temporary files and must always be called
+func (e *Extractor) htmlPath(ctx context.Context, input string, format internal.DocumentFormat) (path string, cleanup func(), err error) {
+	if format == internal.FormatHTML {
+		return input, func() {}, nil
+	}
+	if e.converter == nil {
+		return "", func() {}, fmt.Errorf("%w: cannot extract from %s without a converter", internal.ErrUnsupportedFormat, format)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "yakateka-extract-*")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(tmpDir) }
+
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	output := filepath.Join(tmpDir, base+".html")
+	log.Debug().Str("input", input).Str("format", string(format)).Msg("Converting to HTML for extraction")
+
+	opts := internal.ConversionOptions{
+		InputFormat:  format,
+		OutputFormat: internal.FormatHTML,
+	}
+	if err := e.converter.Convert(ctx, input, output, opts); err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("failed to convert %s to HTML: %w", format, err)
+	}
+	return output, cleanup, nil
+}
@@ -0,0 +1,204 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+const sampleHTML = `<!DOCTYPE html>
+<html><body>
+<table>
+  <caption>Prices</caption>
+  <thead><tr><th>Item</th><th>Cost</th></tr></thead>
+  <tbody>
+    <tr><td>Tea</td><td>$5</td></tr>
+    <tr><td colspan="2">Sold out</td></tr>
+  </tbody>
+</table>
+<div class="sourceCode" id="cb1"><pre class="sourceCode python"><code>def greet(name):
+    print(name)</code></pre></div>
+<pre><code>package main
+
+func main() {
+	x := 1
+	fmt.Println(x)
+}</code></pre>
+<p>Energy <span class="math inline">\(E = mc^2\)</span> and</p>
+<span class="math display">\[\int_0^1 x\,dx\]</span>
+<math display="block"><mi>x</mi><mo>=</mo><mn>2</mn></math>
+<p>Costs $5 and $10, code <code>\(not math\)</code>.</p>
+</body></html>`
+
+const sampleMarkdown = "# Notes\n\n" +
+	"| Name | Qty |\n" +
+	"|------|----:|\n" +
+	"| a \\| b | 1 |\n" +
+	"| c | 2 |\n\n" +
+	"```go\nfunc main() {}\n```\n\n" +
+	"~~~\n#!/bin/sh\necho hi\n~~~\n\n" +
+	"    SELECT name FROM users WHERE id = 1;\n\n" +
+	"Inline $a^2 + b^2$ costs $5, not `$x$`.\n\n" +
+	"$$\n\\sum_{i=1}^n i\n$$\n"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func extract(t *testing.T, e *Extractor, path string, format internal.DocumentFormat, kind string) *Result {
+	t.Helper()
+	result, err := e.ExtractFormat(context.Background(), path, format, internal.ExtractionOptions{ExtractType: kind})
+	if err != nil {
+		t.Fatalf("extract %s failed: %v", kind, err)
+	}
+	return result
+}
+
+func TestExtractHTML(t *testing.T) {
+	path := writeFile(t, "page.html", sampleHTML)
+	e := NewExtractor(nil)
+
+	tables := extract(t, e, path, internal.FormatHTML, TypeTables).Tables
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(tables))
+	}
+	want := Table{
+		Index:   1,
+		Caption: "Prices",
+		Header:  []string{"Item", "Cost"},
+		Rows:    [][]string{{"Tea", "$5"}, {"Sold out", "Sold out"}},
+	}
+	if !reflect.DeepEqual(tables[0], want) {
+		t.Errorf("table = %+v, want %+v", tables[0], want)
+	}
+
+	code := extract(t, e, path, internal.FormatHTML, TypeCode).Code
+	if len(code) != 2 {
+		t.Fatalf("got %d code blocks, want 2", len(code))
+	}
+	if code[0].Language != "python" || code[0].Detected {
+		t.Errorf("block 1 language = %q (detected %v), want declared python", code[0].Language, code[0].Detected)
+	}
+	if code[1].Language != "go" || !code[1].Detected {
+		t.Errorf("block 2 language = %q (detected %v), want detected go", code[1].Language, code[1].Detected)
+	}
+	if !strings.HasPrefix(code[0].Code, "def greet(name):") {
+		t.Errorf("block 1 code = %q", code[0].Code)
+	}
+
+	formulas := extract(t, e, path, internal.FormatHTML, TypeFormulas).Formulas
+	if len(formulas) != 3 {
+		t.Fatalf("got %d formulas, want 3: %+v", len(formulas), formulas)
+	}
+	if formulas[0].Source != "E = mc^2" || formulas[0].Display {
+		t.Errorf("formula 1 = %+v", formulas[0])
+	}
+	if formulas[1].Source != `\int_0^1 x\,dx` || !formulas[1].Display {
+		t.Errorf("formula 2 = %+v", formulas[1])
+	}
+	if formulas[2].Notation != NotationMathML || !formulas[2].Display || !strings.Contains(formulas[2].Source, "<mi>x</mi>") {
+		t.Errorf("formula 3 = %+v", formulas[2])
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	path := writeFile(t, "notes.md", sampleMarkdown)
+	e := NewExtractor(nil)
+
+	tables := extract(t, e, path, internal.FormatMD, TypeTables).Tables
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(tables))
+	}
+	if !reflect.DeepEqual(tables[0].Header, []string{"Name", "Qty"}) ||
+		!reflect.DeepEqual(tables[0].Rows, [][]string{{"a | b", "1"}, {"c", "2"}}) {
+		t.Errorf("table = %+v", tables[0])
+	}
+
+	code := extract(t, e, path, internal.FormatMD, TypeCode).Code
+	var langs []string
+	for _, block := range code {
+		langs = append(langs, block.Language)
+	}
+	if !reflect.DeepEqual(langs, []string{"go", "bash", "sql"}) {
+		t.Errorf("code languages = %v, want [go bash sql]", langs)
+	}
+	if code[0].Code != "func main() {}" || code[0].Detected {
+		t.Errorf("fenced block = %+v", code[0])
+	}
+	if !code[1].Detected || !code[2].Detected {
+		t.Error("unlabelled blocks should be marked as detected")
+	}
+
+	formulas := extract(t, e, path, internal.FormatMD, TypeFormulas).Formulas
+	if len(formulas) != 2 {
+		t.Fatalf("got %d formulas, want 2: %+v", len(formulas), formulas)
+	}
+	if formulas[0].Source != "a^2 + b^2" || formulas[0].Display {
+		t.Errorf("inline formula = %+v", formulas[0])
+	}
+	if formulas[1].Source != `\sum_{i=1}^n i` || !formulas[1].Display {
+		t.Errorf("display formula = %+v", formulas[1])
+	}
+}
+
+// htmlConverter writes fixed HTML, standing in for the converter factory
+type htmlConverter struct {
+	calls []internal.ConversionOptions
+}
+
+func (c *htmlConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
+	c.calls = append(c.calls, opts)
+	return os.WriteFile(output, []byte(sampleHTML), 0644)
+}
+
+func TestExtractConvertsToHTML(t *testing.T) {
+	path := writeFile(t, "report.docx", "PK fake")
+
+	if _, err := NewExtractor(nil).ExtractFormat(context.Background(), path, internal.FormatDOCX,
+		internal.ExtractionOptions{ExtractType: TypeTables}); !errors.Is(err, internal.ErrUnsupportedFormat) {
+		t.Errorf("without converter: err = %v, want ErrUnsupportedFormat", err)
+	}
+
+	conv := &htmlConverter{}
+	result := extract(t, NewExtractor(conv), path, internal.FormatDOCX, TypeTables)
+	if len(result.Tables) != 1 || result.Format != "docx" {
+		t.Errorf("result = %+v", result)
+	}
+	if len(conv.calls) != 1 || conv.calls[0].OutputFormat != internal.FormatHTML {
+		t.Errorf("converter calls = %+v", conv.calls)
+	}
+}
+
+func TestExtractInvalidType(t *testing.T) {
+	path := writeFile(t, "page.html", sampleHTML)
+	_, err := NewExtractor(nil).Extract(context.Background(), path, internal.ExtractionOptions{ExtractType: "images"})
+	if !errors.Is(err, internal.ErrInvalidInput) {
+		t.Errorf("err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"#!/usr/bin/env python3\nprint(1)":                             "python",
+		"const x = 1;\nconsole.log(x);":                                "javascript",
+		"fn main() {\n    let mut v = 1;\n    println!(\"{}\", v);\n}": "rust",
+		"#include <stdio.h>\nint main() { printf(\"hi\"); }":           "c",
+		"hello world": "",
+	}
+	for code, want := range tests {
+		if got := detectLanguage(code); got != want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// maxColspan caps colspan expansion so malformed tables cannot blow up row sizes
+const maxColspan = 100
+
+// loadHTML parses the HTML rendition of input
+func (e *Extractor) loadHTML(ctx context.Context, input string, format internal.DocumentFormat) (*html.Node, error) {
+	path, cleanup, err := e.htmlPath(ctx, input, format)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	root, err := html.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid HTML: %v", internal.ErrInvalidInput, err)
+	}
+	return root, nil
+}
+
+// htmlTables returns every <table> in document order, nested tables included
+func htmlTables(root *html.Node) []Table {
+	var tables []Table
+	for _, node := range findAll(root, func(n *html.Node) bool { return n.DataAtom == atom.Table }) {
+		table := Table{Index: len(tables) + 1}
+		hasHeader := false
+		var rows [][]string
+
+		walkTable(node, func(n *html.Node) {
+			switch n.DataAtom {
+			case atom.Caption:
+				table.Caption = textContent(n)
+			case atom.Tr:
+				row, allHeaders := tableRow(n)
+				if len(row) == 0 {
+					return
+				}
+				inHead := n.Parent != nil && n.Parent.DataAtom == atom.Thead
+				if len(rows) == 0 && (inHead || allHeaders) {
+					hasHeader = true
+				}
+				rows = append(rows, row)
+			}
+		})
+
+		if hasHeader {
+			table.Header, rows = rows[0], rows[1:]
+		}
+		table.Rows = rows
+		if table.Rows == nil {
+			table.Rows = [][]string{}
+		}
+		if len(table.Header) > 0 || len(table.Rows) > 0 {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// walkTable visits the descendants of a table without entering nested tables
+func walkTable(table *html.Node, visit func(*html.Node)) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Table {
+				continue
+			}
+			visit(c)
+			if c.DataAtom != atom.Tr && c.DataAtom != atom.Caption {
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+}
+
+// tableRow returns the cell texts of a row and whether every cell is a <th>
+// Cells spanning several columns are repeated so columns stay aligned
+func tableRow(tr *html.Node) ([]string, bool) {
+	var cells []string
+	allHeaders := true
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Td && c.DataAtom != atom.Th {
+			continue
+		}
+		if c.DataAtom == atom.Td {
+			allHeaders = false
+		}
+		text := textContent(c)
+		span, err := strconv.Atoi(attr(c, "colspan"))
+		if err != nil || span < 1 {
+			span = 1
+		}
+		for i := 0; i < min(span, maxColspan); i++ {
+			cells = append(cells, text)
+		}
+	}
+	return cells, allHeaders && len(cells) > 0
+}
+
+// htmlCode returns <pre> blocks with their declared or detected language
+func htmlCode(root *html.Node) []CodeBlock {
+	var blocks []CodeBlock
+	for _, pre := range findAll(root, func(n *html.Node) bool { return n.DataAtom == atom.Pre }) {
+		code := rawText(pre)
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		block := CodeBlock{
+			Index: len(blocks) + 1,
+			Code:  strings.TrimRight(strings.TrimPrefix(code, "\n"), "\n"),
+		}
+
+		// Pandoc puts the language on the surrounding div (class="sourceCode"),
+		// highlighters on <pre> or its <code> child
+		candidates := []*html.Node{pre}
+		if inner := firstChildElement(pre, atom.Code); inner != nil {
+			candidates = append(candidates, inner)
+		}
+		if pre.Parent != nil && pre.Parent.DataAtom == atom.Div {
+			candidates = append(candidates, pre.Parent)
+		}
+		for _, n := range candidates {
+			if lang := declaredLanguage(n); lang != "" {
+				block.Language = lang
+				break
+			}
+		}
+		if block.Language == "" {
+			if lang := detectLanguage(block.Code); lang != "" {
+				block.Language = lang
+				block.Detected = true
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// declaredLanguage reads a language from data-lang or language-*, lang-*,
+// highlight-*, brush: or "sourceCode <lang>" class conventions
+func declaredLanguage(n *html.Node) string {
+	if lang := attr(n, "data-lang"); lang != "" {
+		return normalizeLanguage(lang)
+	}
+	if lang := attr(n, "data-language"); lang != "" {
+		return normalizeLanguage(lang)
+	}
+
+	classes := strings.Fields(attr(n, "class"))
+	for i, class := range classes {
+		for _, prefix := range []string{"language-", "lang-", "highlight-source-", "highlight-", "brush:"} {
+			if rest, ok := strings.CutPrefix(class, prefix); ok && rest != "" {
+				return normalizeLanguage(rest)
+			}
+		}
+		if class == "brush:" && i+1 < len(classes) {
+			return normalizeLanguage(strings.TrimSuffix(classes[i+1], ";"))
+		}
+	}
+	// Pandoc: <div class="sourceCode" id="cb1"><pre class="sourceCode python">
+	if len(classes) >= 2 && classes[0] == "sourceCode" {
+		return normalizeLanguage(classes[1])
+	}
+	return ""
+}
+
+// htmlFormulas returns MathML <math>, MathJax scripts, pandoc math spans and
+// \(..\), \[..\], $$..$$ delimited LaTeX in text
+func htmlFormulas(root *html.Node) []Formula {
+	var formulas []Formula
+	add := func(notation, source string, display bool) {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			return
+		}
+		formulas = append(formulas, Formula{
+			Index:    len(formulas) + 1,
+			Notation: notation,
+			Display:  display,
+			Source:   source,
+		})
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.ElementNode && n.Data == "math":
+			var buf bytes.Buffer
+			if err := html.Render(&buf, n); err == nil {
+				add(NotationMathML, buf.String(), attr(n, "display") == "block")
+			}
+			return
+		case n.DataAtom == atom.Script:
+			kind := attr(n, "type")
+			if strings.HasPrefix(kind, "math/tex") {
+				add(NotationLaTeX, rawText(n), strings.Contains(kind, "mode=display"))
+			}
+			return
+		case n.DataAtom == atom.Pre || n.DataAtom == atom.Code || n.DataAtom == atom.Style:
+			return
+		case n.Type == html.ElementNode && hasClass(n, "math"):
+			// Pandoc: <span class="math inline">\(x\)</span>, <span class="math display">\[x\]</span>
+			display := hasClass(n, "display")
+			source := stripMathDelimiters(textContent(n))
+			add(NotationLaTeX, source, display)
+			return
+		case n.Type == html.TextNode:
+			for _, m := range scanTeXDelimiters(n.Data, false) {
+				add(NotationLaTeX, m.source, m.display)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return formulas
+}
+
+// stripMathDelimiters removes one pair of \(..\), \[..\], $$..$$ or $..$ delimiters
+func stripMathDelimiters(s string) string {
+	s = strings.TrimSpace(s)
+	for _, pair := range [][2]string{{`\(`, `\)`}, {`\[`, `\]`}, {"$$", "$$"}, {"$", "$"}} {
+		if len(s) >= len(pair[0])+len(pair[1]) && strings.HasPrefix(s, pair[0]) && strings.HasSuffix(s, pair[1]) {
+			return strings.TrimSpace(s[len(pair[0]) : len(s)-len(pair[1])])
+		}
+	}
+	return s
+}
+
+// findAll returns matching elements in document order
+func findAll(root *html.Node, match func(*html.Node) bool) []*html.Node {
+	var nodes []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && match(n) {
+			nodes = append(nodes, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+// textContent returns the text of n with whitespace collapsed
+func textContent(n *html.Node) string {
+	return strings.Join(strings.Fields(rawText(n)), " ")
+}
+
+// rawText returns the text of n verbatim; <br> becomes a newline
+func rawText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			sb.WriteString(n.Data)
+		case n.DataAtom == atom.Br:
+			sb.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// firstChildElement returns the first direct child element with the given tag
+func firstChildElement(n *html.Node, tag atom.Atom) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute, or "" if absent
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasClass reports whether n has the given CSS class
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
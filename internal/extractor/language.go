@@ -0,0 +1,114 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// languageAliases maps common info-string and class spellings to one name
+var languageAliases = map[string]string{
+	"c++":         "cpp",
+	"cxx":         "cpp",
+	"golang":      "go",
+	"js":          "javascript",
+	"node":        "javascript",
+	"py":          "python",
+	"python3":     "python",
+	"rb":          "ruby",
+	"rs":          "rust",
+	"sh":          "bash",
+	"shell":       "bash",
+	"zsh":         "bash",
+	"ts":          "typescript",
+	"yml":         "yaml",
+	"tex":         "latex",
+	"htm":         "html",
+	"xhtml":       "html",
+	"kt":          "kotlin",
+	"cs":          "csharp",
+	"c#":          "csharp",
+	"objective-c": "objc",
+	"ps1":         "powershell",
+}
+
+// notLanguages are classes and info strings that do not name a language
+var notLanguages = map[string]bool{
+	"text":        true,
+	"plain":       true,
+	"plaintext":   true,
+	"none":        true,
+	"nohighlight": true,
+	"numberlines": true,
+}
+
+// normalizeLanguage lowercases a declared language and resolves aliases
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if notLanguages[lang] {
+		return ""
+	}
+	if alias, ok := languageAliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+// languageRule scores a language by the patterns its code tends to contain
+type languageRule struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+// shebangPattern captures the interpreter of a #! line
+var shebangPattern = regexp.MustCompile(`^#!\s*(?:/usr)?/bin/(?:env\s+)?(\w+)`)
+
+// languageRules are checked in order; the first rule with the highest score wins
+var languageRules = []languageRule{
+	{"go", compileAll(`(?m)^package \w+$`, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`, `:= `, `(?m)^import \($`, `\bfmt\.\w+\(`)},
+	{"python", compileAll(`(?m)^\s*def \w+\(.*\):\s*$`, `(?m)^(from [\w.]+ )?import \w+`, `(?m)^\s*(if|for|while|with|class) .*:\s*$`, `\bprint\(`, `\bself\.`, `(?m)^\s*elif `)},
+	{"javascript", compileAll(`\b(const|let|var) \w+ = `, `\bfunction\s*\w*\(`, `=> `, `\bconsole\.log\(`, `\brequire\(['"]`, `(?m)^export (default )?`)},
+	{"java", compileAll(`\bpublic (static )?(class|void|final) `, `\bSystem\.out\.print`, `(?m)^import java\.`, `\bprivate \w+ \w+;`)},
+	{"c", compileAll(`(?m)^#include <\w+\.h>`, `\bint main\(`, `\bprintf\(`, `\bmalloc\(`)},
+	{"cpp", compileAll(`(?m)^#include <\w+>$`, `\bstd::`, `\bcout <<`, `\btemplate <`)},
+	{"rust", compileAll(`\bfn \w+\(`, `\blet mut `, `\bprintln!\(`, `\bimpl \w+`, `(?m)^use \w+::`)},
+	{"ruby", compileAll(`(?m)^\s*def \w+[^:]*$`, `(?m)^\s*end$`, `\bputs `, `(?m)^require ['"]`)},
+	{"php", compileAll(`<\?php`, `\$\w+ = `, `\becho `)},
+	{"bash", compileAll(`(?m)^\s*(if|while) \[\[? `, `(?m)^\s*(fi|done|esac)$`, `(?m)^\s*(echo|export|cd|sudo|apt|apt-get|mkdir|chmod) `, `\$\{?\w+\}?`)},
+	{"sql", compileAll(`(?i)\bselect\b.+\bfrom\b`, `(?i)\binsert into\b`, `(?i)\bcreate table\b`, `(?i)\bwhere\b`)},
+	{"html", compileAll(`(?i)<!doctype html`, `(?i)</?(html|head|body|div|span|p)\b[^>]*>`)},
+	{"json", compileAll(`^\s*[{\[]\s*"`, `"\w+":\s*["{\[\d]`)},
+	{"yaml", compileAll(`(?m)^\w[\w-]*:\s*$`, `(?m)^\s+- \w+`, `(?m)^\w[\w-]*: \S`)},
+	{"latex", compileAll(`\\(begin|end)\{\w+\}`, `\\(section|documentclass|usepackage)\b`)},
+	{"css", compileAll(`(?m)^[.#]?[\w-]+\s*\{`, `(?m)^\s+[\w-]+:\s*[^;]+;\s*$`)},
+}
+
+// compileAll compiles regular expressions at package initialisation
+func compileAll(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = regexp.MustCompile(p)
+	}
+	return compiled
+}
+
+// detectLanguage guesses the programming language of a code block
+// Returns "" when no rule matches at least two patterns (or a shebang)
+func detectLanguage(code string) string {
+	if m := shebangPattern.FindStringSubmatch(code); m != nil {
+		return normalizeLanguage(m[1])
+	}
+
+	best, bestScore := "", 1
+	for _, rule := range languageRules {
+		score := 0
+		for _, p := range rule.patterns {
+			if p.MatchString(code) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = rule.language, score
+		}
+	}
+	return best
+}
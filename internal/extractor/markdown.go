@@ -0,0 +1,261 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// markdownDoc holds everything extracted from a Markdown document in one pass
+type markdownDoc struct {
+	tables   []Table
+	code     []CodeBlock
+	formulas []Formula
+}
+
+var (
+	// delimiterRowPattern matches a pipe table delimiter row: | --- | :-: |
+	delimiterRowPattern = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+	// listItemPattern matches list item markers, whose indented continuations are not code
+	listItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s`)
+
+	// inlineCodePattern matches inline code spans, which cannot contain formulas
+	inlineCodePattern = regexp.MustCompile("`+[^`]*`+")
+)
+
+// parseMarkdown extracts fenced/indented code, pipe tables and $/$$/\(/\[ formulas
+func parseMarkdown(source string) *markdownDoc {
+	doc := &markdownDoc{}
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	var prose []string
+	flushProse := func() {
+		if len(prose) == 0 {
+			return
+		}
+		text := inlineCodePattern.ReplaceAllStringFunc(strings.Join(prose, "\n"), func(s string) string {
+			return strings.Repeat(" ", len(s))
+		})
+		for _, m := range scanTeXDelimiters(text, true) {
+			doc.formulas = append(doc.formulas, Formula{
+				Index:    len(doc.formulas) + 1,
+				Notation: NotationLaTeX,
+				Display:  m.display,
+				Source:   m.source,
+			})
+		}
+		prose = nil
+	}
+	addCode := func(lang, code string) {
+		block := CodeBlock{Index: len(doc.code) + 1, Language: normalizeLanguage(lang), Code: code}
+		if block.Language == "" {
+			if detected := detectLanguage(code); detected != "" {
+				block.Language = detected
+				block.Detected = true
+			}
+		}
+		doc.code = append(doc.code, block)
+	}
+
+	prevBlank := true
+	inList := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+
+		// Fenced code: ``` or ~~~, closed by a fence of the same character at least as long
+		if indent := len(line) - len(trimmed); indent < 4 {
+			if marker, lang, ok := openingFence(trimmed); ok {
+				flushProse()
+				var body []string
+				for i++; i < len(lines); i++ {
+					if closesFence(lines[i], marker) {
+						break
+					}
+					body = append(body, lines[i])
+				}
+				addCode(lang, strings.Join(body, "\n"))
+				prevBlank, inList = false, false
+				continue
+			}
+		}
+
+		// Indented code: four spaces or a tab after a blank line, outside lists
+		if prevBlank && !inList && isIndentedCode(line) {
+			flushProse()
+			var body []string
+			for ; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == "" {
+					body = append(body, "")
+					continue
+				}
+				if !isIndentedCode(lines[i]) {
+					break
+				}
+				body = append(body, strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "    "))
+			}
+			i--
+			addCode("", strings.TrimRight(strings.Join(body, "\n"), "\n"))
+			prevBlank = true
+			continue
+		}
+
+		// Pipe table: header row followed by a delimiter row
+		if strings.Contains(line, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") &&
+			delimiterRowPattern.MatchString(lines[i+1]) {
+			flushProse()
+			table := Table{Index: len(doc.tables) + 1, Header: splitPipeRow(line), Rows: [][]string{}}
+			for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+				table.Rows = append(table.Rows, splitPipeRow(lines[i]))
+			}
+			i--
+			doc.tables = append(doc.tables, table)
+			prevBlank, inList = false, false
+			continue
+		}
+
+		blank := strings.TrimSpace(line) == ""
+		if listItemPattern.MatchString(line) {
+			inList = true
+		} else if !blank && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			inList = false
+		}
+		prose = append(prose, line)
+		prevBlank = blank
+	}
+	flushProse()
+
+	return doc
+}
+
+// openingFence parses a code fence line and returns its marker and info string language
+// Pandoc attribute syntax ({.python .numberLines}) is accepted
+func openingFence(line string) (marker, lang string, ok bool) {
+	for _, ch := range []byte{'`', '~'} {
+		n := 0
+		for n < len(line) && line[n] == ch {
+			n++
+		}
+		if n < 3 {
+			continue
+		}
+		info := strings.TrimSpace(line[n:])
+		if ch == '`' && strings.Contains(info, "`") {
+			return "", "", false // Inline code, not a fence
+		}
+		info = strings.Trim(info, "{}")
+		if fields := strings.Fields(info); len(fields) > 0 {
+			lang = strings.TrimPrefix(fields[0], ".")
+		}
+		return line[:n], lang, true
+	}
+	return "", "", false
+}
+
+// closesFence reports whether line closes a fence opened with marker
+func closesFence(line, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(marker) && strings.Trim(trimmed, marker[:1]) == "" && len(line)-len(strings.TrimLeft(line, " ")) < 4
+}
+
+// isIndentedCode reports whether a non-blank line is indented by four spaces or a tab
+func isIndentedCode(line string) bool {
+	return (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) && strings.TrimSpace(line) != ""
+}
+
+// splitPipeRow splits a table row on unescaped pipes, dropping the outer ones
+func splitPipeRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// texMatch is a delimited LaTeX formula found in text
+type texMatch struct {
+	source  string
+	display bool
+}
+
+// scanTeXDelimiters finds \(..\), \[..\] and $$..$$ formulas in text
+// Single-dollar $..$ math follows pandoc's rules (no space inside the
+// delimiters, no digit after the closing one) and is only scanned when dollars is set
+func scanTeXDelimiters(text string, dollars bool) []texMatch {
+	var matches []texMatch
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], `\(`), strings.HasPrefix(text[i:], `\[`):
+			closing, display := `\)`, false
+			if text[i+1] == '[' {
+				closing, display = `\]`, true
+			}
+			end := strings.Index(text[i+2:], closing)
+			if end < 0 {
+				continue
+			}
+			matches = append(matches, texMatch{strings.TrimSpace(text[i+2 : i+2+end]), display})
+			i += 2 + end + len(closing) - 1
+		case text[i] == '\\' && i+1 < len(text):
+			i++ // Escaped character, e.g. \$
+		case strings.HasPrefix(text[i:], "$$"):
+			end := strings.Index(text[i+2:], "$$")
+			if end < 0 {
+				continue
+			}
+			if source := strings.TrimSpace(text[i+2 : i+2+end]); source != "" {
+				matches = append(matches, texMatch{source, true})
+			}
+			i += 2 + end + 1
+		case dollars && text[i] == '$':
+			if end := closingDollar(text, i+1); end > 0 {
+				matches = append(matches, texMatch{text[i+1 : end], false})
+				i = end
+			}
+		}
+	}
+	return matches
+}
+
+// closingDollar returns the index of the $ closing inline math opened before start, or -1
+func closingDollar(text string, start int) int {
+	if start >= len(text) || text[start] == ' ' || text[start] == '\t' || text[start] == '\n' {
+		return -1
+	}
+	for j := start; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '\n':
+			if j+1 < len(text) && text[j+1] == '\n' {
+				return -1 // Math does not span paragraphs
+			}
+		case '$':
+			prev := text[j-1]
+			if j == start || prev == ' ' || prev == '\t' || prev == '\n' {
+				return -1
+			}
+			if j+1 < len(text) && text[j+1] >= '0' && text[j+1] <= '9' {
+				return -1 // $5 and $10: prices, not math
+			}
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"context"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// Extraction types accepted by ExtractionOptions.ExtractType
+const (
+	TypeTables   = "tables"
+	TypeCode     = "code"
+	TypeFormulas = "formulas"
+)
+
+// Formula notations
+const (
+	NotationLaTeX  = "latex"
+	NotationMathML = "mathml"
+)
+
+// DocumentConverter converts documents to HTML for extraction (converter.Factory)
+type DocumentConverter interface {
+	Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error
+}
+
+// Extractor pulls tables, code blocks and formulas out of documents
+// HTML and Markdown are read directly; other formats are converted to HTML first
+// Implements internal.Extractor
+type Extractor struct {
+	converter DocumentConverter // nil limits extraction to HTML and Markdown
+}
+
+// Result holds the extracted items of one type
+type Result struct {
+	Input    string      `json:"input" yaml:"input"`
+	Format   string      `json:"format" yaml:"format"`
+	Type     string      `json:"type" yaml:"type"`
+	Tables   []Table     `json:"tables,omitempty" yaml:"tables,omitempty"`
+	Code     []CodeBlock `json:"code,omitempty" yaml:"code,omitempty"`
+	Formulas []Formula   `json:"formulas,omitempty" yaml:"formulas,omitempty"`
+}
+
+// Table is a table with its header row split from the body rows
+type Table struct {
+	Index   int        `json:"index" yaml:"index"`
+	Caption string     `json:"caption,omitempty" yaml:"caption,omitempty"`
+	Header  []string   `json:"header,omitempty" yaml:"header,omitempty"`
+	Rows    [][]string `json:"rows" yaml:"rows"`
+}
+
+// CodeBlock is a preformatted code block
+// Detected is set when Language was guessed from the content rather than declared
+type CodeBlock struct {
+	Index    int    `json:"index" yaml:"index"`
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	Detected bool   `json:"detected,omitempty" yaml:"detected,omitempty"`
+	Code     string `json:"code" yaml:"code"`
+}
+
+// Formula is a LaTeX or MathML formula
+type Formula struct {
+	Index    int    `json:"index" yaml:"index"`
+	Notation string `json:"notation" yaml:"notation"`
+	Display  bool   `json:"display,omitempty" yaml:"display,omitempty"`
+	Source   string `json:"source" yaml:"source"`
+}
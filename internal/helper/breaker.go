@@ -0,0 +1,144 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// newBreaker creates a breaker with every circuit closed
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		timeouts:  defaultBreakerTimeouts,
+		cooldown:  cooldown,
+		now:       time.Now,
+		circuits:  make(map[circuitKey]*circuit),
+	}
+}
+
+// configure changes the threshold and cooldown; threshold <= 0 disables the breaker
+func (b *breaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// ready reports whether the helper may be tried: its circuit is closed, or open
+// past the cooldown with no trial running
+func (b *breaker) ready(key circuitKey) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	return !ok || c.openedAt.IsZero() || (!c.trial && b.now().Sub(c.openedAt) >= b.cooldown)
+}
+
+// begin reports whether a conversion may go to the helper, claiming the trial
+// of a half-open circuit; every begin that returns true needs an outcome
+// (success, failure, trip or abandon)
+func (b *breaker) begin(key circuitKey) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok || c.openedAt.IsZero() {
+		return true
+	}
+	if c.trial || b.now().Sub(c.openedAt) < b.cooldown {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// success closes the circuit
+func (b *breaker) success(key circuitKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, key)
+}
+
+// failure counts a failure, opening the circuit at the threshold or when a trial fails
+// It reports whether the circuit is open
+func (b *breaker) failure(key circuitKey) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	c.failures++
+	if c.trial || (b.threshold > 0 && c.failures >= b.threshold) {
+		c.openedAt = b.now()
+	}
+	c.trial = false
+	return !c.openedAt.IsZero()
+}
+
+// setTimeouts changes the timeout threshold; timeouts <= 0 never open a circuit
+func (b *breaker) setTimeouts(timeouts int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeouts = timeouts
+}
+
+// timeout counts a timeout, opening the circuit at the timeout threshold or when
+// a trial times out; a large input is slow with any helper, so timeouts get a
+// threshold of their own, while a helper that hangs on everything is still skipped
+// It reports whether the circuit is open
+func (b *breaker) timeout(key circuitKey) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	c.timeouts++
+	if c.trial || (b.timeouts > 0 && c.timeouts >= b.timeouts) {
+		c.openedAt = b.now()
+	}
+	c.trial = false
+	return !c.openedAt.IsZero()
+}
+
+// trip opens the circuit at once (the helper cannot work, e.g. its tool is missing)
+func (b *breaker) trip(key circuitKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	c.failures++
+	c.openedAt = b.now()
+	c.trial = false
+}
+
+// abandon ends a conversion that says nothing about the helper (it was cancelled)
+func (b *breaker) abandon(key circuitKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		c.trial = false
+	}
+}
+
+// circuit returns the circuit for key, creating a closed one; b.mu must be held
+func (b *breaker) circuit(key circuitKey) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// classify sorts a failed conversion by what it says about the helper
+func classify(err error) failureKind {
+	var helperErr *Error
+	if errors.As(err, &helperErr) {
+		switch helperErr.Class {
+		case ErrorCorruptedInput, ErrorUnsupportedInput:
+			return failureInput
+		case ErrorTransient:
+			return failureTransient
+		case ErrorToolMissing:
+			return failureToolMissing
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return failureTimeout
+	}
+	return failureBroken
+}
@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	key := circuitKey{"helper.sh", "pdf", "txt"}
+	other := circuitKey{"helper.sh", "pdf", "html"}
+
+	// The circuit opens after threshold consecutive failures, for its pair only
+	if !b.begin(key) || b.failure(key) {
+		t.Fatal("Expected the circuit to stay closed after one failure")
+	}
+	if !b.begin(key) || !b.failure(key) {
+		t.Fatal("Expected the circuit to open at the threshold")
+	}
+	if b.ready(key) || b.begin(key) {
+		t.Error("Expected an open circuit to skip the helper")
+	}
+	if !b.ready(other) {
+		t.Error("Expected other pairs of the helper to be unaffected")
+	}
+
+	// After the cooldown one trial is let through; its failure reopens the circuit
+	now = now.Add(time.Minute)
+	if !b.ready(key) || !b.begin(key) {
+		t.Fatal("Expected a trial after the cooldown")
+	}
+	if b.ready(key) || b.begin(key) {
+		t.Error("Expected a single trial at a time")
+	}
+	b.failure(key)
+	if b.ready(key) {
+		t.Error("Expected a failed trial to reopen the circuit")
+	}
+
+	// An abandoned trial frees the slot; a successful one closes the circuit
+	now = now.Add(time.Minute)
+	b.begin(key)
+	b.abandon(key)
+	if !b.begin(key) {
+		t.Fatal("Expected a new trial after an abandoned one")
+	}
+	b.success(key)
+	if !b.begin(key) || b.failure(key) {
+		t.Error("Expected success to close the circuit and reset its failures")
+	}
+
+	// Missing tools open the circuit at once
+	b.trip(other)
+	if b.ready(other) {
+		t.Error("Expected a tripped circuit to be open")
+	}
+}
+
+func TestBreakerTimeouts(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	b.setTimeouts(2)
+	key := circuitKey{"helper.sh", "pdf", "txt"}
+
+	// Timeouts open the circuit at their own threshold
+	if !b.begin(key) || b.timeout(key) {
+		t.Fatal("Expected the circuit to stay closed after one timeout")
+	}
+	if !b.begin(key) || !b.timeout(key) {
+		t.Fatal("Expected the circuit to open at the timeout threshold")
+	}
+
+	// A threshold <= 0 never opens a circuit for timing out
+	b.setTimeouts(0)
+	other := circuitKey{"helper.sh", "pdf", "html"}
+	for i := 0; i < 5; i++ {
+		if !b.begin(other) || b.timeout(other) {
+			t.Fatal("Expected timeouts not to open the circuit")
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want failureKind
+	}{
+		{&Error{Class: ErrorCorruptedInput}, failureInput},
+		{&Error{Class: ErrorUnsupportedInput}, failureInput},
+		{fmt.Errorf("wrapped: %w", &Error{Class: ErrorTransient}), failureTransient},
+		{&Error{Class: ErrorToolMissing}, failureToolMissing},
+		{&Error{Err: context.DeadlineExceeded}, failureTimeout},
+		{&Error{Message: "segfault"}, failureBroken},
+		{errors.New("exit status 1"), failureBroken},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("classify(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,17 +2,29 @@ package helper
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+	"github.com/valpere/yakateka/internal/scheduler"
 )
 
 // HelperConverter uses external helper scripts for conversion
 type HelperConverter struct {
 	cache    *HelperCache
 	executor *Executor
+	limiter  *scheduler.Limiter // Per-helper concurrency caps (keyed by path)
+	only     string             // Restricts conversions to this helper path (empty = all)
+	breaker  *breaker           // Skips helpers that keep failing a conversion pair
+	retries  int                // Retries of a transient failure
+	backoff  time.Duration      // Wait before the first retry, doubled for each next one
 }
 
 // NewHelperConverter creates a converter that uses helper scripts
@@ -20,11 +32,52 @@ func NewHelperConverter(cache *HelperCache, executor *Executor) *HelperConverter
 	return &HelperConverter{
 		cache:    cache,
 		executor: executor,
+		limiter:  scheduler.NewLimiter(nil),
+		breaker:  newBreaker(defaultBreakerThreshold, defaultBreakerCooldown),
+		retries:  defaultRetries,
+		backoff:  defaultRetryBackoff,
 	}
 }
 
+// SetConcurrencyLimits caps concurrent conversions per helper path
+// Helpers without an entry (or with limit <= 0) are unlimited
+func (c *HelperConverter) SetConcurrencyLimits(limits map[string]int) {
+	for path, limit := range limits {
+		c.limiter.SetLimit(path, limit)
+	}
+}
+
+// SetRetryPolicy sets how often a transient helper failure is retried and the
+// backoff before the first retry, which doubles with each retry
+func (c *HelperConverter) SetRetryPolicy(retries int, backoff time.Duration) {
+	c.retries = max(retries, 0)
+	c.backoff = backoff
+}
+
+// SetCircuitBreaker sets the consecutive failures after which a helper is skipped
+// for a conversion pair, and how long until it is tried again
+// A threshold <= 0 only skips helpers whose tools are missing
+func (c *HelperConverter) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker.configure(threshold, cooldown)
+}
+
+// SetTimeoutThreshold sets the consecutive timeouts after which a helper is skipped
+// for a conversion pair; timeouts have their own threshold as large inputs are
+// slow with any helper. A threshold <= 0 never skips a helper for timing out
+func (c *HelperConverter) SetTimeoutThreshold(threshold int) {
+	c.breaker.setTimeouts(threshold)
+}
+
+// Close stops the helper servers started for conversions
+func (c *HelperConverter) Close() error {
+	return c.executor.Close()
+}
+
 // SupportedInputFormats returns all input formats supported by any helper
 func (c *HelperConverter) SupportedInputFormats() []internal.DocumentFormat {
+	c.cache.mu.RLock()
+	defer c.cache.mu.RUnlock()
+
 	formatMap := make(map[internal.DocumentFormat]bool)
 
 	for fromFormat := range c.cache.Conversions {
@@ -41,6 +94,9 @@ func (c *HelperConverter) SupportedInputFormats() []internal.DocumentFormat {
 
 // SupportedOutputFormats returns all output formats supported by any helper
 func (c *HelperConverter) SupportedOutputFormats() []internal.DocumentFormat {
+	c.cache.mu.RLock()
+	defer c.cache.mu.RUnlock()
+
 	formatMap := make(map[internal.DocumentFormat]bool)
 
 	for _, toFormats := range c.cache.Conversions {
@@ -57,6 +113,145 @@ func (c *HelperConverter) SupportedOutputFormats() []internal.DocumentFormat {
 	return formats
 }
 
+// Helpers lists the paths of every helper in the cache, sorted
+func (c *HelperConverter) Helpers() []string {
+	c.cache.mu.RLock()
+	defer c.cache.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, toFormats := range c.cache.Conversions {
+		for _, modes := range toFormats {
+			for _, entries := range modes {
+				for _, entry := range entries {
+					seen[entry.Helper] = true
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ResolveHelper finds a cached helper by path or by name, where the name is the
+// script's file name with or without extension (pandoc-helper.sh, pandoc-helper)
+func (c *HelperConverter) ResolveHelper(name string) (string, bool) {
+	for _, path := range c.Helpers() {
+		if MatchesHelper(path, name) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// MatchesHelper reports whether name refers to the helper at path: its path or
+// its file name with or without extension
+func MatchesHelper(path, name string) bool {
+	abs, _ := filepath.Abs(name)
+	base := filepath.Base(path)
+	switch name {
+	case path, abs, base, strings.TrimSuffix(base, filepath.Ext(base)):
+		return true
+	}
+	return false
+}
+
+// SelectHelper returns a converter restricted to the named helper (see ResolveHelper)
+// along with the helper's path
+func (c *HelperConverter) SelectHelper(name string) (internal.Converter, string, bool) {
+	path, ok := c.ResolveHelper(name)
+	if !ok {
+		return nil, "", false
+	}
+	return &HelperConverter{
+		cache:    c.cache,
+		executor: c.executor,
+		limiter:  c.limiter,
+		only:     path,
+		breaker:  c.breaker,
+		retries:  c.retries,
+		backoff:  c.backoff,
+	}, path, true
+}
+
+// Capabilities returns each conversion offered by an available helper, rated with
+// the metrics of the helper that would be tried first in mode
+// Implements internal.CapabilityProvider
+func (c *HelperConverter) Capabilities(mode string) []internal.ConversionCapability {
+	c.cache.mu.RLock()
+	var pairs [][2]string
+	for from, toFormats := range c.cache.Conversions {
+		for to := range toFormats {
+			pairs = append(pairs, [2]string{from, to})
+		}
+	}
+	c.cache.mu.RUnlock()
+
+	var capabilities []internal.ConversionCapability
+	for _, pair := range pairs {
+		from, to := internal.DocumentFormat(pair[0]), internal.DocumentFormat(pair[1])
+		helpers := c.findHelpers(from, to, ConversionMode(mode))
+		if len(helpers) == 0 {
+			continue
+		}
+		metrics := helpers[0].Metrics()
+		capabilities = append(capabilities, internal.ConversionCapability{
+			From:    from,
+			To:      to,
+			Speed:   metrics.Speed,
+			Quality: metrics.Quality,
+		})
+	}
+	return capabilities
+}
+
+// FindHelpers returns the helpers Convert would try for a conversion in mode, in order
+func (c *HelperConverter) FindHelpers(from, to internal.DocumentFormat, mode string) []CacheEntry {
+	return c.findHelpers(from, to, ConversionMode(mode))
+}
+
+// findHelpers looks up helpers in the cache, keeping only the selected helper if
+// any and leaving out helpers whose circuit for the pair is open
+func (c *HelperConverter) findHelpers(from, to internal.DocumentFormat, mode ConversionMode) []CacheEntry {
+	var helpers []CacheEntry
+	if c.only == "" {
+		helpers = c.cache.FindHelpers(from, to, mode)
+	} else {
+		// The selected helper may only be listed under normal mode
+	selected:
+		for _, m := range []ConversionMode{mode, ModeNormal} {
+			for _, entry := range c.cache.FindHelpers(from, to, m) {
+				if entry.Helper == c.only {
+					helpers = []CacheEntry{entry}
+					break selected
+				}
+			}
+		}
+	}
+
+	ready := helpers[:0]
+	for _, entry := range helpers {
+		if c.breaker.ready(circuitKey{entry.Helper, string(from), string(to)}) {
+			ready = append(ready, entry)
+		}
+	}
+	return ready
+}
+
+// Fingerprint identifies the helper scripts a conversion would try, in order
+// Implements internal.Fingerprinter
+func (c *HelperConverter) Fingerprint(from, to internal.DocumentFormat, mode string) string {
+	var parts []string
+	for _, entry := range c.findHelpers(from, to, ConversionMode(mode)) {
+		parts = append(parts, cache.Fingerprint(entry.Helper))
+	}
+	return "helpers|" + strings.Join(parts, "|")
+}
+
 // Convert performs document conversion using helper scripts
 func (c *HelperConverter) Convert(ctx context.Context, input, output string, opts internal.ConversionOptions) error {
 	// Validate input file exists
@@ -66,16 +261,10 @@ func (c *HelperConverter) Convert(ctx context.Context, input, output string, opt
 	}
 
 	// Determine conversion mode from quality setting
-	mode := ModeNormal
-	switch opts.Quality {
-	case "fast":
-		mode = ModeFast
-	case "high", "quality":
-		mode = ModeQuality
-	}
+	mode := ModeFromQuality(opts.Quality)
 
 	// Find helpers for this conversion
-	helpers := c.cache.FindHelpers(opts.InputFormat, opts.OutputFormat, mode)
+	helpers := c.findHelpers(opts.InputFormat, opts.OutputFormat, mode)
 	if len(helpers) == 0 {
 		log.Debug().
 			Str("from", string(opts.InputFormat)).
@@ -95,8 +284,19 @@ func (c *HelperConverter) Convert(ctx context.Context, input, output string, opt
 
 	// Try each helper in order
 	var lastErr error
+	tried := 0
 	for i, helperEntry := range helpers {
 		helperPath := helperEntry.Helper
+		key := circuitKey{helperPath, string(opts.InputFormat), string(opts.OutputFormat)}
+
+		// Another conversion may have opened the circuit or claimed its trial
+		if !c.breaker.begin(key) {
+			log.Debug().
+				Str("helper", helperPath).
+				Msg("Helper circuit is open, skipping helper")
+			continue
+		}
+		tried++
 
 		log.Info().
 			Str("helper", helperPath).
@@ -105,18 +305,9 @@ func (c *HelperConverter) Convert(ctx context.Context, input, output string, opt
 			Str("mode", string(mode)).
 			Msg("Attempting conversion with helper")
 
-		err := c.executor.Convert(
-			ctx,
-			helperPath,
-			mode,
-			string(opts.InputFormat),
-			input,
-			string(opts.OutputFormat),
-			output,
-		)
-
+		err := c.attempt(ctx, helperPath, mode, input, output, opts)
 		if err == nil {
-			// Success!
+			c.breaker.success(key)
 			log.Info().
 				Str("helper", helperPath).
 				Str("from", string(opts.InputFormat)).
@@ -126,15 +317,53 @@ func (c *HelperConverter) Convert(ctx context.Context, input, output string, opt
 			return nil
 		}
 
-		// Conversion failed
+		// A cancelled conversion says nothing about the helper
+		if ctx.Err() != nil {
+			c.breaker.abandon(key)
+			return err
+		}
+
+		lastErr = err
+		switch kind := classify(err); kind {
+		case failureInput:
+			// The helper works; the input is the problem
+			c.breaker.success(key)
+			if errors.Is(err, internal.ErrInvalidInput) {
+				log.Warn().
+					Err(err).
+					Str("helper", helperPath).
+					Msg("Helper reports corrupted input, not trying other helpers")
+				return err
+			}
+		case failureToolMissing:
+			c.breaker.trip(key)
+		case failureTimeout:
+			if c.breaker.timeout(key) {
+				log.Warn().
+					Str("helper", helperPath).
+					Str("from", string(opts.InputFormat)).
+					Str("to", string(opts.OutputFormat)).
+					Msg("Helper keeps timing out, skipping it for this conversion until the cooldown ends")
+			}
+		default:
+			if c.breaker.failure(key) {
+				log.Warn().
+					Str("helper", helperPath).
+					Str("from", string(opts.InputFormat)).
+					Str("to", string(opts.OutputFormat)).
+					Msg("Helper keeps failing, skipping it for this conversion until the cooldown ends")
+			}
+		}
+
 		log.Warn().
 			Err(err).
 			Str("helper", helperPath).
 			Msg("Helper conversion failed, trying next helper")
+	}
 
-		// Mark this helper as failed for this conversion pair
-		c.cache.MarkHelperFailed(opts.InputFormat, opts.OutputFormat, helperPath)
-		lastErr = err
+	if tried == 0 {
+		return fmt.Errorf("%w: every helper for %s → %s keeps failing, retry after the cooldown",
+			internal.ErrConversionFailed, opts.InputFormat, opts.OutputFormat)
 	}
 
 	// All helpers failed
@@ -142,9 +371,37 @@ func (c *HelperConverter) Convert(ctx context.Context, input, output string, opt
 		Err(lastErr).
 		Str("from", string(opts.InputFormat)).
 		Str("to", string(opts.OutputFormat)).
-		Int("tried", len(helpers)).
+		Int("tried", tried).
 		Msg("All helpers failed")
 
-	return fmt.Errorf("%w: all %d helpers failed, last error: %v",
-		internal.ErrConversionFailed, len(helpers), lastErr)
+	return fmt.Errorf("all %d helpers failed, last error: %w", tried, lastErr)
+}
+
+// attempt runs a conversion with one helper, retrying transient failures with backoff
+func (c *HelperConverter) attempt(ctx context.Context, helperPath string, mode ConversionMode, input, output string, opts internal.ConversionOptions) error {
+	for retry := 0; ; retry++ {
+		release, err := c.limiter.Acquire(ctx, helperPath)
+		if err != nil {
+			return fmt.Errorf("waiting for helper %s: %w", helperPath, err)
+		}
+		err = c.executor.Convert(ctx, helperPath, mode, input, output, opts)
+		release()
+
+		if err == nil || retry >= c.retries || classify(err) != failureTransient {
+			return err
+		}
+
+		delay := c.backoff << retry
+		log.Warn().
+			Err(err).
+			Str("helper", helperPath).
+			Int("retry", retry+1).
+			Dur("backoff", delay).
+			Msg("Transient helper failure, retrying")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
@@ -0,0 +1,153 @@
+//go:build unix || linux || darwin
+
+package helper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// flakyHelper is a protocol v2 helper that fails inputs named *flaky* with a
+// transient error on the first try, *corrupt* ones as corrupted and *broken* ones
+// without a reason; every run is counted in <input>.runs
+const flakyHelper = `#!/bin/sh
+[ "$1" = convert ] || exit 1
+echo run >> "$4.runs"
+case "$4" in
+  *flaky*) [ "$(wc -l < "$4.runs")" -gt 1 ] || { echo "error: transient: index locked" >&2; exit 1; } ;;
+  *corrupt*) echo "error: corrupted_input: truncated" >&2; exit 1 ;;
+  *broken*) echo "segfault" >&2; exit 139 ;;
+esac
+echo primary > "$6"
+`
+
+const fallbackHelper = `#!/bin/sh
+echo fallback > "$6"
+`
+
+// slowHelper never finishes a conversion in time
+const slowHelper = `#!/bin/sh
+exec sleep 5
+`
+
+func TestHelperConverterFailureHandling(t *testing.T) {
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "flaky-helper.sh")
+	fallback := filepath.Join(dir, "fallback-helper.sh")
+	for path, script := range map[string]string{primary: flakyHelper, fallback: fallbackHelper} {
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := &HelperCache{Conversions: map[string]map[string]map[string][]CacheEntry{
+		"md": {"html": {"normal": {
+			{Helper: primary, Weight: 0.9},
+			{Helper: fallback, Weight: 0.5},
+		}}},
+	}}
+	executor := NewExecutor(time.Second)
+	executor.SetHelper(primary, CachedHelper{Protocol: ProtocolV2})
+	converter := NewHelperConverter(cache, executor)
+	converter.SetRetryPolicy(1, time.Millisecond)
+	converter.SetCircuitBreaker(2, time.Minute)
+	now := time.Now()
+	converter.breaker.now = func() time.Time { return now }
+
+	convert := func(name string) (string, int, error) {
+		input := filepath.Join(dir, name)
+		if err := os.WriteFile(input, []byte("# Title\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		output := input + ".html"
+		err := converter.Convert(context.Background(), input, output, mdToHTML)
+		data, _ := os.ReadFile(output)
+		runs, _ := os.ReadFile(input + ".runs")
+		return strings.TrimSpace(string(data)), strings.Count(string(runs), "run"), err
+	}
+
+	// Transient failures are retried with the same helper
+	if out, runs, err := convert("flaky.md"); err != nil || out != "primary" || runs != 2 {
+		t.Errorf("Expected a retried conversion by the primary helper, got %q after %d runs, err %v", out, runs, err)
+	}
+
+	// Corrupted input fails at once, without other helpers or retries
+	_, runs, err := convert("corrupt.md")
+	if !errors.Is(err, internal.ErrInvalidInput) || runs != 1 {
+		t.Errorf("Expected a single run failing as invalid input, got %d runs, err %v", runs, err)
+	}
+
+	// Helper failures fall back, and open the circuit at the threshold
+	for _, name := range []string{"broken1.md", "broken2.md"} {
+		if out, _, err := convert(name); err != nil || out != "fallback" {
+			t.Fatalf("Expected the fallback helper to convert %s, got %q, err %v", name, out, err)
+		}
+	}
+	if out, runs, err := convert("good.md"); err != nil || out != "fallback" || runs != 0 {
+		t.Errorf("Expected the primary helper to be skipped, got %q after %d runs, err %v", out, runs, err)
+	}
+	if helpers := converter.FindHelpers(internal.FormatMD, internal.FormatHTML, "normal"); len(helpers) != 1 || helpers[0].Helper != fallback {
+		t.Errorf("Expected only the fallback helper to be listed, got %v", helpers)
+	}
+
+	// After the cooldown a successful trial closes the circuit
+	now = now.Add(time.Minute)
+	if out, _, err := convert("trial.md"); err != nil || out != "primary" {
+		t.Errorf("Expected a trial with the primary helper, got %q, err %v", out, err)
+	}
+	if helpers := converter.FindHelpers(internal.FormatMD, internal.FormatHTML, "normal"); len(helpers) != 2 {
+		t.Errorf("Expected both helpers after the trial, got %v", helpers)
+	}
+}
+
+func TestHelperConverterTimeoutsOpenCircuit(t *testing.T) {
+	dir := t.TempDir()
+	slow := filepath.Join(dir, "slow-helper.sh")
+	fallback := filepath.Join(dir, "fallback-helper.sh")
+	for path, script := range map[string]string{slow: slowHelper, fallback: fallbackHelper} {
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := &HelperCache{Conversions: map[string]map[string]map[string][]CacheEntry{
+		"md": {"html": {"normal": {
+			{Helper: slow, Weight: 0.9},
+			{Helper: fallback, Weight: 0.5},
+		}}},
+	}}
+	converter := NewHelperConverter(cache, NewExecutor(50*time.Millisecond))
+	converter.SetCircuitBreaker(2, time.Minute)
+	converter.SetTimeoutThreshold(3)
+
+	input := filepath.Join(dir, "large.md")
+	if err := os.WriteFile(input, []byte("# Title\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	convert := func() {
+		t.Helper()
+		if err := converter.Convert(context.Background(), input, input+".html", mdToHTML); err != nil {
+			t.Fatalf("Expected the fallback helper to convert after a timeout, got %v", err)
+		}
+	}
+
+	// Timeouts fall back and count against their own threshold, not the failure one
+	convert()
+	convert()
+	if helpers := converter.FindHelpers(internal.FormatMD, internal.FormatHTML, "normal"); len(helpers) != 2 {
+		t.Errorf("Expected the slow helper to stay listed below the timeout threshold, got %v", helpers)
+	}
+
+	// A helper that keeps timing out is skipped
+	convert()
+	if helpers := converter.FindHelpers(internal.FormatMD, internal.FormatHTML, "normal"); len(helpers) != 1 || helpers[0].Helper != fallback {
+		t.Errorf("Expected the slow helper to be skipped after repeated timeouts, got %v", helpers)
+	}
+}
@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DiscoveryDirs returns the directories helpers are discovered in, first match
+// winning: $YAKATEKA_HELPERS_PATH, ~/.yakateka/helpers.d, /usr/share/yakateka/helpers
+func DiscoveryDirs() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv(HelpersPathEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, userHelpersDir))
+	}
+	return append(dirs, systemHelpersDir)
+}
+
+// Discover finds the executable helpers in dirs and returns their weights by
+// path, leaving out helpers matching a disabled name (see MatchesHelper)
+// A helper found in an earlier directory shadows one with the same file name
+// in a later one; missing directories are skipped
+func Discover(dirs []string, disabled []string) map[string]float64 {
+	helpers := make(map[string]float64)
+	seen := make(map[string]string) // File name -> path of the helper used
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn().Err(err).Str("dir", dir).Msg("Failed to read helper directory")
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			path, err := filepath.Abs(filepath.Join(dir, name))
+			// Manifests are never helpers, even when marked executable
+			if err != nil || strings.HasPrefix(name, ".") || filepath.Ext(name) == ".yaml" || !isExecutable(path) {
+				continue
+			}
+			if used, ok := seen[name]; ok {
+				log.Debug().
+					Str("helper", path).
+					Str("used", used).
+					Msg("Helper shadowed by one found earlier, skipping")
+				continue
+			}
+			seen[name] = path
+			if isDisabled(path, disabled) {
+				log.Debug().Str("helper", path).Msg("Discovered helper is disabled in config")
+				continue
+			}
+
+			helpers[path] = manifestWeight(path)
+			log.Debug().
+				Str("helper", path).
+				Float64("weight", helpers[path]).
+				Msg("Discovered helper")
+		}
+	}
+	return helpers
+}
+
+// isExecutable reports whether path is a regular file (or a link to one) anyone may execute
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// isDisabled reports whether the helper at path matches a disabled name
+func isDisabled(path string, disabled []string) bool {
+	for _, name := range disabled {
+		if MatchesHelper(path, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestWeight returns the weight from the helper's manifest, or the default
+// if it has none
+func manifestWeight(path string) float64 {
+	manifestPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".yaml"
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return defaultDiscoveryWeight
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		log.Warn().
+			Err(err).
+			Str("manifest", manifestPath).
+			Msg("Invalid helper manifest, using the default weight")
+		return defaultDiscoveryWeight
+	}
+	if manifest.Weight <= 0 {
+		return defaultDiscoveryWeight
+	}
+	return manifest.Weight
+}
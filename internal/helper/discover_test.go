@@ -0,0 +1,53 @@
+//go:build unix || linux || darwin
+
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	user, system := t.TempDir(), t.TempDir()
+	files := []struct {
+		dir, name, content string
+		mode               os.FileMode
+	}{
+		{user, "pandoc-helper.sh", "#!/bin/sh\n", 0755},
+		{user, "pandoc-helper.yaml", "weight: 0.9\n", 0755}, // Manifests are skipped even if executable
+		{user, "notes.txt", "not a helper", 0644},
+		{user, ".hidden-helper.sh", "#!/bin/sh\n", 0755},
+		{user, "broken-helper", "#!/bin/sh\n", 0755},
+		{user, "broken-helper.yaml", "weight: [", 0644},
+		{system, "pandoc-helper.sh", "#!/bin/sh\n", 0755}, // Shadowed by the user's
+		{system, "calibre-helper.sh", "#!/bin/sh\n", 0755},
+		{system, "abiword-helper.sh", "#!/bin/sh\n", 0755},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(f.dir, f.name), []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := Discover([]string{user, filepath.Join(user, "missing"), system}, []string{"abiword-helper"})
+	want := map[string]float64{
+		filepath.Join(user, "pandoc-helper.sh"):    0.9,
+		filepath.Join(user, "broken-helper"):       defaultDiscoveryWeight,
+		filepath.Join(system, "calibre-helper.sh"): defaultDiscoveryWeight,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoveryDirs(t *testing.T) {
+	t.Setenv("HOME", "/home/reader")
+	t.Setenv(HelpersPathEnv, "/opt/a::/opt/b")
+
+	want := []string{"/opt/a", "/opt/b", "/home/reader/.yakateka/helpers.d", "/usr/share/yakateka/helpers"}
+	if got := DiscoveryDirs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoveryDirs() = %v, want %v", got, want)
+	}
+}
@@ -4,26 +4,51 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
 	"gopkg.in/yaml.v3"
 )
 
 // Executor executes helper commands
+// Helpers configured with SetHelper are spoken to with their protocol version,
+// and serve-mode ones are started once and reused for conversions; the others
+// are run once per command
 type Executor struct {
 	timeout time.Duration
+	helpers map[string]CachedHelper // By helper path; missing helpers are protocol v1, one-shot
+	servers *serverPool
 }
 
 // NewExecutor creates a new helper executor
 func NewExecutor(timeout time.Duration) *Executor {
 	return &Executor{
 		timeout: timeout,
+		helpers: make(map[string]CachedHelper),
+		servers: &serverPool{
+			idle:    make(map[string][]*server),
+			running: make(map[*server]bool),
+			crashes: make(map[string]int),
+		},
 	}
 }
 
+// SetHelper records a helper's protocol version and serve mode support
+// Call it before conversions start
+func (e *Executor) SetHelper(helperPath string, helper CachedHelper) {
+	e.helpers[helperPath] = helper
+}
+
+// Close stops every helper server; conversions through them fail afterwards
+func (e *Executor) Close() error {
+	e.servers.close()
+	return nil
+}
+
 // Ping checks if helper is available
 // Returns true if exit code == 0 and stdout == "pong"
 func (e *Executor) Ping(ctx context.Context, helperPath string) bool {
@@ -114,44 +139,119 @@ func (e *Executor) GetInfo(ctx context.Context, helperPath string) (*HelperInfo,
 }
 
 // Convert executes a conversion using the helper
-func (e *Executor) Convert(ctx context.Context, helperPath string, mode ConversionMode, fromFormat, fromFile, toFormat, toFile string) error {
-	// Apply timeout only if parent doesn't have a shorter deadline
-	var cancel context.CancelFunc
-	deadline, hasDeadline := ctx.Deadline()
-	timeoutDeadline := time.Now().Add(e.timeout)
-
-	// Only create new context with timeout if needed
-	if !hasDeadline || timeoutDeadline.Before(deadline) {
-		ctx, cancel = context.WithTimeout(ctx, e.timeout)
-		defer cancel()
+// Serve-mode helpers get the request through a running server, falling back to a
+// one-shot run when their server keeps failing to start or crashing. Failures
+// are returned as *Error
+func (e *Executor) Convert(ctx context.Context, helperPath string, mode ConversionMode, input, output string, opts internal.ConversionOptions) error {
+	ctx, cancel := e.conversionContext(ctx)
+	defer cancel()
+
+	helper := e.helpers[helperPath]
+	req := newRequest(helper.protocol(), mode, input, output, opts)
+	if helper.Serve {
+		if !e.servers.broken(helperPath) {
+			return e.convertServed(ctx, helperPath, req)
+		}
+		log.Debug().Str("helper", helperPath).Msg("Helper server keeps failing, running the helper once per conversion")
 	}
 
-	cmd := exec.CommandContext(ctx, helperPath, "convert", string(mode), fromFormat, fromFile, toFormat, toFile)
+	cmd := exec.CommandContext(ctx, helperPath, "convert", req.Mode, req.FromFormat, req.FromFile, req.ToFormat, req.ToFile)
+	stderr := newStderrParser(helperPath, helper.protocol())
+	cmd.Stderr = stderr
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	// Protocol v2 options come as environment variables and a request file
+	if req.Options != nil {
+		requestFile, err := writeRequestFile(req)
+		if err != nil {
+			return &Error{Helper: helperPath, Message: "failed to write request file", Err: err}
+		}
+		defer os.Remove(requestFile)
+		cmd.Env = append(os.Environ(), requestEnv(req, requestFile)...)
+	}
 
-	err := cmd.Run()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		failure := stderr.failure(err)
 		log.Error().
 			Err(err).
 			Str("helper", helperPath).
-			Str("mode", string(mode)).
-			Str("from", fromFormat).
-			Str("to", toFormat).
-			Str("stderr", stderr.String()).
+			Str("mode", req.Mode).
+			Str("from", req.FromFormat).
+			Str("to", req.ToFormat).
+			Str("class", string(failure.Class)).
+			Str("stderr", failure.Message).
 			Msg("Helper conversion failed")
-		return fmt.Errorf("conversion failed: %w - %s", err, stderr.String())
+		return failure
 	}
 
 	log.Info().
 		Str("helper", helperPath).
-		Str("mode", string(mode)).
-		Str("from", fromFormat).
-		Str("to", toFormat).
-		Str("input", fromFile).
-		Str("output", toFile).
+		Str("mode", req.Mode).
+		Str("from", req.FromFormat).
+		Str("to", req.ToFormat).
+		Str("input", input).
+		Str("output", output).
 		Msg("Successfully converted with helper")
 
 	return nil
 }
+
+// conversionContext applies the executor timeout unless the parent has a shorter deadline
+func (e *Executor) conversionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(e.timeout)) {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+// convertServed sends a conversion to a server of the helper
+// A server that crashes or times out is killed; the next request starts a new one
+func (e *Executor) convertServed(ctx context.Context, helperPath string, req serveRequest) error {
+	srv, err := e.servers.get(ctx, helperPath, e.timeout)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("helper", helperPath).
+			Msg("Failed to start helper server")
+		return &Error{Helper: helperPath, Err: err}
+	}
+
+	resp, err := srv.call(ctx, req)
+	if err != nil {
+		e.servers.discard(srv)
+		log.Error().
+			Err(err).
+			Str("helper", helperPath).
+			Str("mode", req.Mode).
+			Str("from", req.FromFormat).
+			Str("to", req.ToFormat).
+			Str("stderr", srv.stderr.String()).
+			Msg("Helper server failed during conversion, it will be restarted")
+		return &Error{Helper: helperPath, Message: srv.stderr.String(), Err: err}
+	}
+	e.servers.put(srv)
+
+	if !resp.OK {
+		log.Error().
+			Str("helper", helperPath).
+			Str("mode", req.Mode).
+			Str("from", req.FromFormat).
+			Str("to", req.ToFormat).
+			Str("class", string(resp.Class)).
+			Str("error", resp.Error).
+			Msg("Helper conversion failed")
+		return &Error{Helper: helperPath, Class: knownClass(string(resp.Class)), Message: resp.Error}
+	}
+
+	log.Info().
+		Str("helper", helperPath).
+		Str("mode", req.Mode).
+		Str("from", req.FromFormat).
+		Str("to", req.ToFormat).
+		Str("input", req.FromFile).
+		Str("output", req.ToFile).
+		Msg("Successfully converted with helper server")
+	return nil
+}
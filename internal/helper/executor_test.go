@@ -0,0 +1,187 @@
+//go:build unix || linux || darwin
+
+package helper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+var mdToHTML = internal.ConversionOptions{InputFormat: internal.FormatMD, OutputFormat: internal.FormatHTML}
+
+// optionsHelper is a one-shot helper that writes the options it received to the
+// output and fails inputs named *corrupt* with a classified error
+const optionsHelper = `#!/bin/sh
+[ "$1" = convert ] || exit 1
+echo "progress: 50% halfway" >&2
+case "$4" in
+  *corrupt*) echo "tool noise" >&2; echo "error: corrupted_input: broken xref table" >&2; exit 1 ;;
+esac
+echo "$YAKATEKA_DPI|$YAKATEKA_PAGES|$YAKATEKA_OCR_LANGUAGES|$YAKATEKA_OPT_PDF_ENGINE" > "$6"
+[ -n "$YAKATEKA_REQUEST" ] && cat "$YAKATEKA_REQUEST" >> "$6"
+exit 0
+`
+
+// serveHelper answers serve-mode requests with a shell loop: it writes its pid to
+// the output, reports inputs named *bad* as corrupt, exits on *crash* and hangs
+// on *hang*. One-shot conversions write "oneshot"
+const serveHelper = `#!/bin/sh
+case "$1" in
+ping) echo pong ;;
+convert) echo oneshot > "$6" ;;
+serve)
+  echo "helper chatter before the first request"
+  while IFS= read -r line; do
+    id=$(printf '%s' "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+    from=$(printf '%s' "$line" | sed -n 's/.*"from_file":"\([^"]*\)".*/\1/p')
+    to=$(printf '%s' "$line" | sed -n 's/.*"to_file":"\([^"]*\)".*/\1/p')
+    case "$from" in
+      *crash*) echo "crashed on $from" >&2; exit 3 ;;
+      *hang*) sleep 5 </dev/null >/dev/null 2>&1 ;;
+      *bad*) echo "{\"id\":$id,\"ok\":false,\"class\":\"corrupted_input\",\"error\":\"corrupt input\"}"; continue ;;
+    esac
+    [ -n "$to" ] && echo "{\"id\":$id,\"event\":\"progress\",\"progress\":50}" && echo $$ > "$to"
+    echo "{\"id\":$id,\"ok\":true}"
+  done ;;
+esac
+`
+
+func TestExecutorServe(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "serve-helper.sh")
+	if err := os.WriteFile(path, []byte(serveHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(time.Second)
+	executor.SetHelper(path, CachedHelper{Serve: true})
+	defer executor.Close()
+
+	convert := func(input string) (string, error) {
+		output := filepath.Join(dir, input+".out")
+		err := executor.Convert(context.Background(), path, ModeNormal, filepath.Join(dir, input), output, mdToHTML)
+		data, _ := os.ReadFile(output)
+		return strings.TrimSpace(string(data)), err
+	}
+
+	// Conversions reuse one process, also after a conversion the helper reports as failed
+	first, err := convert("a.md")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if _, err := convert("bad.md"); !errors.Is(err, internal.ErrInvalidInput) || !strings.Contains(err.Error(), "corrupt input") {
+		t.Errorf("Expected the helper's classified error, got %v", err)
+	}
+	if second, _ := convert("b.md"); second != first {
+		t.Errorf("Expected the server (pid %s) to be reused, got pid %s", first, second)
+	}
+
+	// A crash fails its request with the helper's stderr; the next request restarts the server
+	if _, err := convert("crash.md"); err == nil || !strings.Contains(err.Error(), "crashed on") {
+		t.Errorf("Expected the crash to be reported, got %v", err)
+	}
+	restarted, err := convert("c.md")
+	if err != nil || restarted == first {
+		t.Errorf("Expected a new server after the crash, got pid %s (was %s), err %v", restarted, first, err)
+	}
+
+	// A request that times out kills the server
+	if _, err := convert("hang.md"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if pid, err := convert("d.md"); err != nil || pid == restarted {
+		t.Errorf("Expected a new server after the timeout, got pid %s, err %v", pid, err)
+	}
+
+	// Servers that keep crashing are given up on for one-shot runs
+	for i := 0; i < maxServerRestarts; i++ {
+		convert("crash.md")
+	}
+	if out, err := convert("e.md"); err != nil || out != "oneshot" {
+		t.Errorf("Expected a one-shot conversion, got %q, err %v", out, err)
+	}
+}
+
+func TestExecutorCloseStopsServers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "serve-helper.sh")
+	if err := os.WriteFile(path, []byte(serveHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(time.Second)
+	executor.SetHelper(path, CachedHelper{Serve: true})
+
+	output := filepath.Join(dir, "out.html")
+	if err := executor.Convert(context.Background(), path, ModeNormal, filepath.Join(dir, "in.md"), output, mdToHTML); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	executor.Close()
+
+	data, _ := os.ReadFile(output)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("Expected the server's pid in the output, got %q", data)
+	}
+	if err := syscall.Kill(pid, 0); err == nil {
+		t.Errorf("Expected the helper server (pid %d) to have exited", pid)
+	}
+}
+
+func TestExecutorProtocolV2(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "options-helper.sh")
+	if err := os.WriteFile(path, []byte(optionsHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	opts := mdToHTML
+	opts.DPI = 300
+	opts.Pages = "1-3"
+	opts.OCRLanguages = []string{"uk", "en"}
+	opts.Extra = map[string]string{"pdf-engine": "xelatex"}
+
+	convert := func(executor *Executor, input string) (string, error) {
+		output := filepath.Join(dir, input+".out")
+		err := executor.Convert(context.Background(), path, ModeNormal, filepath.Join(dir, input), output, opts)
+		data, _ := os.ReadFile(output)
+		return string(data), err
+	}
+
+	// Protocol v2 helpers get options as variables and a request file
+	v2 := NewExecutor(time.Second)
+	v2.SetHelper(path, CachedHelper{Protocol: ProtocolV2})
+	out, err := convert(v2, "a.md")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "300|1-3|uk,en|xelatex\n") || !strings.Contains(out, `"pdf-engine":"xelatex"`) {
+		t.Errorf("Expected the options in variables and the request file, got %q", out)
+	}
+
+	// Their error lines classify the failure
+	_, err = convert(v2, "corrupt.md")
+	var helperErr *Error
+	if !errors.As(err, &helperErr) || helperErr.Class != ErrorCorruptedInput || helperErr.Message != "broken xref table" {
+		t.Fatalf("Expected a corrupted input error, got %v", err)
+	}
+	if !errors.Is(err, internal.ErrInvalidInput) {
+		t.Errorf("Expected corrupted input to be an invalid input, got %v", err)
+	}
+
+	// Protocol v1 helpers get neither, and their stderr is taken as it is
+	v1 := NewExecutor(time.Second)
+	if out, _ := convert(v1, "b.md"); out != "|||\n" {
+		t.Errorf("Expected no options for a v1 helper, got %q", out)
+	}
+	_, err = convert(v1, "corrupt.md")
+	if !errors.As(err, &helperErr) || helperErr.Class != "" || !strings.Contains(helperErr.Message, "error: corrupted_input") {
+		t.Errorf("Expected an unclassified error with the stderr, got %v", err)
+	}
+}
@@ -5,18 +5,14 @@ import (
 	"os"
 
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
 )
 
-// LoadAndPing loads helpers.yaml cache and pings all helpers
+// LoadAndPing loads the helper cache at cacheFile and pings all helpers
+// Helpers that changed since the cache was written (see HelperCache.Drift) are
+// queried again and the cache saved, unless offline, which only warns about them
+// No configured helpers (weights) means the cache is used as it is
 // Returns nil if cache doesn't exist or is empty
-func LoadAndPing(ctx context.Context) (*HelperConverter, error) {
-	// Get cache file path from config
-	cacheFile := viper.GetString("helpers.cache_file")
-	if cacheFile == "" {
-		cacheFile = "helpers.yaml"
-	}
-
+func LoadAndPing(ctx context.Context, cacheFile string, weights map[string]float64, offline bool) (*HelperConverter, error) {
 	// Check if cache file exists
 	if _, err := os.Stat(cacheFile); os.IsNotExist(err) {
 		log.Debug().
@@ -35,6 +31,12 @@ func LoadAndPing(ctx context.Context) (*HelperConverter, error) {
 		return nil, err
 	}
 
+	executor := NewExecutor(GetTimeout())
+
+	if len(weights) > 0 {
+		refreshStale(ctx, cache, cacheFile, executor, weights, offline)
+	}
+
 	if len(cache.Conversions) == 0 {
 		log.Debug().Msg("Helper cache is empty, skipping helper system")
 		return nil, nil
@@ -56,11 +58,21 @@ func LoadAndPing(ctx context.Context) (*HelperConverter, error) {
 		}
 	}
 
-	executor := NewExecutor(GetTimeout())
 	successCount := 0
 	failCount := 0
 
 	for helperPath := range helperPaths {
+		executor.SetHelper(helperPath, cache.Helpers[helperPath])
+
+		// Serve-mode helpers are pinged when their server starts, on first use
+		if cache.Helpers[helperPath].Serve {
+			helperPaths[helperPath] = true
+			successCount++
+			log.Debug().
+				Str("helper", helperPath).
+				Msg("Helper runs in serve mode, skipping ping until first use")
+			continue
+		}
 		if executor.Ping(ctx, helperPath) {
 			helperPaths[helperPath] = true
 			successCount++
@@ -93,3 +105,34 @@ func LoadAndPing(ctx context.Context) (*HelperConverter, error) {
 	converter := NewHelperConverter(cache, executor)
 	return converter, nil
 }
+
+// refreshStale queries the helpers that changed since the cache was written again
+// and saves the cache, or only warns about them when offline
+func refreshStale(ctx context.Context, cache *HelperCache, cacheFile string, executor *Executor, weights map[string]float64, offline bool) {
+	drifts := cache.Drift(weights)
+	if len(drifts) == 0 {
+		return
+	}
+	for _, d := range drifts {
+		log.Debug().
+			Str("helper", d.Helper).
+			Str("reason", string(d.Reason)).
+			Msg("Helper changed since the cache was generated")
+	}
+	if offline {
+		log.Warn().
+			Int("helpers", len(drifts)).
+			Str("cache", cacheFile).
+			Msg("Helper cache is stale, run 'yakateka helpers' to refresh it")
+	} else if err := cache.Refresh(ctx, executor, weights, drifts); err != nil {
+		log.Warn().Err(err).Msg("Failed to refresh helper cache, using it as it is")
+	} else {
+		log.Info().
+			Int("helpers", len(drifts)).
+			Str("cache", cacheFile).
+			Msg("Refreshed changed helpers in the cache")
+		if err := cache.SaveCache(cacheFile); err != nil {
+			log.Warn().Err(err).Msg("Failed to save refreshed helper cache")
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+)
+
+// Error implements error: "conversion failed (class): message"
+func (e *Error) Error() string {
+	msg := "conversion failed"
+	if e.Class != "" {
+		msg += " (" + string(e.Class) + ")"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+		if e.Message != "" {
+			msg += " - " + e.Message
+		}
+		return msg
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap exposes the class as an internal error (corrupted input is
+// internal.ErrInvalidInput, unsupported input internal.ErrUnsupportedFormat,
+// anything else internal.ErrConversionFailed) along with the cause
+func (e *Error) Unwrap() []error {
+	var class error
+	switch e.Class {
+	case ErrorCorruptedInput:
+		class = internal.ErrInvalidInput
+	case ErrorUnsupportedInput:
+		class = internal.ErrUnsupportedFormat
+	default:
+		class = internal.ErrConversionFailed
+	}
+	if e.Err == nil {
+		return []error{class}
+	}
+	return []error{class, e.Err}
+}
+
+// knownClass returns a class reported by a helper, or "" if it is not one of ours
+func knownClass(class string) ErrorClass {
+	switch c := ErrorClass(strings.TrimSpace(class)); c {
+	case ErrorUnsupportedInput, ErrorCorruptedInput, ErrorToolMissing, ErrorTransient:
+		return c
+	}
+	return ""
+}
+
+// protocol returns the contract version to speak to the helper with
+func (h CachedHelper) protocol() int {
+	if h.Protocol < ProtocolV1 {
+		return ProtocolV1
+	}
+	return min(h.Protocol, ProtocolV2)
+}
+
+// newRequest builds the conversion request for a helper speaking protocol
+func newRequest(protocol int, mode ConversionMode, input, output string, opts internal.ConversionOptions) serveRequest {
+	req := serveRequest{
+		Command:    "convert",
+		Mode:       string(mode),
+		FromFormat: string(opts.InputFormat),
+		FromFile:   input,
+		ToFormat:   string(opts.OutputFormat),
+		ToFile:     output,
+	}
+	if protocol >= ProtocolV2 {
+		req.Options = &requestOptions{
+			DPI:          opts.DPI,
+			OCRLanguages: opts.OCRLanguages,
+			Pages:        opts.Pages,
+			Extra:        opts.Extra,
+		}
+	}
+	return req
+}
+
+// requestEnv returns the environment of a one-shot protocol v2 conversion: every
+// option as a YAKATEKA_* variable, and YAKATEKA_REQUEST naming the request file
+func requestEnv(req serveRequest, requestFile string) []string {
+	env := []string{
+		"YAKATEKA_PROTOCOL=" + strconv.Itoa(ProtocolV2),
+		"YAKATEKA_REQUEST=" + requestFile,
+	}
+	opts := req.Options
+	if opts.DPI > 0 {
+		env = append(env, "YAKATEKA_DPI="+strconv.Itoa(opts.DPI))
+	}
+	if len(opts.OCRLanguages) > 0 {
+		env = append(env, "YAKATEKA_OCR_LANGUAGES="+strings.Join(opts.OCRLanguages, ","))
+	}
+	if opts.Pages != "" {
+		env = append(env, "YAKATEKA_PAGES="+opts.Pages)
+	}
+	for key, value := range opts.Extra {
+		env = append(env, "YAKATEKA_OPT_"+envName(key)+"="+value)
+	}
+	return env
+}
+
+// envName turns an option key into an environment variable name: pdf-engine → PDF_ENGINE
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, key)
+}
+
+// writeRequestFile writes a request as JSON to a temp file
+func writeRequestFile(req serveRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "yakateka-request-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// logProgress reports a helper's progress on a conversion
+func logProgress(helperPath string, percent float64, message string) {
+	log.Info().
+		Str("helper", helperPath).
+		Float64("percent", percent).
+		Str("message", message).
+		Msg("Helper progress")
+}
+
+// stderrParser collects a helper's stderr; for protocol v2 helpers it also acts
+// on "progress: <percent> [message]" and "error: <class>: <message>" lines,
+// which are left out of the collected text
+type stderrParser struct {
+	helper     string
+	structured bool // Protocol v2
+	partial    []byte
+	text       tailBuffer
+	class      ErrorClass
+	message    string
+}
+
+func newStderrParser(helperPath string, protocol int) *stderrParser {
+	return &stderrParser{
+		helper:     helperPath,
+		structured: protocol >= ProtocolV2,
+		text:       tailBuffer{max: stderrTailSize},
+	}
+}
+
+func (p *stderrParser) Write(b []byte) (int, error) {
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		p.line(string(p.partial[:i+1]))
+		p.partial = p.partial[i+1:]
+	}
+	// Output without newlines (a tool dumping binary) is kept as text
+	if len(p.partial) > stderrTailSize {
+		p.text.Write(p.partial)
+		p.partial = nil
+	}
+	return len(b), nil
+}
+
+// line handles one line of stderr, including its newline
+func (p *stderrParser) line(line string) {
+	if p.structured {
+		trimmed := strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(trimmed, "progress:"); ok {
+			value, message, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			if percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+				logProgress(p.helper, percent, strings.TrimSpace(message))
+				return
+			}
+		}
+		if rest, ok := strings.CutPrefix(trimmed, "error:"); ok {
+			class, message, found := strings.Cut(rest, ":")
+			if c := knownClass(class); found && c != "" {
+				p.class, p.message = c, strings.TrimSpace(message)
+				return
+			}
+		}
+	}
+	p.text.Write([]byte(line))
+}
+
+// failure builds the error for a failed run from what the helper reported
+func (p *stderrParser) failure(cause error) *Error {
+	if len(p.partial) > 0 {
+		p.line(string(p.partial))
+		p.partial = nil
+	}
+	if p.class != "" {
+		return &Error{Helper: p.helper, Class: p.class, Message: p.message, Err: cause}
+	}
+	return &Error{Helper: p.helper, Message: p.text.String(), Err: cause}
+}
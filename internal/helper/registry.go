@@ -52,6 +52,14 @@ func (r *Registry) Initialize(ctx context.Context, executor *Executor) error {
 			continue
 		}
 
+		if info.Protocol > ProtocolV2 {
+			log.Warn().
+				Str("helper", path).
+				Int("protocol_version", info.Protocol).
+				Int("supported", ProtocolV2).
+				Msg("Helper declares a newer protocol, it will be spoken to with the newest supported one")
+		}
+
 		entry.Info = info
 		log.Info().
 			Str("helper", path).
@@ -67,6 +75,7 @@ func (r *Registry) Initialize(ctx context.Context, executor *Executor) error {
 func (r *Registry) GenerateCache() (*HelperCache, error) {
 	cache := &HelperCache{
 		Conversions: make(map[string]map[string]map[string][]CacheEntry),
+		Helpers:     make(map[string]CachedHelper),
 	}
 
 	// Collect all conversions from all helpers
@@ -80,9 +89,16 @@ func (r *Registry) GenerateCache() (*HelperCache, error) {
 
 	// Iterate through all helpers
 	for path, entry := range r.Helpers {
+		cached := CachedHelper{Weight: entry.Config.Weight}
+		cached.Modified, cached.Hash = stampFile(path)
 		if !entry.Config.Available || entry.Info == nil {
+			// Recorded so the helper is only queried again once it changes
+			cached.Unavailable = true
+			cache.Helpers[path] = cached
 			continue
 		}
+		cached.Serve, cached.Protocol, cached.Version = entry.Info.Serve, entry.Info.Protocol, entry.Info.Version
+		cache.Helpers[path] = cached
 
 		// Iterate through capabilities
 		for fromFormat, toFormats := range entry.Info.Capabilities {
@@ -107,8 +123,10 @@ func (r *Registry) GenerateCache() (*HelperCache, error) {
 						}
 
 						conversions[key] = append(conversions[key], CacheEntry{
-							Helper: path,
-							Weight: entry.Config.Weight,
+							Helper:  path,
+							Weight:  entry.Config.Weight,
+							Speed:   metrics.Speed,
+							Quality: metrics.Quality,
 						})
 					}
 				}
@@ -118,12 +136,7 @@ func (r *Registry) GenerateCache() (*HelperCache, error) {
 
 	// Sort each conversion list by weight (descending) then by name
 	for key, entries := range conversions {
-		sort.Slice(entries, func(i, j int) bool {
-			if entries[i].Weight != entries[j].Weight {
-				return entries[i].Weight > entries[j].Weight // Descending
-			}
-			return entries[i].Helper < entries[j].Helper // Alphabetical
-		})
+		sortEntries(entries)
 
 		// Add to cache structure
 		if cache.Conversions[key.from] == nil {
@@ -138,9 +151,21 @@ func (r *Registry) GenerateCache() (*HelperCache, error) {
 	return cache, nil
 }
 
+// sortEntries orders helpers by weight (descending) then by path
+func sortEntries(entries []CacheEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Weight != entries[j].Weight {
+			return entries[i].Weight > entries[j].Weight // Descending
+		}
+		return entries[i].Helper < entries[j].Helper // Alphabetical
+	})
+}
+
 // SaveCache saves helpers.yaml to disk
 func (cache *HelperCache) SaveCache(path string) error {
+	cache.mu.RLock()
 	data, err := yaml.Marshal(cache)
+	cache.mu.RUnlock()
 	if err != nil {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
@@ -171,13 +196,16 @@ func LoadCache(path string) (*HelperCache, error) {
 // FindHelpers returns ordered list of helpers for a conversion
 // Falls back from requested mode to normal if needed
 func (cache *HelperCache) FindHelpers(from, to internal.DocumentFormat, mode ConversionMode) []CacheEntry {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
 	fromStr := string(from)
 	toStr := string(to)
 	modeStr := string(mode)
 
 	// Try requested mode first
 	if helpers, ok := cache.Conversions[fromStr][toStr][modeStr]; ok && len(helpers) > 0 {
-		return helpers
+		return append([]CacheEntry(nil), helpers...)
 	}
 
 	// Fallback to normal mode
@@ -188,7 +216,7 @@ func (cache *HelperCache) FindHelpers(from, to internal.DocumentFormat, mode Con
 				Str("to", toStr).
 				Str("requested", modeStr).
 				Msg("No helpers for requested mode, falling back to normal")
-			return helpers
+			return append([]CacheEntry(nil), helpers...)
 		}
 	}
 
@@ -198,6 +226,9 @@ func (cache *HelperCache) FindHelpers(from, to internal.DocumentFormat, mode Con
 // MarkHelperFailed marks a helper as unavailable for a specific conversion
 // This prevents retrying the same helper for the same conversion pair
 func (cache *HelperCache) MarkHelperFailed(from, to internal.DocumentFormat, helperPath string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
 	fromStr := string(from)
 	toStr := string(to)
 
@@ -229,9 +260,22 @@ func (cache *HelperCache) MarkHelperFailed(from, to internal.DocumentFormat, hel
 // MarkHelperGloballyFailed marks a helper as unavailable for all conversions
 // This is more efficient than calling MarkHelperFailed for each conversion pair
 func (cache *HelperCache) MarkHelperGloballyFailed(helperPath string) {
-	conversionCount := 0
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
 
-	// Remove helper from all conversions and modes
+	conversionCount := cache.removeConversions(helperPath)
+
+	log.Debug().
+		Str("helper", helperPath).
+		Int("conversions_removed", conversionCount).
+		Msg("Marked helper as globally failed")
+}
+
+// removeConversions removes a helper from all conversions and modes, dropping
+// conversions left without helpers, and returns how many entries it had
+// cache.mu must be held
+func (cache *HelperCache) removeConversions(helperPath string) int {
+	conversionCount := 0
 	for fromFormat, toFormats := range cache.Conversions {
 		for toFormat, modes := range toFormats {
 			for mode, helpers := range modes {
@@ -243,15 +287,21 @@ func (cache *HelperCache) MarkHelperGloballyFailed(helperPath string) {
 						conversionCount++
 					}
 				}
-				cache.Conversions[fromFormat][toFormat][mode] = filtered
+				if len(filtered) == 0 {
+					delete(modes, mode)
+				} else {
+					modes[mode] = filtered
+				}
+			}
+			if len(modes) == 0 {
+				delete(toFormats, toFormat)
 			}
 		}
+		if len(toFormats) == 0 {
+			delete(cache.Conversions, fromFormat)
+		}
 	}
-
-	log.Debug().
-		Str("helper", helperPath).
-		Int("conversions_removed", conversionCount).
-		Msg("Marked helper as globally failed")
+	return conversionCount
 }
 
 // GetTimeout returns default timeout for helper operations
@@ -0,0 +1,256 @@
+package helper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// startServer starts a helper in serve mode and waits until it answers a ping
+func startServer(ctx context.Context, path string, timeout time.Duration) (*server, error) {
+	cmd := exec.Command(path, "serve")
+	cmd.WaitDelay = serverStopGrace // Tools the helper started may hold its pipes open
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		path:      path,
+		cmd:       cmd,
+		stdin:     stdin,
+		responses: make(chan serveResponse),
+		stderr:    &tailBuffer{max: stderrTailSize},
+		exited:    make(chan struct{}),
+	}
+	cmd.Stderr = s.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start helper server: %w", err)
+	}
+
+	go s.readResponses(stdout)
+	go func() {
+		err := cmd.Wait()
+		log.Debug().Err(err).Str("helper", path).Msg("Helper server exited")
+		close(s.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, err := s.call(ctx, serveRequest{Command: "ping"}); err != nil {
+		s.kill()
+		return nil, fmt.Errorf("helper server did not answer ping: %w", err)
+	}
+
+	log.Debug().
+		Str("helper", path).
+		Int("pid", cmd.Process.Pid).
+		Msg("Started helper server")
+	return s, nil
+}
+
+// readResponses parses response lines from the helper's stdout
+// Lines that are not responses (chatter from tools the helper runs) are skipped
+func (s *server) readResponses(stdout io.Reader) {
+	defer close(s.responses)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxResponseSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var resp serveResponse
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &resp) != nil || resp.ID == 0 {
+			log.Debug().Str("helper", s.path).Str("line", line).Msg("Ignoring helper server output")
+			continue
+		}
+		select {
+		case s.responses <- resp:
+		case <-s.exited:
+			return
+		}
+	}
+}
+
+// call sends a request and waits for its response
+// The server is unusable after an error; the caller must kill it
+func (s *server) call(ctx context.Context, req serveRequest) (serveResponse, error) {
+	req.ID = s.nextID.Add(1)
+	line, err := json.Marshal(req)
+	if err != nil {
+		return serveResponse{}, err
+	}
+	if _, err := s.stdin.Write(append(line, '\n')); err != nil {
+		return serveResponse{}, fmt.Errorf("%w: %v", errServerExited, err)
+	}
+
+	for {
+		select {
+		case resp, ok := <-s.responses:
+			if !ok {
+				return serveResponse{}, errServerExited
+			}
+			if resp.ID != req.ID {
+				log.Debug().Str("helper", s.path).Int64("id", resp.ID).Msg("Ignoring stale helper server response")
+				continue
+			}
+			if resp.Event == "progress" {
+				logProgress(s.path, resp.Progress, resp.Message)
+				continue
+			}
+			return resp, nil
+		case <-ctx.Done():
+			return serveResponse{}, ctx.Err()
+		}
+	}
+}
+
+// stop asks the server to exit by closing its stdin, killing it if it lingers
+func (s *server) stop() {
+	s.stdin.Close()
+	select {
+	case <-s.exited:
+	case <-time.After(serverStopGrace):
+		s.kill()
+	}
+}
+
+// kill terminates the server and waits for it to be reaped
+func (s *server) kill() {
+	s.stdin.Close()
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	<-s.exited
+}
+
+// alive reports whether the process is still running
+func (s *server) alive() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// get returns an idle server for the helper, starting one if none is waiting
+func (p *serverPool) get(ctx context.Context, path string, timeout time.Duration) (*server, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("helper servers are shut down")
+	}
+	for idle := p.idle[path]; len(idle) > 0; idle = p.idle[path] {
+		s := idle[len(idle)-1]
+		p.idle[path] = idle[:len(idle)-1]
+		if s.alive() {
+			p.mu.Unlock()
+			return s, nil
+		}
+		delete(p.running, s)
+	}
+	p.mu.Unlock()
+
+	s, err := startServer(ctx, path, timeout)
+	if err != nil {
+		p.failed(path)
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		go s.stop()
+		return nil, errors.New("helper servers are shut down")
+	}
+	p.running[s] = true
+	return s, nil
+}
+
+// put returns a server that answered its request to the idle list
+func (p *serverPool) put(s *server) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.crashes[s.path] = 0
+	if p.closed {
+		go s.stop()
+		return
+	}
+	p.idle[s.path] = append(p.idle[s.path], s)
+}
+
+// discard kills a server that crashed, timed out or broke the protocol
+// The next request for its helper starts a fresh one
+func (p *serverPool) discard(s *server) {
+	s.kill()
+	p.mu.Lock()
+	delete(p.running, s)
+	p.mu.Unlock()
+	p.failed(s.path)
+}
+
+// failed counts a start failure or crash of a helper's server
+func (p *serverPool) failed(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.crashes[path]++
+}
+
+// broken reports whether a helper's server failed too often in a row to keep restarting it
+func (p *serverPool) broken(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.crashes[path] >= maxServerRestarts
+}
+
+// close stops every server
+func (p *serverPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	servers := make([]*server, 0, len(p.running))
+	for s := range p.running {
+		servers = append(servers, s)
+	}
+	p.running = make(map[*server]bool)
+	p.idle = make(map[string][]*server)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			s.stop()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Write appends p, dropping the oldest bytes beyond max
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+// String returns the kept output, trimmed
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
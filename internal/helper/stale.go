@@ -0,0 +1,161 @@
+package helper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stampFile returns a script's modification time and SHA-256, or zero values if
+// it cannot be read
+func stampFile(path string) (time.Time, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, ""
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, ""
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return time.Time{}, ""
+	}
+	return info.ModTime(), hex.EncodeToString(hash.Sum(nil))
+}
+
+// Drift compares the cache with the configured helpers (path -> weight) and
+// returns the helpers whose entries are stale, sorted by path
+// A script is only hashed when its modification time changed
+func (cache *HelperCache) Drift(weights map[string]float64) []Drift {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	known := make(map[string]bool, len(cache.Helpers))
+	for path := range cache.Helpers {
+		known[path] = true
+	}
+	// Caches written before helpers were recorded only list them in conversions
+	for _, toFormats := range cache.Conversions {
+		for _, modes := range toFormats {
+			for _, entries := range modes {
+				for _, entry := range entries {
+					known[entry.Helper] = true
+				}
+			}
+		}
+	}
+
+	var drifts []Drift
+	for path, weight := range weights {
+		if !known[path] {
+			drifts = append(drifts, Drift{Helper: path, Reason: DriftAdded})
+		} else if reason := cache.Helpers[path].drift(path, weight); reason != "" {
+			drifts = append(drifts, Drift{Helper: path, Reason: reason})
+		}
+	}
+	for path := range known {
+		if _, ok := weights[path]; !ok {
+			drifts = append(drifts, Drift{Helper: path, Reason: DriftRemoved})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Helper < drifts[j].Helper })
+	return drifts
+}
+
+// drift tells how the helper at path with weight differs from the entry, or ""
+func (h CachedHelper) drift(path string, weight float64) DriftReason {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		// A script that was already missing when the cache was written is not news
+		if h.Unavailable && h.Hash == "" {
+			return ""
+		}
+		return DriftMissing
+	case h.Hash == "":
+		return DriftModified
+	case !info.ModTime().Equal(h.Modified):
+		// Touched files with the same content are still fresh
+		if _, hash := stampFile(path); hash != h.Hash {
+			return DriftModified
+		}
+	}
+	if weight != h.Weight {
+		return DriftWeight
+	}
+	return ""
+}
+
+// Refresh re-queries the drifted helpers and replaces their entries, leaving
+// the rest of the cache as it is; removed helpers are dropped
+func (cache *HelperCache) Refresh(ctx context.Context, executor *Executor, weights map[string]float64, drifts []Drift) error {
+	registry := NewRegistry()
+	for _, d := range drifts {
+		if d.Reason != DriftRemoved {
+			registry.Register(d.Helper, weights[d.Helper])
+		}
+	}
+	if err := registry.Initialize(ctx, executor); err != nil {
+		return fmt.Errorf("helper initialization failed: %w", err)
+	}
+	fresh, err := registry.GenerateCache()
+	if err != nil {
+		return fmt.Errorf("cache generation failed: %w", err)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.Conversions == nil {
+		cache.Conversions = make(map[string]map[string]map[string][]CacheEntry)
+	}
+	if cache.Helpers == nil {
+		cache.Helpers = make(map[string]CachedHelper)
+	}
+	for _, d := range drifts {
+		old := cache.Helpers[d.Helper]
+		cache.removeConversions(d.Helper)
+		delete(cache.Helpers, d.Helper)
+
+		updated, ok := fresh.Helpers[d.Helper]
+		if !ok {
+			continue
+		}
+		cache.Helpers[d.Helper] = updated
+		if old.Version != "" && updated.Version != old.Version {
+			log.Info().
+				Str("helper", d.Helper).
+				Str("was", old.Version).
+				Str("version", updated.Version).
+				Msg("Helper version changed")
+		}
+	}
+
+	// Merge the new entries into the lists of the remaining helpers
+	for from, toFormats := range fresh.Conversions {
+		if cache.Conversions[from] == nil {
+			cache.Conversions[from] = make(map[string]map[string][]CacheEntry)
+		}
+		for to, modes := range toFormats {
+			if cache.Conversions[from][to] == nil {
+				cache.Conversions[from][to] = make(map[string][]CacheEntry)
+			}
+			for mode, entries := range modes {
+				merged := append(cache.Conversions[from][to][mode], entries...)
+				sortEntries(merged)
+				cache.Conversions[from][to][mode] = merged
+			}
+		}
+	}
+	return nil
+}
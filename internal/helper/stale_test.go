@@ -0,0 +1,130 @@
+//go:build unix || linux || darwin
+
+package helper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeInfoHelper writes a helper converting md to the given format that counts
+// its info calls in <path>.calls
+func writeInfoHelper(t *testing.T, path, version, to string) {
+	t.Helper()
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+ping) echo pong ;;
+info)
+  echo info >> "$0.calls"
+  printf 'name: test\nversion: "%s"\ncapabilities:\n  md:\n    %s:\n      modes:\n        normal:\n          speed: 1\n          quality: 1\n' ;;
+esac
+`, version, to)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func infoCalls(path string) int {
+	data, _ := os.ReadFile(path + ".calls")
+	return strings.Count(string(data), "info")
+}
+
+func TestHelperCacheDriftAndRefresh(t *testing.T) {
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.sh")
+	reweighted := filepath.Join(dir, "reweighted.sh")
+	touched := filepath.Join(dir, "touched.sh")
+	deleted := filepath.Join(dir, "deleted.sh")
+	added := filepath.Join(dir, "added.sh")
+	writeInfoHelper(t, edited, "1.0", "html")
+	writeInfoHelper(t, reweighted, "1.0", "txt")
+	writeInfoHelper(t, touched, "1.0", "rst")
+	writeInfoHelper(t, deleted, "1.0", "epub")
+
+	weights := map[string]float64{edited: 0.9, reweighted: 0.5, touched: 0.4, deleted: 0.3}
+	registry := NewRegistry()
+	for path, weight := range weights {
+		registry.Register(path, weight)
+	}
+	executor := NewExecutor(time.Second)
+	if err := registry.Initialize(context.Background(), executor); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := registry.GenerateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drifts := cache.Drift(weights); len(drifts) != 0 {
+		t.Fatalf("Expected a fresh cache, got %v", drifts)
+	}
+
+	// Only content, weight and membership changes count as drift
+	writeInfoHelper(t, edited, "2.0", "pdf")
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(edited, later, later)
+	os.Chtimes(touched, later, later)
+	os.Remove(deleted)
+	writeInfoHelper(t, added, "1.0", "odt")
+	weights[reweighted] = 0.6
+	weights[added] = 0.2
+
+	want := []Drift{
+		{added, DriftAdded},
+		{deleted, DriftMissing},
+		{edited, DriftModified},
+		{reweighted, DriftWeight},
+	}
+	drifts := cache.Drift(weights)
+	if !reflect.DeepEqual(drifts, want) {
+		t.Fatalf("Expected drifts %v, got %v", want, drifts)
+	}
+
+	// Refreshing queries only the drifted helpers
+	if err := cache.Refresh(context.Background(), executor, weights, drifts); err != nil {
+		t.Fatal(err)
+	}
+	if calls := infoCalls(touched); calls != 1 {
+		t.Errorf("Expected the unchanged helper not to be queried again, got %d info calls", calls)
+	}
+	if _, ok := cache.Conversions["md"]["html"]; ok {
+		t.Error("Expected the edited helper's old conversion to be dropped")
+	}
+	if entries := cache.Conversions["md"]["pdf"]["normal"]; len(entries) != 1 || entries[0].Helper != edited {
+		t.Errorf("Expected the edited helper's new conversion, got %v", entries)
+	}
+	if entries := cache.Conversions["md"]["txt"]["normal"]; len(entries) != 1 || entries[0].Weight != 0.6 {
+		t.Errorf("Expected the new weight, got %v", entries)
+	}
+	if entries := cache.Conversions["md"]["rst"]["normal"]; len(entries) != 1 {
+		t.Errorf("Expected the unchanged helper to keep its conversion, got %v", entries)
+	}
+	if helper := cache.Helpers[edited]; helper.Version != "2.0" {
+		t.Errorf("Expected the reported version to be updated, got %q", helper.Version)
+	}
+	if helper := cache.Helpers[deleted]; !helper.Unavailable {
+		t.Errorf("Expected the deleted helper to be recorded as unavailable, got %+v", helper)
+	}
+	if drifts := cache.Drift(weights); len(drifts) != 0 {
+		t.Errorf("Expected no drift after the refresh, got %v", drifts)
+	}
+
+	// Helpers dropped from the config are dropped from the cache
+	delete(weights, reweighted)
+	drifts = cache.Drift(weights)
+	if !reflect.DeepEqual(drifts, []Drift{{reweighted, DriftRemoved}}) {
+		t.Fatalf("Expected the helper to be reported as removed, got %v", drifts)
+	}
+	cache.Refresh(context.Background(), executor, weights, drifts)
+	if _, ok := cache.Conversions["md"]["txt"]; ok {
+		t.Error("Expected the removed helper's conversions to be dropped")
+	}
+	if _, ok := cache.Helpers[reweighted]; ok {
+		t.Error("Expected the removed helper to be dropped")
+	}
+}
@@ -1,6 +1,14 @@
 package helper
 
 import (
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/valpere/yakateka/internal"
 )
 
@@ -13,6 +21,19 @@ const (
 	ModeQuality ConversionMode = "quality" // Quality over speed (optional)
 )
 
+// ModeFromQuality maps a --quality value to a conversion mode
+// fast/low select fast, quality/high select quality, anything else normal
+func ModeFromQuality(quality string) ConversionMode {
+	switch strings.ToLower(strings.TrimSpace(quality)) {
+	case "fast", "low":
+		return ModeFast
+	case "quality", "high":
+		return ModeQuality
+	default:
+		return ModeNormal
+	}
+}
+
 // ModeMetrics contains performance metrics for a conversion mode
 type ModeMetrics struct {
 	Speed   float64 `yaml:"speed"`   // > 0 means supported (higher = faster)
@@ -65,6 +86,8 @@ type HelperInfo struct {
 	Version      string                                       `yaml:"version,omitempty"`
 	Description  string                                       `yaml:"description,omitempty"`
 	Capabilities map[string]map[string]ModeCapabilities      `yaml:"capabilities"` // from_format -> to_format -> modes
+	Serve        bool                                         `yaml:"serve,omitempty"` // Runs as a long-lived process with "serve"
+	Protocol     int                                          `yaml:"protocol_version,omitempty"` // Helper contract version; 0 means ProtocolV1
 }
 
 // HelperConfig represents a helper's configuration
@@ -86,14 +109,218 @@ type Registry struct {
 }
 
 // CacheEntry represents a helper entry in helpers.yaml cache
+// Speed and Quality are the helper's ModeMetrics for the mode; caches written
+// before they were recorded leave them zero
 type CacheEntry struct {
-	Helper string  `yaml:"helper"`
-	Weight float64 `yaml:"weight"`
-	// Future: can add speed/quality metrics here
+	Helper  string  `yaml:"helper"`
+	Weight  float64 `yaml:"weight"`
+	Speed   float64 `yaml:"speed,omitempty"`
+	Quality float64 `yaml:"quality,omitempty"`
+}
+
+// Metrics returns the entry's mode metrics, treating unrecorded metrics as 1
+func (e CacheEntry) Metrics() ModeMetrics {
+	m := ModeMetrics{Speed: e.Speed, Quality: e.Quality}
+	if m.Speed <= 0 {
+		m.Speed = 1
+	}
+	if m.Quality <= 0 {
+		m.Quality = 1
+	}
+	return m
+}
+
+// CachedHelper records what helpers.yaml knows about one helper beyond its conversions
+// The script's modification time, hash and weight tell when the entry is stale
+type CachedHelper struct {
+	Serve       bool      `yaml:"serve,omitempty"`       // Supports serve mode (see HelperInfo.Serve)
+	Protocol    int       `yaml:"protocol,omitempty"`    // Contract version the helper is spoken to with; 0 means ProtocolV1
+	Version     string    `yaml:"version,omitempty"`     // Version the helper reported
+	Weight      float64   `yaml:"weight"`                // Weight configured when the helper was queried
+	Modified    time.Time `yaml:"modified,omitempty"`    // Script modification time
+	Hash        string    `yaml:"sha256,omitempty"`      // Script SHA-256; empty if the script was missing
+	Unavailable bool      `yaml:"unavailable,omitempty"` // Failed ping or info, so it has no conversions
+}
+
+// DriftReason says how a configured helper differs from its cache entry
+type DriftReason string
+
+const (
+	DriftAdded    DriftReason = "added"    // Configured, not in the cache
+	DriftRemoved  DriftReason = "removed"  // In the cache, no longer configured
+	DriftModified DriftReason = "modified" // Script changed (or was never stamped)
+	DriftMissing  DriftReason = "missing"  // Script no longer exists
+	DriftWeight   DriftReason = "weight"   // Weight changed in config
+)
+
+// Drift is a helper whose cache entry is stale
+type Drift struct {
+	Helper string
+	Reason DriftReason
 }
 
 // HelperCache represents the helpers.yaml file structure
 type HelperCache struct {
 	// Structure: from_format -> to_format -> mode -> []CacheEntry
 	Conversions map[string]map[string]map[string][]CacheEntry `yaml:"conversions"`
+	Helpers     map[string]CachedHelper                       `yaml:"helpers,omitempty"` // By helper path
+
+	mu sync.RWMutex // Guards Conversions when shared by concurrent conversions
+}
+
+// Serve mode limits
+const (
+	serverStopGrace   = 2 * time.Second // Time a server gets to exit after its stdin closes
+	stderrTailSize    = 4096            // Bytes of server stderr kept for error messages
+	maxResponseSize   = 1 << 20         // Longest response line a server may send
+	maxServerRestarts = 3               // Consecutive start failures or crashes before a helper runs one-shot
+)
+
+// Helper discovery
+const (
+	HelpersPathEnv         = "YAKATEKA_HELPERS_PATH"       // Extra helper directories, separated like PATH
+	userHelpersDir         = ".yakateka/helpers.d"         // Under the home directory
+	systemHelpersDir       = "/usr/share/yakateka/helpers" // Where packages install helpers
+	defaultDiscoveryWeight = 0.5                           // Weight of a discovered helper without one in its manifest
+)
+
+// Manifest is the optional <helper name>.yaml next to a discovered helper
+// (pandoc-helper.yaml for pandoc-helper.sh)
+type Manifest struct {
+	Weight float64 `yaml:"weight,omitempty"` // 0 means defaultDiscoveryWeight
+}
+
+// Failure handling defaults
+const (
+	defaultRetries          = 2                      // Retries of a transient failure
+	defaultRetryBackoff     = 500 * time.Millisecond // Wait before the first retry
+	defaultBreakerThreshold = 3                      // Consecutive failures that open a circuit
+	defaultBreakerTimeouts  = 5                      // Consecutive timeouts that open a circuit
+	defaultBreakerCooldown  = 30 * time.Second       // Time an open circuit waits before a trial
+)
+
+// Helper contract versions
+const (
+	ProtocolV1 = 1 // convert arguments in, exit code and free-form stderr out
+	ProtocolV2 = 2 // Adds conversion options, structured errors and progress
+)
+
+// ErrorClass says why a protocol v2 helper failed a conversion
+type ErrorClass string
+
+const (
+	ErrorUnsupportedInput ErrorClass = "unsupported_input" // Valid input the helper cannot handle (e.g. encrypted)
+	ErrorCorruptedInput   ErrorClass = "corrupted_input"   // Damaged input no helper will convert
+	ErrorToolMissing      ErrorClass = "tool_missing"      // A tool the helper runs is not installed
+	ErrorTransient        ErrorClass = "transient"         // Worth retrying (busy resource, lock held)
+)
+
+// Error is a failed helper conversion
+type Error struct {
+	Helper  string     // Helper path
+	Class   ErrorClass // Empty for protocol v1 helpers and unclassified failures
+	Message string     // The helper's error message, or the end of its stderr
+	Err     error      // Underlying cause (exit status, timeout, crashed server), if any
+}
+
+// requestOptions are the conversion options a protocol v2 helper receives
+type requestOptions struct {
+	DPI          int               `json:"dpi,omitempty"`
+	OCRLanguages []string          `json:"ocr_languages,omitempty"`
+	Pages        string            `json:"pages,omitempty"`
+	Extra        map[string]string `json:"extra,omitempty"`
+}
+
+// serveRequest is one line sent to a helper in serve mode, and the content of
+// the request file a protocol v2 helper gets for a one-shot conversion
+type serveRequest struct {
+	ID         int64           `json:"id,omitempty"`
+	Command    string          `json:"command"` // ping or convert
+	Mode       string          `json:"mode,omitempty"`
+	FromFormat string          `json:"from_format,omitempty"`
+	FromFile   string          `json:"from_file,omitempty"`
+	ToFormat   string          `json:"to_format,omitempty"`
+	ToFile     string          `json:"to_file,omitempty"`
+	Options    *requestOptions `json:"options,omitempty"` // Protocol v2 only
+}
+
+// serveResponse is the line a helper in serve mode answers a request with
+// Protocol v2 helpers may send progress events for the request before it
+type serveResponse struct {
+	ID       int64      `json:"id"`
+	OK       bool       `json:"ok"`
+	Error    string     `json:"error,omitempty"`
+	Class    ErrorClass `json:"class,omitempty"`    // Protocol v2 error class
+	Event    string     `json:"event,omitempty"`    // "progress" for progress events
+	Progress float64    `json:"progress,omitempty"` // Percent done
+	Message  string     `json:"message,omitempty"`  // Progress detail
+}
+
+// errServerExited reports a serve-mode helper that exited while it was needed
+var errServerExited = errors.New("helper server exited")
+
+// server is a running `helper serve` process answering one request at a time
+type server struct {
+	path      string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan serveResponse // Closed when stdout ends
+	stderr    *tailBuffer
+	exited    chan struct{} // Closed once the process has been waited for
+	nextID    atomic.Int64
+}
+
+// serverPool keeps idle serve-mode helper processes for reuse
+type serverPool struct {
+	mu      sync.Mutex
+	idle    map[string][]*server // By helper path
+	running map[*server]bool     // Every started server, idle or busy
+	crashes map[string]int       // Consecutive failed starts and crashes, by helper path
+	closed  bool
+}
+
+// tailBuffer keeps the last bytes written to it (a server's stderr)
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+// circuitKey identifies the circuit of one helper for one format pair
+type circuitKey struct {
+	helper string
+	from   string
+	to     string
 }
+
+// circuit tracks the recent failures of a helper for a format pair
+// It is closed while failures stay below the threshold, open (the helper is
+// skipped) for the cooldown after that, then half-open: one trial conversion
+// decides whether it closes again or stays open for another cooldown
+type circuit struct {
+	failures int       // Consecutive failures
+	timeouts int       // Consecutive timeouts, counted apart from failures
+	openedAt time.Time // Zero while closed
+	trial    bool      // A half-open trial conversion is running
+}
+
+// breaker holds the circuits of every helper and format pair
+type breaker struct {
+	mu        sync.Mutex
+	threshold int           // Consecutive failures that open a circuit
+	timeouts  int           // Consecutive timeouts that open a circuit
+	cooldown  time.Duration // Time an open circuit waits before a trial
+	now       func() time.Time
+	circuits  map[circuitKey]*circuit
+}
+
+// failureKind says what a failed conversion tells about the helper
+type failureKind int
+
+const (
+	failureBroken      failureKind = iota // Crashed or failed without saying why
+	failureInput                          // The input is at fault: corrupted or unsupported
+	failureTransient                      // Temporary; retried after a backoff
+	failureTimeout                        // Ran out of time
+	failureToolMissing                    // Cannot work until something is installed
+)
@@ -0,0 +1,369 @@
+package metadata
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/parser"
+)
+
+// NewAnnotator creates an annotator using the metadata configuration
+func NewAnnotator(cfg internal.MetadataConfig) *Annotator {
+	return &Annotator{
+		config: cfg,
+		// Checksums are computed after writing, never for the pre-edit file
+		parser: parser.NewParser(""),
+		writers: map[internal.DocumentFormat]embedFunc{
+			internal.FormatEPUB: embedEPUB,
+			internal.FormatFB2:  embedFB2,
+			internal.FormatDOCX: embedDOCX,
+			internal.FormatPDF:  embedPDF,
+		},
+	}
+}
+
+// CanEmbed reports whether metadata can be written into documents of this format
+func (a *Annotator) CanEmbed(format internal.DocumentFormat) bool {
+	_, ok := a.writers[format]
+	return ok
+}
+
+// Annotate merges updates into the document's existing metadata and stores the result:
+// embedded in place when metadata.embed is set and the format supports it, and in a
+// <file>.json sidecar when metadata.sidecar is set and embedding was not possible or
+// dropped fields
+func (a *Annotator) Annotate(ctx context.Context, path string, format internal.DocumentFormat, updates *internal.DocumentMetadata) (*Result, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%w: %s", internal.ErrInvalidInput, path)
+	}
+
+	// Start from what the document already carries so unrelated fields survive
+	existing, err := a.parser.ParseFormat(ctx, path, format)
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("No existing metadata, starting empty")
+		existing = &internal.DocumentMetadata{}
+	}
+	merged := Merge(existing, updates)
+
+	result := &Result{Metadata: merged}
+	needSidecar := true
+
+	if a.config.Embed {
+		if embed, ok := a.writers[format]; ok {
+			skipped, err := embed(ctx, path, existing, merged)
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Str("path", path).
+					Str("format", string(format)).
+					Msg("Failed to embed metadata")
+				if !a.config.Sidecar {
+					return nil, fmt.Errorf("failed to embed metadata: %w", err)
+				}
+			} else {
+				result.Embedded = true
+				result.Skipped = skipped
+				needSidecar = len(skipped) > 0
+			}
+		} else {
+			log.Debug().Str("format", string(format)).Msg("Format does not support embedded metadata")
+		}
+	}
+
+	if a.config.Checksum != "" {
+		sum, err := parser.Checksum(path, a.config.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		merged.Checksum = sum
+	}
+
+	if needSidecar && a.config.Sidecar {
+		sidecar, err := WriteSidecar(path, merged)
+		if err != nil {
+			return nil, err
+		}
+		result.Sidecar = sidecar
+	}
+
+	if !result.Embedded && result.Sidecar == "" {
+		return nil, fmt.Errorf("%w: metadata for %s was neither embedded nor written to a sidecar (check metadata.embed and metadata.sidecar)",
+			internal.ErrUnsupportedFormat, format)
+	}
+
+	log.Info().
+		Str("path", path).
+		Bool("embedded", result.Embedded).
+		Str("sidecar", result.Sidecar).
+		Strs("skipped", result.Skipped).
+		Msg("Annotated document")
+
+	return result, nil
+}
+
+// Merge overlays non-empty fields of updates onto base and returns a new record
+// Tags are replaced when updates has any; custom fields are merged key by key
+func Merge(base, updates *internal.DocumentMetadata) *internal.DocumentMetadata {
+	merged := *base
+	merged.Tags = append([]string(nil), base.Tags...)
+	merged.Custom = make(map[string]string, len(base.Custom))
+	for k, v := range base.Custom {
+		merged.Custom[k] = v
+	}
+
+	if updates == nil {
+		return &merged
+	}
+	if updates.Title != "" {
+		merged.Title = updates.Title
+	}
+	if updates.Author != "" {
+		merged.Author = updates.Author
+	}
+	if updates.Language != "" {
+		merged.Language = updates.Language
+	}
+	if updates.Category != "" {
+		merged.Category = updates.Category
+	}
+	if len(updates.Tags) > 0 {
+		merged.Tags = append([]string(nil), updates.Tags...)
+	}
+	if updates.Description != "" {
+		merged.Description = updates.Description
+	}
+	if !updates.Created.IsZero() {
+		merged.Created = updates.Created
+	}
+	if !updates.Modified.IsZero() {
+		merged.Modified = updates.Modified
+	}
+	if updates.PageCount > 0 {
+		merged.PageCount = updates.PageCount
+	}
+	for k, v := range updates.Custom {
+		merged.Custom[k] = v
+	}
+	if len(merged.Custom) == 0 {
+		merged.Custom = nil
+	}
+	return &merged
+}
+
+// SidecarPath returns the sidecar location for a document
+func SidecarPath(path string) string {
+	return path + SidecarSuffix
+}
+
+// WriteSidecar writes meta as <path>.json and returns the sidecar path
+func WriteSidecar(path string, meta *internal.DocumentMetadata) (string, error) {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	sidecar := SidecarPath(path)
+	if err := writeFileAtomic(sidecar, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to write sidecar: %w", err)
+	}
+
+	log.Debug().Str("sidecar", sidecar).Msg("Wrote metadata sidecar")
+	return sidecar, nil
+}
+
+// writeFileAtomic writes path through a temp file in the same directory and renames it
+// The original file mode is preserved when path already exists
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".yakateka-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op after a successful rename
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	return os.Rename(tmpName, path)
+}
+
+// errEntryNotFound is returned by rewriteZip when a required entry is missing
+var errEntryNotFound = errors.New("archive entry not found")
+
+// rewriteZip copies a ZIP archive, replacing the content of one entry via edit
+// Other entries (including an uncompressed EPUB mimetype) are copied verbatim
+func rewriteZip(path, entry string, edit func(content []byte) ([]byte, error)) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer r.Close()
+
+	found := false
+	for _, f := range r.File {
+		if f.Name == entry {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", errEntryNotFound, entry)
+	}
+
+	return writeFileAtomic(path, func(out io.Writer) error {
+		w := zip.NewWriter(out)
+		for _, f := range r.File {
+			if f.Name != entry {
+				if err := w.Copy(f); err != nil {
+					return fmt.Errorf("failed to copy %s: %w", f.Name, err)
+				}
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", f.Name, err)
+			}
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", f.Name, err)
+			}
+
+			edited, err := edit(content)
+			if err != nil {
+				return err
+			}
+
+			header := f.FileHeader
+			header.Modified = time.Now()
+			fw, err := w.CreateHeader(&header)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", f.Name, err)
+			}
+			if _, err := fw.Write(edited); err != nil {
+				return fmt.Errorf("failed to write %s: %w", f.Name, err)
+			}
+		}
+		return w.Close()
+	})
+}
+
+// sortedKeys returns map keys in sorted order for deterministic output
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diff compares stored metadata with the updated record
+func diff(old, meta *internal.DocumentMetadata) changes {
+	c := changes{
+		title:       old.Title != meta.Title,
+		author:      old.Author != meta.Author,
+		language:    old.Language != meta.Language,
+		category:    old.Category != meta.Category,
+		tags:        !slices.Equal(old.Tags, meta.Tags),
+		description: old.Description != meta.Description,
+		created:     !old.Created.Equal(meta.Created),
+		modified:    !old.Modified.Equal(meta.Modified),
+	}
+	for _, k := range sortedKeys(meta.Custom) {
+		if old.Custom[k] != meta.Custom[k] {
+			c.custom = append(c.custom, k)
+		}
+	}
+	return c
+}
+
+// customField names a custom key in skipped field lists
+func customField(key string) string {
+	return FieldCustom + "." + key
+}
+
+// Read returns the metadata worth carrying from a document to its conversions
+// Page count and checksum describe the file itself and are dropped; nil means
+// the document has no readable metadata
+func (a *Annotator) Read(ctx context.Context, path string, format internal.DocumentFormat) *internal.DocumentMetadata {
+	meta, err := a.parser.ParseFormat(ctx, path, format)
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("No source metadata to preserve")
+		return nil
+	}
+	meta.PageCount = 0
+	meta.Checksum = ""
+	if len(Fields(meta)) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// Preserve applies source metadata to a converted document
+// Unlike Annotate, a target that can hold nothing is not an error: the result
+// lists every source field as skipped
+func (a *Annotator) Preserve(ctx context.Context, output string, format internal.DocumentFormat, source *internal.DocumentMetadata) (*Result, error) {
+	if !(a.config.Embed && a.CanEmbed(format)) && !a.config.Sidecar {
+		return &Result{Metadata: source, Skipped: Fields(source)}, nil
+	}
+
+	result, err := a.Annotate(ctx, output, format, source)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Embedded {
+		result.Skipped = Fields(source)
+	}
+	return result, nil
+}
+
+// Fields lists the populated fields of a metadata record
+// Custom fields are reported as custom.<key>
+func Fields(meta *internal.DocumentMetadata) []string {
+	var fields []string
+	add := func(name string, set bool) {
+		if set {
+			fields = append(fields, name)
+		}
+	}
+	add(FieldTitle, meta.Title != "")
+	add(FieldAuthor, meta.Author != "")
+	add(FieldLanguage, meta.Language != "")
+	add(FieldCategory, meta.Category != "")
+	add(FieldTags, len(meta.Tags) > 0)
+	add(FieldDescription, meta.Description != "")
+	add(FieldCreated, !meta.Created.IsZero())
+	add(FieldModified, !meta.Modified.IsZero())
+	for _, key := range sortedKeys(meta.Custom) {
+		fields = append(fields, customField(key))
+	}
+	return fields
+}
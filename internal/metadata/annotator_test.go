@@ -0,0 +1,334 @@
+package metadata
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/parser"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// writeZip creates a ZIP file with entries in the given order (name, content pairs)
+func writeZip(t *testing.T, path string, entries ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for i := 0; i+1 < len(entries); i += 2 {
+		header := &zip.FileHeader{Name: entries[i], Method: zip.Deflate}
+		if entries[i] == "mimetype" {
+			header.Method = zip.Store
+		}
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("Failed to add zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(entries[i+1])); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+}
+
+// readBack parses a document with the metadata parser
+func readBack(t *testing.T, path string, format internal.DocumentFormat) *internal.DocumentMetadata {
+	t.Helper()
+	meta, err := parser.NewParser("").ParseFormat(context.Background(), path, format)
+	if err != nil {
+		t.Fatalf("Failed to parse annotated document: %v", err)
+	}
+	return meta
+}
+
+func embedOnly() internal.MetadataConfig {
+	return internal.MetadataConfig{Embed: true}
+}
+
+func TestAnnotateEPUB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeZip(t, path,
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1234</dc:identifier>
+    <dc:title>Old Title</dc:title>
+    <dc:creator id="a1">Old Author</dc:creator>
+    <dc:creator id="e1">Some Editor</dc:creator>
+    <meta refines="#e1" property="role">edt</meta>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest/>
+  <spine/>
+</package>`,
+	)
+
+	a := NewAnnotator(embedOnly())
+	result, err := a.Annotate(context.Background(), path, internal.FormatEPUB, &internal.DocumentMetadata{
+		Title:    "Кобзар",
+		Author:   "Taras Shevchenko",
+		Language: "uk",
+		Tags:     []string{"poetry", "classics"},
+		Custom:   map[string]string{"calibre:series": "Poems"},
+	})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !result.Embedded || result.Sidecar != "" || len(result.Skipped) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	meta := readBack(t, path, internal.FormatEPUB)
+	if meta.Title != "Кобзар" || meta.Author != "Taras Shevchenko" || meta.Language != "uk" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if strings.Join(meta.Tags, ",") != "poetry,classics" {
+		t.Errorf("Expected tags to be written, got %v", meta.Tags)
+	}
+	if meta.Custom["calibre:series"] != "Poems" || meta.Custom["identifier"] != "urn:uuid:1234" {
+		t.Errorf("Unexpected custom fields: %v", meta.Custom)
+	}
+
+	// The editor keeps its role; mimetype stays the first, stored entry
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen EPUB: %v", err)
+	}
+	defer r.Close()
+	if r.File[0].Name != "mimetype" || r.File[0].Method != zip.Store {
+		t.Errorf("mimetype must remain the first stored entry, got %s (method %d)", r.File[0].Name, r.File[0].Method)
+	}
+	rc, _ := r.File[2].Open()
+	opf, _ := io.ReadAll(rc)
+	rc.Close()
+	if !strings.Contains(string(opf), `<dc:creator id="e1">Some Editor</dc:creator>`) {
+		t.Errorf("Editor should be preserved:\n%s", opf)
+	}
+}
+
+func TestAnnotateFB2(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="windows-1251"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <genre>prose</genre>
+      <author><first-name>Old</first-name><last-name>Author</last-name></author>
+      <book-title>Старий заголовок</book-title>
+      <lang>uk</lang>
+    </title-info>
+    <document-info><id>doc-1</id></document-info>
+  </description>
+  <body><p>Текст</p></body>
+</FictionBook>`
+	encoded, err := charmap.Windows1251.NewEncoder().String(doc)
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "book.fb2")
+	if err := os.WriteFile(path, []byte(encoded), 0644); err != nil {
+		t.Fatalf("Failed to write FB2: %v", err)
+	}
+
+	a := NewAnnotator(embedOnly())
+	_, err = a.Annotate(context.Background(), path, internal.FormatFB2, &internal.DocumentMetadata{
+		Title:       "Новий заголовок",
+		Author:      "Ivan Yakovych Franko",
+		Description: "Short <annotation>",
+		Tags:        []string{"classic"},
+		Custom:      map[string]string{"series": "Works", "series_index": "3", "source": "scan"},
+	})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `encoding="UTF-8"`) || !strings.Contains(string(data), "<p>Текст</p>") {
+		t.Errorf("Expected a UTF-8 document with the body intact:\n%s", data)
+	}
+
+	meta := readBack(t, path, internal.FormatFB2)
+	if meta.Title != "Новий заголовок" || meta.Author != "Ivan Yakovych Franko" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if meta.Description != "Short <annotation>" {
+		t.Errorf("Unexpected description: %q", meta.Description)
+	}
+	if meta.Category != "prose" || strings.Join(meta.Tags, ",") != "prose,classic" {
+		t.Errorf("Unexpected category/tags: %q %v", meta.Category, meta.Tags)
+	}
+	if meta.Custom["series"] != "Works" || meta.Custom["series_index"] != "3" || meta.Custom["source"] != "scan" {
+		t.Errorf("Unexpected custom fields: %v", meta.Custom)
+	}
+}
+
+func TestAnnotateDOCX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.docx")
+	writeZip(t, path,
+		"[Content_Types].xml", `<Types/>`,
+		"word/document.xml", `<w:document/>`,
+		"docProps/core.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <dc:title>Old</dc:title>
+  <dc:creator>Someone</dc:creator>
+</cp:coreProperties>`,
+	)
+
+	a := NewAnnotator(internal.MetadataConfig{Embed: true, Sidecar: true, Checksum: parser.ChecksumSHA256})
+	result, err := a.Annotate(context.Background(), path, internal.FormatDOCX, &internal.DocumentMetadata{
+		Title:    "Report",
+		Category: "finance",
+		Tags:     []string{"q1", "budget"},
+		Custom:   map[string]string{"project": "apollo"},
+	})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !result.Embedded {
+		t.Error("Expected metadata to be embedded")
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "custom.project" {
+		t.Errorf("Expected custom.project to be skipped, got %v", result.Skipped)
+	}
+	if result.Sidecar != SidecarPath(path) {
+		t.Errorf("Expected a sidecar for skipped fields, got %q", result.Sidecar)
+	}
+
+	meta := readBack(t, path, internal.FormatDOCX)
+	if meta.Title != "Report" || meta.Author != "Someone" || meta.Category != "finance" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if strings.Join(meta.Tags, ",") != "q1,budget" {
+		t.Errorf("Unexpected tags: %v", meta.Tags)
+	}
+
+	data, err := os.ReadFile(result.Sidecar)
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	var sidecar internal.DocumentMetadata
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatalf("Invalid sidecar: %v", err)
+	}
+	want, _ := parser.Checksum(path, parser.ChecksumSHA256)
+	if sidecar.Custom["project"] != "apollo" || sidecar.Checksum != want {
+		t.Errorf("Unexpected sidecar: %+v (want checksum %s)", sidecar, want)
+	}
+}
+
+func TestAnnotatePDF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	pdf := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n" +
+		"3 0 obj\n<< /Title (Old) /Producer (Test) >>\nendobj\n"
+	xref := len(pdf)
+	pdf += "xref\n0 4\n0000000000 65535 f \n" +
+		"trailer\n<< /Size 4 /Root 1 0 R /Info 3 0 R >>\nstartxref\n" +
+		strconv.Itoa(xref) + "\n%%EOF\n"
+	if err := os.WriteFile(path, []byte(pdf), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	a := NewAnnotator(embedOnly())
+	result, err := a.Annotate(context.Background(), path, internal.FormatPDF, &internal.DocumentMetadata{
+		Title:  "Звіт",
+		Author: "A (B) C",
+		Tags:   []string{"x", "y"},
+		Custom: map[string]string{"Company": "ACME"},
+	})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !result.Embedded {
+		t.Error("Expected metadata to be embedded")
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), pdf) {
+		t.Error("Incremental update must keep the original bytes")
+	}
+	if !strings.Contains(string(data), "/Prev "+strconv.Itoa(xref)) {
+		t.Error("Expected the new trailer to reference the previous xref")
+	}
+
+	meta := readBack(t, path, internal.FormatPDF)
+	if meta.Title != "Звіт" || meta.Author != "A (B) C" || strings.Join(meta.Tags, ",") != "x,y" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if meta.Custom["producer"] != "Test" || meta.Custom["Company"] != "ACME" {
+		t.Errorf("Unexpected custom fields: %v", meta.Custom)
+	}
+	if meta.Modified.IsZero() {
+		t.Error("Expected ModDate to be set")
+	}
+}
+
+func TestAnnotateSidecarOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	a := NewAnnotator(internal.MetadataConfig{Embed: true, Sidecar: true, Checksum: parser.ChecksumMD5})
+	result, err := a.Annotate(context.Background(), path, internal.FormatTXT, &internal.DocumentMetadata{Title: "Notes"})
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if result.Embedded || result.Sidecar == "" {
+		t.Errorf("Expected sidecar only, got %+v", result)
+	}
+	if !strings.HasPrefix(result.Metadata.Checksum, "md5:") {
+		t.Errorf("Expected md5 checksum, got %q", result.Metadata.Checksum)
+	}
+
+	// With sidecars disabled there is nowhere to store metadata
+	a = NewAnnotator(internal.MetadataConfig{Embed: true})
+	if _, err := a.Annotate(context.Background(), path, internal.FormatTXT, &internal.DocumentMetadata{Title: "Notes"}); err == nil {
+		t.Error("Expected an error when neither embedding nor sidecar is possible")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := &internal.DocumentMetadata{
+		Title:  "Old",
+		Author: "Kept",
+		Tags:   []string{"a"},
+		Custom: map[string]string{"k1": "v1"},
+	}
+	merged := Merge(base, &internal.DocumentMetadata{
+		Title:  "New",
+		Tags:   []string{"b", "c"},
+		Custom: map[string]string{"k2": "v2"},
+	})
+
+	if merged.Title != "New" || merged.Author != "Kept" {
+		t.Errorf("Unexpected merge: %+v", merged)
+	}
+	if strings.Join(merged.Tags, ",") != "b,c" {
+		t.Errorf("Tags should be replaced, got %v", merged.Tags)
+	}
+	if merged.Custom["k1"] != "v1" || merged.Custom["k2"] != "v2" {
+		t.Errorf("Custom fields should be merged, got %v", merged.Custom)
+	}
+	if len(base.Custom) != 1 || base.Title != "Old" {
+		t.Error("Merge must not modify the base record")
+	}
+}
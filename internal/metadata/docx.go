@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// OPC core properties namespaces
+const (
+	corePropertiesNamespace = "http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	dctermsNamespace        = "http://purl.org/dc/terms/"
+	xsiNamespace            = "http://www.w3.org/2001/XMLSchema-instance"
+	docxCorePath            = "docProps/core.xml"
+)
+
+// embedDOCX edits docProps/core.xml
+// Core properties have no place for custom fields other than subject, so changed ones
+// are reported as skipped
+func embedDOCX(ctx context.Context, path string, old, meta *internal.DocumentMetadata) ([]string, error) {
+	c := diff(old, meta)
+
+	err := rewriteZip(path, docxCorePath, func(content []byte) ([]byte, error) {
+		doc := string(content)
+		dc := namespacePrefix(doc, dcNamespace, "dc")
+		cp := namespacePrefix(doc, corePropertiesNamespace, "cp")
+		dcterms := namespacePrefix(doc, dctermsNamespace, "dcterms")
+		xsi := namespacePrefix(doc, xsiNamespace, "xsi")
+
+		edited, err := editSection(doc, "coreProperties", func(inner string) string {
+			if c.title {
+				inner = replaceElements(inner, "title", nil, textElements(dc+":title", meta.Title))
+			}
+			if c.author {
+				inner = replaceElements(inner, "creator", nil, textElements(dc+":creator", meta.Author))
+			}
+			if c.language {
+				inner = replaceElements(inner, "language", nil, textElements(dc+":language", meta.Language))
+			}
+			if c.tags {
+				inner = replaceElements(inner, "keywords", nil, textElements(cp+":keywords", strings.Join(meta.Tags, ", ")))
+			}
+			if c.description {
+				inner = replaceElements(inner, "description", nil, textElements(dc+":description", meta.Description))
+			}
+			if c.category {
+				inner = replaceElements(inner, "category", nil, textElements(cp+":category", meta.Category))
+			}
+			if slices.Contains(c.custom, "subject") {
+				inner = replaceElements(inner, "subject", nil, textElements(dc+":subject", meta.Custom["subject"]))
+			}
+			if c.created {
+				inner = replaceElements(inner, "created", nil, w3cdtfElements(dcterms, xsi, "created", meta.Created))
+			}
+			if c.modified {
+				inner = replaceElements(inner, "modified", nil, w3cdtfElements(dcterms, xsi, "modified", meta.Modified))
+			}
+			return inner
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", docxCorePath, err)
+		}
+		return []byte(edited), nil
+	})
+	if errors.Is(err, errEntryNotFound) {
+		// Adding core.xml would also require new relationships and content types
+		return nil, fmt.Errorf("%w: DOCX without %s", internal.ErrUnsupportedFormat, docxCorePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	for _, key := range c.custom {
+		if key != "subject" {
+			skipped = append(skipped, customField(key))
+		}
+	}
+	return skipped, nil
+}
+
+// w3cdtfElements returns a dcterms date element, or none for a zero time
+func w3cdtfElements(dcterms, xsi, local string, t time.Time) []xmlElement {
+	if t.IsZero() {
+		return nil
+	}
+	return []xmlElement{{
+		name:  dcterms + ":" + local,
+		attrs: map[string]string{xsi + ":type": dcterms + ":W3CDTF"},
+		value: t.UTC().Format(time.RFC3339),
+	}}
+}
@@ -0,0 +1,168 @@
+package metadata
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/parser"
+)
+
+// dcNamespace is the Dublin Core elements namespace used by OPF
+const dcNamespace = "http://purl.org/dc/elements/1.1/"
+
+var (
+	opfIDPattern   = regexp.MustCompile(`\sid\s*=\s*["']([^"']+)["']`)
+	opfRolePattern = regexp.MustCompile(`<meta\s[^>]*refines\s*=\s*["']#([^"']+)["'][^>]*property\s*=\s*["']role["'][^>]*>\s*([^<\s]+)\s*</meta>`)
+)
+
+// embedEPUB edits the <metadata> section of the OPF package document
+// Category has no OPF equivalent and the identifier is left alone because the
+// package's unique-identifier refers to it
+func embedEPUB(ctx context.Context, path string, old, meta *internal.DocumentMetadata) ([]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	opfPath, err := parser.FindOPF(&r.Reader)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c := diff(old, meta)
+	var skipped []string
+
+	err = rewriteZip(path, opfPath, func(content []byte) ([]byte, error) {
+		doc := string(content)
+		dc := namespacePrefix(doc, dcNamespace, "dc")
+		name := func(local string) string {
+			if dc == "" {
+				return local
+			}
+			return dc + ":" + local
+		}
+
+		edited, err := editSection(doc, "metadata", func(inner string) string {
+			if c.title {
+				inner = replaceElements(inner, "title", nil, textElements(name("title"), meta.Title))
+			}
+			if c.author {
+				var authors []xmlElement
+				for _, a := range splitAuthors(meta.Author) {
+					authors = append(authors, xmlElement{name: name("creator"), value: a})
+				}
+				// Contributors with a non-author role (editors, illustrators) are kept
+				contributors := opfContributorIDs(inner)
+				inner = replaceElements(inner, "creator", func(e string) bool {
+					if m := opfIDPattern.FindStringSubmatch(openingTag(e)); m != nil && contributors[m[1]] {
+						return false
+					}
+					return !strings.Contains(openingTag(e), "role=") || hasAttr(e, "role", "aut")
+				}, authors, "title")
+			}
+			if c.language {
+				inner = replaceElements(inner, "language", nil, textElements(name("language"), meta.Language))
+			}
+			if c.tags {
+				var subjects []xmlElement
+				for _, tag := range meta.Tags {
+					subjects = append(subjects, xmlElement{name: name("subject"), value: tag})
+				}
+				inner = replaceElements(inner, "subject", nil, subjects)
+			}
+			if c.description {
+				inner = replaceElements(inner, "description", nil, textElements(name("description"), meta.Description))
+			}
+			if c.created {
+				var dates []xmlElement
+				if !meta.Created.IsZero() {
+					dates = textElements(name("date"), meta.Created.UTC().Format("2006-01-02"))
+				}
+				inner = replaceElements(inner, "date", func(e string) bool {
+					return !hasAttr(e, "event", "modification")
+				}, dates)
+			}
+			if c.modified && !meta.Modified.IsZero() {
+				inner = replaceElements(inner, "meta", func(e string) bool {
+					return hasAttr(e, "property", "dcterms:modified")
+				}, []xmlElement{{
+					name:  "meta",
+					attrs: map[string]string{"property": "dcterms:modified"},
+					value: meta.Modified.UTC().Format(time.RFC3339),
+				}})
+			}
+			for _, key := range c.custom {
+				value := meta.Custom[key]
+				switch key {
+				case "publisher":
+					inner = replaceElements(inner, "publisher", nil, textElements(name("publisher"), value))
+				case "identifier":
+					skipped = append(skipped, customField(key))
+				default:
+					inner = replaceElements(inner, "meta", func(e string) bool {
+						return hasAttr(e, "name", key)
+					}, []xmlElement{{
+						name:  "meta",
+						attrs: map[string]string{"name": key, "content": value},
+					}})
+				}
+			}
+			return inner
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPF %s: %w", opfPath, err)
+		}
+		return []byte(edited), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.category && meta.Category != "" {
+		skipped = append(skipped, FieldCategory)
+	}
+	return skipped, nil
+}
+
+// opfContributorIDs returns creator IDs refined with a role other than author (OPF 3)
+func opfContributorIDs(inner string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, m := range opfRolePattern.FindAllStringSubmatch(inner, -1) {
+		if m[2] != "aut" {
+			ids[m[1]] = true
+		}
+	}
+	return ids
+}
+
+// textElements returns a single text element, or none for an empty value
+func textElements(name, value string) []xmlElement {
+	if value == "" {
+		return nil
+	}
+	return []xmlElement{{name: name, value: value}}
+}
+
+// splitAuthors splits a comma-separated author list
+func splitAuthors(author string) []string {
+	var authors []string
+	for _, a := range strings.Split(author, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			authors = append(authors, a)
+		}
+	}
+	return authors
+}
+
+// openingTag returns the opening tag of an element's markup
+func openingTag(element string) string {
+	if end := strings.IndexByte(element, '>'); end >= 0 {
+		return element[:end]
+	}
+	return element
+}
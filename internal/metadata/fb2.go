@@ -0,0 +1,186 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// xmlEncodingPattern matches the encoding pseudo-attribute of the XML declaration
+var xmlEncodingPattern = regexp.MustCompile(`^(\s*<\?xml[^>]*?encoding\s*=\s*["'])([^"']+)(["'])`)
+
+// fb2PublishFields are custom keys stored in <publish-info>
+var fb2PublishFields = map[string]bool{
+	"publisher": true,
+	"year":      true,
+	"isbn":      true,
+}
+
+// embedFB2 edits <title-info> (and publish/custom info) of an FB2 document
+// Files in legacy encodings are rewritten as UTF-8
+func embedFB2(ctx context.Context, path string, old, meta *internal.DocumentMetadata) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FB2: %w", err)
+	}
+	doc, err := fb2ToUTF8(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c := diff(old, meta)
+	var skipped []string
+
+	doc, err = editSection(doc, "title-info", func(inner string) string {
+		if c.category {
+			inner = replaceElements(inner, "genre", nil, textElements("genre", meta.Category))
+		}
+		if c.author {
+			var authors []xmlElement
+			for _, a := range splitAuthors(meta.Author) {
+				authors = append(authors, fb2AuthorElement(a))
+			}
+			inner = replaceElements(inner, "author", nil, authors, "genre")
+		}
+		if c.title {
+			inner = replaceElements(inner, "book-title", nil, textElements("book-title", meta.Title), "author", "genre")
+		}
+		if c.description {
+			var annotation []xmlElement
+			if meta.Description != "" {
+				annotation = []xmlElement{{name: "annotation", value: "<p>" + escapeXML(meta.Description) + "</p>", raw: true}}
+			}
+			inner = replaceElements(inner, "annotation", nil, annotation, "book-title", "author")
+		}
+		if c.tags || c.category {
+			// Genres are reported as tags too; keep only the remaining ones as keywords
+			var keywords []string
+			for _, tag := range meta.Tags {
+				if tag != meta.Category {
+					keywords = append(keywords, tag)
+				}
+			}
+			inner = replaceElements(inner, "keywords", nil, textElements("keywords", strings.Join(keywords, ", ")),
+				"annotation", "book-title")
+		}
+		if c.created {
+			var date []xmlElement
+			if !meta.Created.IsZero() {
+				date = []xmlElement{{
+					name:  "date",
+					attrs: map[string]string{"value": meta.Created.Format("2006-01-02")},
+					value: meta.Created.Format("2006"),
+				}}
+			}
+			inner = replaceElements(inner, "date", nil, date, "keywords", "annotation", "book-title")
+		}
+		if c.language {
+			inner = replaceElements(inner, "lang", nil, textElements("lang", meta.Language),
+				"coverpage", "date", "keywords", "annotation", "book-title")
+		}
+		if slices.Contains(c.custom, "series") || slices.Contains(c.custom, "series_index") {
+			var sequence []xmlElement
+			if name := meta.Custom["series"]; name != "" {
+				attrs := map[string]string{"name": name}
+				if number := meta.Custom["series_index"]; number != "" {
+					attrs["number"] = number
+				}
+				sequence = []xmlElement{{name: "sequence", attrs: attrs}}
+			}
+			inner = replaceElements(inner, "sequence", nil, sequence, "translator", "src-lang", "lang")
+		}
+		return inner
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: FB2 %v", internal.ErrInvalidInput, err)
+	}
+
+	for _, key := range c.custom {
+		value := meta.Custom[key]
+		switch {
+		case key == "series" || key == "series_index":
+			// Written as <sequence> above
+		case key == "document_id":
+			// The document ID identifies the file itself; never rewritten
+			skipped = append(skipped, customField(key))
+		case fb2PublishFields[key]:
+			edited, err := editSection(doc, "publish-info", func(inner string) string {
+				return replaceElements(inner, key, nil, textElements(key, value))
+			})
+			if err != nil {
+				skipped = append(skipped, customField(key))
+				continue
+			}
+			doc = edited
+		default:
+			doc, err = editSection(doc, "description", func(inner string) string {
+				return replaceElements(inner, "custom-info", func(e string) bool {
+					return hasAttr(e, "info-type", key)
+				}, []xmlElement{{name: "custom-info", attrs: map[string]string{"info-type": key}, value: value}},
+					"custom-info", "publish-info", "document-info", "title-info")
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%w: FB2 %v", internal.ErrInvalidInput, err)
+			}
+		}
+	}
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, doc)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write FB2: %w", err)
+	}
+	return skipped, nil
+}
+
+// fb2ToUTF8 decodes an FB2 document and declares it as UTF-8
+func fb2ToUTF8(data []byte) (string, error) {
+	m := xmlEncodingPattern.FindSubmatchIndex(data)
+	if m == nil {
+		return string(data), nil
+	}
+
+	charset := strings.ToLower(string(data[m[4]:m[5]]))
+	if charset == "utf-8" || charset == "utf8" {
+		return string(data), nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return "", fmt.Errorf("unsupported charset %s: %w", charset, err)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", charset, err)
+	}
+
+	doc := string(decoded)
+	return xmlEncodingPattern.ReplaceAllString(doc, "${1}UTF-8${3}"), nil
+}
+
+// fb2AuthorElement splits a display name into FB2 first/middle/last name parts
+func fb2AuthorElement(name string) xmlElement {
+	parts := strings.Fields(name)
+	var inner strings.Builder
+	switch len(parts) {
+	case 0:
+	case 1:
+		inner.WriteString("<nickname>" + escapeXML(parts[0]) + "</nickname>")
+	default:
+		inner.WriteString("<first-name>" + escapeXML(parts[0]) + "</first-name>")
+		if len(parts) > 2 {
+			inner.WriteString("<middle-name>" + escapeXML(strings.Join(parts[1:len(parts)-1], " ")) + "</middle-name>")
+		}
+		inner.WriteString("<last-name>" + escapeXML(parts[len(parts)-1]) + "</last-name>")
+	}
+	return xmlElement{name: "author", value: inner.String(), raw: true}
+}
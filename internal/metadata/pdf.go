@@ -0,0 +1,205 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+var (
+	pdfStartXrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+	pdfTrailerPattern   = regexp.MustCompile(`trailer\s*<<`)
+	pdfSizePattern      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRootPattern      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	pdfIDPattern        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+	pdfEncryptPattern   = regexp.MustCompile(`/Encrypt\s+\d+\s+\d+\s+R`)
+)
+
+// pdfInfoKeys maps custom keys to their standard Info dictionary names
+var pdfInfoKeys = map[string]string{
+	"creator":  "Creator",
+	"producer": "Producer",
+}
+
+// embedPDF appends an incremental update with a new document Info dictionary
+// The original bytes are left untouched; files using cross-reference streams or
+// encryption are refused because a classic xref section cannot extend them
+func embedPDF(ctx context.Context, path string, old, meta *internal.DocumentMetadata) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: missing %%PDF- header", internal.ErrInvalidInput)
+	}
+
+	trailer, prev, err := pdfLastTrailer(data)
+	if err != nil {
+		return nil, err
+	}
+	if pdfEncryptPattern.Match(trailer) {
+		return nil, fmt.Errorf("%w: encrypted PDF", internal.ErrUnsupportedFormat)
+	}
+
+	sizeMatch := pdfSizePattern.FindSubmatch(trailer)
+	rootMatch := pdfRootPattern.FindSubmatch(trailer)
+	if sizeMatch == nil || rootMatch == nil {
+		return nil, fmt.Errorf("%w: PDF trailer lacks /Size or /Root", internal.ErrInvalidInput)
+	}
+	size, _ := strconv.Atoi(string(sizeMatch[1]))
+
+	var update bytes.Buffer
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		update.WriteByte('\n')
+	}
+
+	infoOffset := len(data) + update.Len()
+	fmt.Fprintf(&update, "%d 0 obj\n%s\nendobj\n", size, pdfInfoDictionary(meta))
+
+	xrefOffset := len(data) + update.Len()
+	fmt.Fprintf(&update, "xref\n%d 1\n%010d 00000 n \n", size, infoOffset)
+	fmt.Fprintf(&update, "trailer\n<< /Size %d /Root %s /Info %d 0 R /Prev %d", size+1, rootMatch[1], size, prev)
+	if id := pdfIDPattern.Find(trailer); id != nil {
+		update.WriteString(" " + string(id))
+	}
+	fmt.Fprintf(&update, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		_, err := w.Write(update.Bytes())
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	// Language lives in the document catalog and category has no Info entry
+	c := diff(old, meta)
+	var skipped []string
+	if c.language && meta.Language != "" {
+		skipped = append(skipped, FieldLanguage)
+	}
+	if c.category && meta.Category != "" {
+		skipped = append(skipped, FieldCategory)
+	}
+	return skipped, nil
+}
+
+// pdfLastTrailer returns the trailer dictionary of the last classic xref section
+// and that section's offset
+func pdfLastTrailer(data []byte) ([]byte, int, error) {
+	starts := pdfStartXrefPattern.FindAllSubmatch(data, -1)
+	if len(starts) == 0 {
+		return nil, 0, fmt.Errorf("%w: PDF has no startxref", internal.ErrInvalidInput)
+	}
+	prev, err := strconv.Atoi(string(starts[len(starts)-1][1]))
+	if err != nil || prev >= len(data) {
+		return nil, 0, fmt.Errorf("%w: invalid startxref offset", internal.ErrInvalidInput)
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data[prev:], " \t\r\n"), []byte("xref")) {
+		return nil, 0, fmt.Errorf("%w: PDF uses a cross-reference stream", internal.ErrUnsupportedFormat)
+	}
+
+	loc := pdfTrailerPattern.FindIndex(data[prev:])
+	if loc == nil {
+		return nil, 0, fmt.Errorf("%w: PDF has no trailer", internal.ErrInvalidInput)
+	}
+	start := prev + loc[1] - 2
+	end := bytes.Index(data[start:], []byte("startxref"))
+	if end < 0 {
+		end = len(data) - start
+	}
+	return data[start : start+end], prev, nil
+}
+
+// pdfInfoDictionary renders the Info dictionary for meta
+func pdfInfoDictionary(meta *internal.DocumentMetadata) string {
+	var b strings.Builder
+	b.WriteString("<<")
+	entry := func(key, value string) {
+		if value != "" {
+			b.WriteString("\n/" + pdfName(key) + " " + pdfString(value))
+		}
+	}
+
+	entry("Title", meta.Title)
+	entry("Author", meta.Author)
+	entry("Subject", meta.Description)
+	entry("Keywords", strings.Join(meta.Tags, ", "))
+	for _, key := range sortedKeys(meta.Custom) {
+		if name, ok := pdfInfoKeys[key]; ok {
+			entry(name, meta.Custom[key])
+		} else {
+			entry(key, meta.Custom[key])
+		}
+	}
+	if !meta.Created.IsZero() {
+		entry("CreationDate", pdfDate(meta.Created))
+	}
+	entry("ModDate", pdfDate(time.Now()))
+
+	b.WriteString("\n>>")
+	return b.String()
+}
+
+// pdfName escapes a PDF name token
+func pdfName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < '!' || c > '~' || c == '#' || strings.IndexByte("()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// pdfString encodes text as a literal string, or UTF-16BE hex when it is not ASCII
+func pdfString(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+		return "(" + r.Replace(s) + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfDate formats a time as a PDF date string
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return t.Format("D:20060102150405Z")
+	}
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
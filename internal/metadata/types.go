@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"context"
+
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/parser"
+)
+
+// Metadata fields reported when they cannot be embedded
+const (
+	FieldTitle       = "title"
+	FieldAuthor      = "author"
+	FieldLanguage    = "language"
+	FieldCategory    = "category"
+	FieldTags        = "tags"
+	FieldDescription = "description"
+	FieldCreated     = "created"
+	FieldModified    = "modified"
+	FieldCustom      = "custom"
+)
+
+// SidecarSuffix is appended to the document path for sidecar files
+const SidecarSuffix = ".json"
+
+// embedFunc writes metadata into a document in place
+// old is the metadata already in the document; writers only touch fields that changed
+// Returns the changed fields the format could not hold
+type embedFunc func(ctx context.Context, path string, old, meta *internal.DocumentMetadata) ([]string, error)
+
+// Annotator embeds metadata into documents or writes JSON sidecars
+// Behaviour follows MetadataConfig: Embed, Sidecar and Checksum
+type Annotator struct {
+	config  internal.MetadataConfig
+	parser  *parser.Parser
+	writers map[internal.DocumentFormat]embedFunc
+}
+
+// Result describes what Annotate did
+type Result struct {
+	Metadata *internal.DocumentMetadata `json:"metadata" yaml:"metadata"`
+	Embedded bool                       `json:"embedded" yaml:"embedded"`
+	Sidecar  string                     `json:"sidecar,omitempty" yaml:"sidecar,omitempty"`
+	Skipped  []string                   `json:"skipped,omitempty" yaml:"skipped,omitempty"` // Fields not embedded
+}
+
+// changes records which fields differ between stored and updated metadata
+type changes struct {
+	title       bool
+	author      bool
+	language    bool
+	category    bool
+	tags        bool
+	description bool
+	created     bool
+	modified    bool
+	custom      []string // Changed custom keys, sorted
+}
@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// xmlElement is an element to insert into a metadata section
+type xmlElement struct {
+	name  string            // Qualified name, e.g. "dc:title"
+	attrs map[string]string // Optional attributes
+	value string            // Text content (escaped on output)
+	raw   bool              // value is already markup
+}
+
+// render returns the element markup
+func (e xmlElement) render() string {
+	var b strings.Builder
+	b.WriteString("<" + e.name)
+	for _, k := range sortedKeys(e.attrs) {
+		b.WriteString(" " + k + `="` + escapeXML(e.attrs[k]) + `"`)
+	}
+	b.WriteString(">")
+	if e.raw {
+		b.WriteString(e.value)
+	} else {
+		b.WriteString(escapeXML(e.value))
+	}
+	b.WriteString("</" + e.name + ">")
+	return b.String()
+}
+
+// escapeXML escapes text for element content and attribute values
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// findSection returns the byte range of the inner content of the first <local> element
+// The element may carry any namespace prefix
+func findSection(doc, local string) (start, end int, err error) {
+	open := regexp.MustCompile(`<(?:[\w.-]+:)?` + regexp.QuoteMeta(local) + `(?:\s[^>]*)?>`)
+	loc := open.FindStringIndex(doc)
+	if loc == nil {
+		return 0, 0, fmt.Errorf("<%s> element not found", local)
+	}
+
+	closeTag := regexp.MustCompile(`</(?:[\w.-]+:)?` + regexp.QuoteMeta(local) + `\s*>`)
+	closeLoc := closeTag.FindStringIndex(doc[loc[1]:])
+	if closeLoc == nil {
+		return 0, 0, fmt.Errorf("</%s> closing tag not found", local)
+	}
+
+	return loc[1], loc[1] + closeLoc[0], nil
+}
+
+// editSection applies edit to the inner content of the first <local> element
+func editSection(doc, local string, edit func(inner string) string) (string, error) {
+	start, end, err := findSection(doc, local)
+	if err != nil {
+		return "", err
+	}
+	return doc[:start] + edit(doc[start:end]) + doc[end:], nil
+}
+
+// elementPattern matches a (possibly prefixed) element with content or self-closed
+// match filters elements further by their opening tag (nil matches all)
+func elementPattern(local string) *regexp.Regexp {
+	q := regexp.QuoteMeta(local)
+	return regexp.MustCompile(`(?s)[ \t]*<(?:[\w.-]+:)?` + q + `(?:\s[^>]*?)?(?:/>|>.*?</(?:[\w.-]+:)?` + q + `\s*>)[ \t]*\r?\n?`)
+}
+
+// replaceElements removes every <local> element accepted by match and inserts
+// the given elements where the first one was; when none existed they go after the
+// last element named in after (first name present wins) or at the end of the section
+func replaceElements(inner, local string, match func(element string) bool, elements []xmlElement, after ...string) string {
+	pattern := elementPattern(local)
+
+	insertAt := -1
+	var out strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(inner, -1) {
+		element := inner[loc[0]:loc[1]]
+		if match != nil && !match(element) {
+			continue
+		}
+		out.WriteString(inner[last:loc[0]])
+		if insertAt < 0 {
+			insertAt = out.Len()
+		}
+		last = loc[1]
+	}
+	out.WriteString(inner[last:])
+	result := out.String()
+
+	if len(elements) == 0 {
+		return result
+	}
+
+	indent := detectIndent(inner)
+	var insert strings.Builder
+	for _, e := range elements {
+		insert.WriteString(indent + e.render() + "\n")
+	}
+
+	if insertAt < 0 {
+		for _, name := range after {
+			locs := elementPattern(name).FindAllStringIndex(result, -1)
+			if len(locs) == 0 {
+				continue
+			}
+			insertAt = locs[len(locs)-1][1]
+			if insertAt > 0 && result[insertAt-1] != '\n' {
+				// Sibling shares its line with the closing tag; start a new line
+				return result[:insertAt] + "\n" + insert.String() + result[insertAt:]
+			}
+			break
+		}
+	}
+
+	if insertAt < 0 {
+		// Append before trailing whitespace (the closing tag's indentation)
+		trimmed := strings.TrimRight(result, " \t\r\n")
+		tail := result[len(trimmed):]
+		if !strings.HasSuffix(trimmed, "\n") && trimmed != "" {
+			trimmed += "\n"
+		}
+		return trimmed + insert.String() + strings.TrimLeft(tail, "\r\n")
+	}
+	return result[:insertAt] + insert.String() + result[insertAt:]
+}
+
+// detectIndent returns the indentation of the first child element in a section
+func detectIndent(inner string) string {
+	for _, line := range strings.Split(inner, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "<") {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// hasAttr reports whether an element's opening tag contains attr="value"
+func hasAttr(element, attr, value string) bool {
+	pattern := regexp.MustCompile(`\s(?:[\w.-]+:)?` + regexp.QuoteMeta(attr) + `\s*=\s*["']` + regexp.QuoteMeta(value) + `["']`)
+	end := strings.IndexByte(element, '>')
+	if end < 0 {
+		end = len(element)
+	}
+	return pattern.MatchString(element[:end])
+}
+
+// namespacePrefix returns the prefix bound to a namespace URI in doc, or fallback
+func namespacePrefix(doc, uri, fallback string) string {
+	pattern := regexp.MustCompile(`xmlns:([\w.-]+)\s*=\s*["']` + regexp.QuoteMeta(uri) + `["']`)
+	if m := pattern.FindStringSubmatch(doc); m != nil {
+		return m[1]
+	}
+	return fallback
+}
@@ -0,0 +1,257 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/cache"
+	"github.com/valpere/yakateka/internal/detect"
+	"github.com/valpere/yakateka/internal/preprocess"
+)
+
+// NewTesseractEngine creates a Tesseract engine using the OCR configuration
+// Binaries are resolved from PATH
+func NewTesseractEngine(cfg internal.OCRConfig) *TesseractEngine {
+	dpi := cfg.DPI
+	if dpi <= 0 {
+		dpi = defaultDPI
+	}
+	engine := &TesseractEngine{
+		tesseractPath: defaultTesseractPath,
+		pdftoppmPath:  defaultPdftoppmPath,
+		ddjvuPath:     defaultDdjvuPath,
+		languages:     cfg.Languages,
+		dpi:           dpi,
+	}
+
+	preprocessor, err := preprocess.NewPreprocessor(cfg.Preprocess)
+	if err != nil {
+		log.Warn().Err(err).Msg("Image preprocessing disabled")
+	} else if preprocessor.Enabled() {
+		engine.preprocessor = preprocessor
+	}
+	return engine
+}
+
+// Fingerprint identifies the OCR tools and page cleanup settings
+// Implements internal.Fingerprinter
+func (e *TesseractEngine) Fingerprint(from, to internal.DocumentFormat, mode string) string {
+	return fmt.Sprintf("tesseract|%s|%s|%s|%d|%+v",
+		cache.Fingerprint(e.tesseractPath), cache.Fingerprint(e.pdftoppmPath), cache.Fingerprint(e.ddjvuPath),
+		e.dpi, e.preprocessor)
+}
+
+// ExtractText rasterises the input if needed and returns the recognised text
+// Pages are separated by blank lines
+func (e *TesseractEngine) ExtractText(ctx context.Context, input string, opts internal.ExtractionOptions) (string, error) {
+	if _, err := os.Stat(input); err != nil {
+		return "", fmt.Errorf("%w: %s", internal.ErrInvalidInput, input)
+	}
+
+	format := inputFormat(input)
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = e.dpi
+	}
+	languages := opts.OCRLanguages
+	if len(languages) == 0 {
+		languages = e.languages
+	}
+
+	tmpDir, err := os.MkdirTemp("", "yakateka-ocr-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR work directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var pages []string
+	switch format {
+	case internal.FormatPDF:
+		pages, err = e.rasterisePDF(ctx, input, tmpDir, dpi)
+	case internal.FormatDJVU:
+		pages, err = e.rasteriseDJVU(ctx, input, tmpDir, dpi)
+	case internal.FormatPNG, internal.FormatJPG, internal.FormatJPEG, internal.FormatTIFF, internal.FormatBMP:
+		pages = []string{input}
+	default:
+		return "", fmt.Errorf("%w: OCR does not support %q input", internal.ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(pages) == 0 {
+		return "", fmt.Errorf("%w: no pages rendered from %s", internal.ErrConversionFailed, input)
+	}
+
+	log.Info().
+		Str("input", input).
+		Str("format", string(format)).
+		Int("pages", len(pages)).
+		Int("dpi", dpi).
+		Strs("languages", languages).
+		Msg("Running OCR")
+
+	texts := make([]string, 0, len(pages))
+	for i, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		text, err := e.recognise(ctx, e.preprocessPage(page, tmpDir, i), dpi, languages)
+		if err != nil {
+			return "", fmt.Errorf("OCR failed on page %d: %w", i+1, err)
+		}
+		texts = append(texts, strings.TrimSpace(text))
+		log.Debug().Int("page", i+1).Int("chars", len(text)).Msg("OCR page done")
+	}
+
+	return strings.Join(texts, "\n\n") + "\n", nil
+}
+
+// SupportedLanguages returns the languages installed for Tesseract
+// Falls back to the configured languages when tesseract cannot be queried
+func (e *TesseractEngine) SupportedLanguages() []string {
+	cmd := exec.Command(e.tesseractPath, "--list-langs")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to list Tesseract languages")
+		return e.languages
+	}
+
+	// First line is a header: List of available languages in "/usr/share/tessdata/" (3):
+	var langs []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "List of") {
+			continue
+		}
+		langs = append(langs, line)
+	}
+	return langs
+}
+
+// CheckAvailability checks if tesseract is available on the system
+func (e *TesseractEngine) CheckAvailability() error {
+	if _, err := exec.LookPath(e.tesseractPath); err != nil {
+		return fmt.Errorf("tesseract not available: %w", err)
+	}
+	return nil
+}
+
+// preprocessPage cleans a page image for recognition and returns the image to OCR
+// The original page is used when preprocessing is off or fails
+func (e *TesseractEngine) preprocessPage(page, dir string, index int) string {
+	if e.preprocessor == nil {
+		return page
+	}
+	cleaned := filepath.Join(dir, fmt.Sprintf("clean-%04d.png", index+1))
+	report, err := e.preprocessor.ProcessFile(page, cleaned)
+	if err != nil {
+		log.Warn().Err(err).Str("page", page).Msg("Preprocessing failed, using original page")
+		return page
+	}
+	log.Debug().
+		Int("page", index+1).
+		Strs("steps", report.Steps).
+		Float64("skew", report.SkewAngle).
+		Msg("Page preprocessed")
+	return cleaned
+}
+
+// recognise runs tesseract on one page image and returns its text
+func (e *TesseractEngine) recognise(ctx context.Context, image string, dpi int, languages []string) (string, error) {
+	// Usage: tesseract <image> stdout [-l lang+lang] [--dpi N]
+	args := []string{image, "stdout", "--dpi", strconv.Itoa(dpi)}
+	if lang := tesseractLanguageArg(languages); lang != "" {
+		args = append(args, "-l", lang)
+	}
+
+	cmd := exec.CommandContext(ctx, e.tesseractPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: tesseract failed: %v - %s",
+			internal.ErrConversionFailed, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// rasterisePDF renders every PDF page to PNG with pdftoppm
+func (e *TesseractEngine) rasterisePDF(ctx context.Context, input, dir string, dpi int) ([]string, error) {
+	// Usage: pdftoppm -r <dpi> -png <input> <prefix> → <prefix>-1.png, <prefix>-2.png, ...
+	prefix := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, e.pdftoppmPath, "-r", strconv.Itoa(dpi), "-png", input, prefix)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: pdftoppm failed: %v - %s",
+			internal.ErrConversionFailed, err, strings.TrimSpace(string(output)))
+	}
+	return pageFiles(dir, "page-*.png")
+}
+
+// rasteriseDJVU renders every DjVu page to TIFF with ddjvu
+func (e *TesseractEngine) rasteriseDJVU(ctx context.Context, input, dir string, dpi int) ([]string, error) {
+	// Usage: ddjvu -format=tiff -eachpage -scale=<dpi> <input> <pattern with %d>
+	pattern := filepath.Join(dir, "page-%04d.tif")
+	cmd := exec.CommandContext(ctx, e.ddjvuPath, "-format=tiff", "-eachpage", "-scale="+strconv.Itoa(dpi), input, pattern)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: ddjvu failed: %v - %s",
+			internal.ErrConversionFailed, err, strings.TrimSpace(string(output)))
+	}
+	return pageFiles(dir, "page-*.tif")
+}
+
+// pageFiles returns rendered page images in page order
+// Renderers zero-pad page numbers to a common width, so a numeric sort is only
+// needed as a safeguard against unpadded names
+func pageFiles(dir, glob string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, glob))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return pageNumber(files[i]) < pageNumber(files[j])
+	})
+	return files, nil
+}
+
+// pageNumber extracts the trailing page number from a rendered file name
+func pageNumber(path string) int {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	idx := strings.LastIndexByte(name, '-')
+	n, _ := strconv.Atoi(name[idx+1:])
+	return n
+}
+
+// inputFormat identifies the input from its content, falling back to the extension
+func inputFormat(input string) internal.DocumentFormat {
+	if result, err := detect.Detect(input); err == nil && result.Confidence >= detect.ConfidenceMedium {
+		return result.Format
+	}
+	return detect.FromExtension(input)
+}
+
+// tesseractLanguageArg joins languages as Tesseract expects (eng+ukr)
+func tesseractLanguageArg(languages []string) string {
+	codes := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		lang = strings.TrimSpace(strings.ToLower(lang))
+		if lang == "" {
+			continue
+		}
+		if code, ok := tesseractLanguages[lang]; ok {
+			lang = code
+		}
+		codes = append(codes, lang)
+	}
+	return strings.Join(codes, "+")
+}
@@ -0,0 +1,122 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// installFakeTools puts fake tesseract and pdftoppm scripts first on PATH
+// tesseract echoes its image name and arguments; pdftoppm renders three pages
+func installFakeTools(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	scripts := map[string]string{
+		"tesseract": `#!/bin/sh
+if [ "$1" = "--list-langs" ]; then
+  echo 'List of available languages in "/usr/share/tessdata/" (2):'
+  echo eng
+  echo ukr
+  exit 0
+fi
+echo "text of $(basename "$1")"
+shift 2
+echo "args: $*"
+`,
+		"pdftoppm": `#!/bin/sh
+# pdftoppm -r DPI -png INPUT PREFIX
+for n in 01 02 10; do : > "$5-$n.png"; done
+`,
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0755); err != nil {
+			t.Fatalf("Failed to write fake %s: %v", name, err)
+		}
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestExtractTextImage(t *testing.T) {
+	installFakeTools(t)
+	image := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(image, []byte("\x89PNG\r\n\x1a\nfake"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	engine := NewTesseractEngine(internal.OCRConfig{Languages: []string{"uk", "en"}, DPI: 200})
+	text, err := engine.ExtractText(context.Background(), image, internal.ExtractionOptions{})
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+
+	if !strings.Contains(text, "text of scan.png") {
+		t.Errorf("Expected recognised text, got %q", text)
+	}
+	if !strings.Contains(text, "--dpi 200 -l ukr+eng") {
+		t.Errorf("Expected config DPI and mapped languages, got %q", text)
+	}
+}
+
+func TestExtractTextPDFPerPage(t *testing.T) {
+	installFakeTools(t)
+	pdf := filepath.Join(t.TempDir(), "scan.pdf")
+	if err := os.WriteFile(pdf, []byte("%PDF-1.4\n%%EOF\n"), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	engine := NewTesseractEngine(internal.OCRConfig{})
+	text, err := engine.ExtractText(context.Background(), pdf, internal.ExtractionOptions{
+		OCRLanguages: []string{"ru"},
+		DPI:          150,
+	})
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+
+	first := strings.Index(text, "text of page-01.png")
+	second := strings.Index(text, "text of page-02.png")
+	third := strings.Index(text, "text of page-10.png")
+	if first < 0 || second < first || third < second {
+		t.Errorf("Expected three pages in order, got %q", text)
+	}
+	if !strings.Contains(text, "--dpi 150 -l rus") {
+		t.Errorf("Expected option overrides to be used, got %q", text)
+	}
+}
+
+func TestExtractTextUnsupported(t *testing.T) {
+	installFakeTools(t)
+	input := filepath.Join(t.TempDir(), "book.epub")
+	if err := os.WriteFile(input, []byte("not really"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	engine := NewTesseractEngine(internal.OCRConfig{})
+	_, err := engine.ExtractText(context.Background(), input, internal.ExtractionOptions{})
+	if !errors.Is(err, internal.ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestSupportedLanguages(t *testing.T) {
+	installFakeTools(t)
+
+	langs := NewTesseractEngine(internal.OCRConfig{}).SupportedLanguages()
+	if strings.Join(langs, ",") != "eng,ukr" {
+		t.Errorf("Expected eng,ukr, got %v", langs)
+	}
+}
+
+func TestTesseractLanguageArg(t *testing.T) {
+	if got := tesseractLanguageArg([]string{"uk", " EN ", "chi_tra", ""}); got != "ukr+eng+chi_tra" {
+		t.Errorf("Unexpected language argument %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package ocr
+
+import (
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/preprocess"
+)
+
+// Default binaries, resolved from PATH
+const (
+	defaultTesseractPath = "tesseract"
+	defaultPdftoppmPath  = "pdftoppm"
+	defaultDdjvuPath     = "ddjvu"
+	defaultDPI           = 300
+)
+
+// tesseractLanguages maps ISO 639-1 codes used in config to Tesseract traineddata names
+// Codes not listed are passed to Tesseract unchanged
+var tesseractLanguages = map[string]string{
+	"be": "bel",
+	"cs": "ces",
+	"de": "deu",
+	"en": "eng",
+	"es": "spa",
+	"fr": "fra",
+	"it": "ita",
+	"ja": "jpn",
+	"pl": "pol",
+	"pt": "por",
+	"ru": "rus",
+	"uk": "ukr",
+	"zh": "chi_sim",
+}
+
+// TesseractEngine performs OCR with the tesseract CLI
+// PDF and DjVu documents are rasterised page by page (pdftoppm, ddjvu) first
+type TesseractEngine struct {
+	tesseractPath string   // Path to tesseract binary
+	pdftoppmPath  string   // Path to pdftoppm binary (poppler-utils)
+	ddjvuPath     string   // Path to ddjvu binary (DjVuLibre)
+	languages     []string // Default languages (ISO 639-1 or Tesseract codes)
+	dpi           int      // Default rasterisation resolution
+
+	preprocessor *preprocess.Preprocessor // Page cleanup before recognition (nil if disabled)
+}
+
+// SupportedInputFormats returns formats the engine can OCR
+func (e *TesseractEngine) SupportedInputFormats() []internal.DocumentFormat {
+	return []internal.DocumentFormat{
+		internal.FormatPDF,
+		internal.FormatDJVU,
+		internal.FormatPNG,
+		internal.FormatJPG,
+		internal.FormatJPEG,
+		internal.FormatTIFF,
+		internal.FormatBMP,
+	}
+}
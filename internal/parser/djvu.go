@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+)
+
+// parseDJVU reads document metadata and page count with djvused
+// Without djvused only the page count is available (from the IFF structure)
+func parseDJVU(ctx context.Context, input, djvusedPath string) (*internal.DocumentMetadata, error) {
+	meta := &internal.DocumentMetadata{}
+
+	if _, err := exec.LookPath(djvusedPath); err != nil {
+		log.Debug().Err(err).Msg("djvused not available, reading DjVu page count only")
+		pages, err := djvuPageCount(input)
+		if err != nil {
+			return nil, err
+		}
+		meta.PageCount = pages
+		return meta, nil
+	}
+
+	// Usage: djvused <djvufile> -e 'n; print-meta'
+	cmd := exec.CommandContext(ctx, djvusedPath, input, "-e", "n; print-meta")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("djvused failed: %w - %s", err, stderr.String())
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			// First line is the page count from "n"
+			first = false
+			if pages, err := strconv.Atoi(line); err == nil {
+				meta.PageCount = pages
+				continue
+			}
+		}
+		key, value, ok := parseDjvuMetaLine(line)
+		if !ok {
+			continue
+		}
+		applyDjvuMeta(meta, key, value)
+	}
+
+	return meta, nil
+}
+
+// parseDjvuMetaLine splits a print-meta line: key "escaped value"
+func parseDjvuMetaLine(line string) (string, string, bool) {
+	idx := strings.IndexAny(line, " \t")
+	if idx < 0 {
+		return "", "", false
+	}
+	key := line[:idx]
+	raw := strings.TrimSpace(line[idx:])
+	value, err := strconv.Unquote(raw)
+	if err != nil {
+		value = strings.Trim(raw, `"`)
+	}
+	return key, value, true
+}
+
+// applyDjvuMeta maps DjVu metadata keys (BibTeX and PDF-style) to DocumentMetadata
+func applyDjvuMeta(meta *internal.DocumentMetadata, key, value string) {
+	switch strings.ToLower(key) {
+	case "title", "booktitle":
+		if meta.Title == "" {
+			meta.Title = collapseSpace(value)
+		}
+	case "author":
+		meta.Author = collapseSpace(value)
+	case "subject", "note", "annote":
+		if meta.Description == "" {
+			meta.Description = collapseSpace(value)
+		}
+	case "keywords":
+		meta.Tags = splitTags(value)
+	case "language", "lang":
+		meta.Language = strings.TrimSpace(value)
+	case "creationdate":
+		meta.Created = parsePDFDate(value)
+	case "moddate":
+		meta.Modified = parsePDFDate(value)
+	default:
+		setCustom(meta, strings.ToLower(key), value)
+	}
+}
+
+// djvuPageCount counts pages in a single-page or bundled DjVu document
+// Indirect (multi-file) documents are reported as one page per FORM:DJVU found
+func djvuPageCount(input string) (int, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read DjVu: %w", err)
+	}
+	if len(data) < 16 || string(data[0:8]) != "AT&TFORM" {
+		return 0, fmt.Errorf("%w: missing AT&TFORM header", internal.ErrInvalidInput)
+	}
+
+	switch string(data[12:16]) {
+	case "DJVU":
+		return 1, nil
+	case "DJVM":
+		// Walk the top-level chunks of the bundled document
+		pages := 0
+		pos := 16
+		for pos+8 <= len(data) {
+			id := string(data[pos : pos+4])
+			size := int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+			if id == "FORM" && pos+12 <= len(data) && string(data[pos+8:pos+12]) == "DJVU" {
+				pages++
+			}
+			pos += 8 + size
+			if size%2 == 1 {
+				pos++ // IFF chunks are padded to even length
+			}
+		}
+		return pages, nil
+	default:
+		return 0, nil
+	}
+}
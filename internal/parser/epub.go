@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// epubContainer is META-INF/container.xml
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// opfValue is a Dublin Core element with optional refinement attributes
+type opfValue struct {
+	Value string `xml:",chardata"`
+	Event string `xml:"event,attr"` // OPF 2 dc:date opf:event
+	Role  string `xml:"role,attr"`  // OPF 2 dc:creator opf:role
+	ID    string `xml:"id,attr"`
+}
+
+// opfMeta is an OPF <meta> element (OPF 2 name/content or OPF 3 property)
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// opfPackage is the subset of the OPF package document we read
+type opfPackage struct {
+	Metadata struct {
+		Titles       []opfValue `xml:"title"`
+		Creators     []opfValue `xml:"creator"`
+		Languages    []opfValue `xml:"language"`
+		Subjects     []opfValue `xml:"subject"`
+		Descriptions []opfValue `xml:"description"`
+		Dates        []opfValue `xml:"date"`
+		Publishers   []opfValue `xml:"publisher"`
+		Identifiers  []opfValue `xml:"identifier"`
+		Metas        []opfMeta  `xml:"meta"`
+	} `xml:"metadata"`
+	Spine struct {
+		Items []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// parseEPUB reads metadata from the OPF package document
+func parseEPUB(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer r.Close()
+
+	opfPath, err := FindOPF(&r.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readZipFile(&r.Reader, opfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg opfPackage
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse OPF %s: %w", opfPath, err)
+	}
+
+	return opfToMetadata(&pkg), nil
+}
+
+// FindOPF locates the OPF package document via META-INF/container.xml
+func FindOPF(r *zip.Reader) (string, error) {
+	data, err := readZipFile(r, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("failed to parse container.xml: %w", err)
+	}
+
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			return path.Clean(rf.FullPath), nil
+		}
+	}
+	return "", fmt.Errorf("container.xml has no OPF rootfile")
+}
+
+// opfToMetadata maps OPF metadata to DocumentMetadata
+func opfToMetadata(pkg *opfPackage) *internal.DocumentMetadata {
+	md := pkg.Metadata
+	meta := &internal.DocumentMetadata{}
+
+	if len(md.Titles) > 0 {
+		meta.Title = collapseSpace(md.Titles[0].Value)
+	}
+
+	// OPF 3 marks roles with <meta refines="#id" property="role">
+	roles := make(map[string]string)
+	for _, m := range md.Metas {
+		if m.Property == "role" && m.Refines != "" {
+			roles[strings.TrimPrefix(m.Refines, "#")] = strings.TrimSpace(m.Value)
+		}
+	}
+
+	var authors []string
+	for _, c := range md.Creators {
+		role := c.Role
+		if role == "" {
+			role = roles[c.ID]
+		}
+		if role != "" && role != "aut" {
+			continue
+		}
+		if name := collapseSpace(c.Value); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	meta.Author = strings.Join(authors, ", ")
+
+	if len(md.Languages) > 0 {
+		meta.Language = strings.TrimSpace(md.Languages[0].Value)
+	}
+
+	for _, s := range md.Subjects {
+		meta.Tags = append(meta.Tags, splitTags(s.Value)...)
+	}
+
+	if len(md.Descriptions) > 0 {
+		meta.Description = collapseSpace(md.Descriptions[0].Value)
+	}
+
+	for _, d := range md.Dates {
+		switch strings.ToLower(d.Event) {
+		case "modification":
+			meta.Modified = parseDate(d.Value)
+		default:
+			if meta.Created.IsZero() {
+				meta.Created = parseDate(d.Value)
+			}
+		}
+	}
+
+	for _, m := range md.Metas {
+		switch {
+		case m.Property == "dcterms:modified":
+			meta.Modified = parseDate(m.Value)
+		case m.Name != "" && m.Content != "":
+			// OPF 2 extension metadata, e.g. calibre:series
+			setCustom(meta, m.Name, m.Content)
+		}
+	}
+
+	if len(md.Publishers) > 0 {
+		setCustom(meta, "publisher", md.Publishers[0].Value)
+	}
+	if len(md.Identifiers) > 0 {
+		setCustom(meta, "identifier", md.Identifiers[0].Value)
+	}
+
+	return meta
+}
@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// xmlTagPattern strips markup from FB2 annotations
+var xmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// fb2Author is an FB2 <author> element
+type fb2Author struct {
+	FirstName  string `xml:"first-name"`
+	MiddleName string `xml:"middle-name"`
+	LastName   string `xml:"last-name"`
+	Nickname   string `xml:"nickname"`
+}
+
+// fb2Date is an FB2 <date> element with optional machine-readable value
+type fb2Date struct {
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+// fb2Description is the FB2 <description> element
+type fb2Description struct {
+	TitleInfo struct {
+		Genres     []string    `xml:"genre"`
+		Authors    []fb2Author `xml:"author"`
+		BookTitle  string      `xml:"book-title"`
+		Annotation struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"annotation"`
+		Keywords string  `xml:"keywords"`
+		Date     fb2Date `xml:"date"`
+		Lang     string  `xml:"lang"`
+		Sequence struct {
+			Name   string `xml:"name,attr"`
+			Number string `xml:"number,attr"`
+		} `xml:"sequence"`
+	} `xml:"title-info"`
+	DocumentInfo struct {
+		Date    fb2Date `xml:"date"`
+		ID      string  `xml:"id"`
+		Version string  `xml:"version"`
+	} `xml:"document-info"`
+	PublishInfo struct {
+		Publisher string `xml:"publisher"`
+		Year      string `xml:"year"`
+		ISBN      string `xml:"isbn"`
+	} `xml:"publish-info"`
+	CustomInfo []struct {
+		Type  string `xml:"info-type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"custom-info"`
+}
+
+// parseFB2 reads <description><title-info> without decoding the (large) body and binaries
+func parseFB2(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FB2: %w", err)
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	decoder.CharsetReader = charsetReader
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("FB2 has no <description> element")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FB2: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "description" {
+			continue
+		}
+
+		var desc fb2Description
+		if err := decoder.DecodeElement(&desc, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse FB2 description: %w", err)
+		}
+		return fb2ToMetadata(&desc), nil
+	}
+}
+
+// fb2ToMetadata maps an FB2 description to DocumentMetadata
+func fb2ToMetadata(desc *fb2Description) *internal.DocumentMetadata {
+	ti := desc.TitleInfo
+	meta := &internal.DocumentMetadata{
+		Title:    collapseSpace(ti.BookTitle),
+		Language: strings.TrimSpace(ti.Lang),
+	}
+
+	var authors []string
+	for _, a := range ti.Authors {
+		if name := fb2AuthorName(a); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	meta.Author = strings.Join(authors, ", ")
+
+	// Genres become the category and tags; keywords are added as tags
+	for _, g := range ti.Genres {
+		if g = strings.TrimSpace(g); g != "" {
+			meta.Tags = append(meta.Tags, g)
+		}
+	}
+	if len(meta.Tags) > 0 {
+		meta.Category = meta.Tags[0]
+	}
+	meta.Tags = append(meta.Tags, splitTags(ti.Keywords)...)
+
+	annotation := xmlTagPattern.ReplaceAllString(ti.Annotation.Inner, " ")
+	meta.Description = collapseSpace(html.UnescapeString(annotation))
+
+	meta.Created = parseDate(fb2DateValue(ti.Date))
+	meta.Modified = parseDate(fb2DateValue(desc.DocumentInfo.Date))
+
+	if ti.Sequence.Name != "" {
+		setCustom(meta, "series", ti.Sequence.Name)
+		setCustom(meta, "series_index", ti.Sequence.Number)
+	}
+	setCustom(meta, "publisher", desc.PublishInfo.Publisher)
+	setCustom(meta, "year", desc.PublishInfo.Year)
+	setCustom(meta, "isbn", desc.PublishInfo.ISBN)
+	setCustom(meta, "document_id", desc.DocumentInfo.ID)
+	for _, ci := range desc.CustomInfo {
+		if ci.Type != "" {
+			setCustom(meta, ci.Type, ci.Value)
+		}
+	}
+
+	return meta
+}
+
+// fb2AuthorName joins the name parts of an FB2 author
+func fb2AuthorName(a fb2Author) string {
+	name := collapseSpace(strings.Join([]string{a.FirstName, a.MiddleName, a.LastName}, " "))
+	if name == "" {
+		name = collapseSpace(a.Nickname)
+	}
+	return name
+}
+
+// fb2DateValue prefers the value attribute over free-form text
+func fb2DateValue(d fb2Date) string {
+	if d.Value != "" {
+		return d.Value
+	}
+	return d.Text
+}
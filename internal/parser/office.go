@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// ooxmlCore is docProps/core.xml (OPC core properties)
+type ooxmlCore struct {
+	Title       string `xml:"title"`
+	Subject     string `xml:"subject"`
+	Creator     string `xml:"creator"`
+	Keywords    string `xml:"keywords"`
+	Description string `xml:"description"`
+	Language    string `xml:"language"`
+	Category    string `xml:"category"`
+	Created     string `xml:"created"`
+	Modified    string `xml:"modified"`
+}
+
+// ooxmlApp is docProps/app.xml (extended properties)
+type ooxmlApp struct {
+	Pages       int    `xml:"Pages"`
+	Application string `xml:"Application"`
+}
+
+// odfMeta is the <office:meta> element of meta.xml
+type odfMeta struct {
+	Meta struct {
+		Title          string   `xml:"title"`
+		Subject        string   `xml:"subject"`
+		Description    string   `xml:"description"`
+		Language       string   `xml:"language"`
+		Creator        string   `xml:"creator"`
+		InitialCreator string   `xml:"initial-creator"`
+		Keywords       []string `xml:"keyword"`
+		CreationDate   string   `xml:"creation-date"`
+		Date           string   `xml:"date"`
+		Generator      string   `xml:"generator"`
+		Statistic      struct {
+			PageCount string `xml:"page-count,attr"`
+		} `xml:"document-statistic"`
+		UserDefined []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"user-defined"`
+	} `xml:"meta"`
+}
+
+// parseDOCX reads OOXML core and extended properties
+func parseDOCX(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	defer r.Close()
+
+	meta := &internal.DocumentMetadata{}
+
+	// Core properties are optional in OOXML; a document without them has no metadata
+	if data, err := readZipFile(&r.Reader, "docProps/core.xml"); err == nil {
+		var core ooxmlCore
+		if err := xml.Unmarshal(data, &core); err != nil {
+			return nil, fmt.Errorf("failed to parse core.xml: %w", err)
+		}
+		meta.Title = collapseSpace(core.Title)
+		meta.Author = collapseSpace(core.Creator)
+		meta.Language = strings.TrimSpace(core.Language)
+		meta.Category = strings.TrimSpace(core.Category)
+		meta.Tags = splitTags(core.Keywords)
+		meta.Description = collapseSpace(core.Description)
+		meta.Created = parseDate(core.Created)
+		meta.Modified = parseDate(core.Modified)
+		setCustom(meta, "subject", core.Subject)
+	}
+
+	if data, err := readZipFile(&r.Reader, "docProps/app.xml"); err == nil {
+		var app ooxmlApp
+		if err := xml.Unmarshal(data, &app); err == nil {
+			meta.PageCount = app.Pages
+			setCustom(meta, "application", app.Application)
+		}
+	}
+
+	return meta, nil
+}
+
+// parseODT reads OpenDocument meta.xml
+func parseODT(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ODT: %w", err)
+	}
+	defer r.Close()
+
+	data, err := readZipFile(&r.Reader, "meta.xml")
+	if err != nil {
+		// meta.xml is optional in ODF
+		return &internal.DocumentMetadata{}, nil
+	}
+
+	var doc odfMeta
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse meta.xml: %w", err)
+	}
+
+	m := doc.Meta
+	meta := &internal.DocumentMetadata{
+		Title:       collapseSpace(m.Title),
+		Author:      collapseSpace(m.Creator),
+		Language:    strings.TrimSpace(m.Language),
+		Description: collapseSpace(m.Description),
+		Created:     parseDate(m.CreationDate),
+		Modified:    parseDate(m.Date),
+	}
+	if meta.Author == "" {
+		meta.Author = collapseSpace(m.InitialCreator)
+	}
+	for _, k := range m.Keywords {
+		meta.Tags = append(meta.Tags, splitTags(k)...)
+	}
+	if pages, err := strconv.Atoi(m.Statistic.PageCount); err == nil {
+		meta.PageCount = pages
+	}
+
+	setCustom(meta, "subject", m.Subject)
+	setCustom(meta, "generator", m.Generator)
+	for _, u := range m.UserDefined {
+		setCustom(meta, u.Name, u.Value)
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/valpere/yakateka/internal"
+	"github.com/valpere/yakateka/internal/detect"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// NewParser creates a metadata parser
+// checksumAlgo selects the checksum algorithm (sha256, md5); empty disables checksums
+func NewParser(checksumAlgo string) *Parser {
+	p := &Parser{
+		parsers:  make(map[internal.DocumentFormat]formatParser),
+		checksum: strings.ToLower(checksumAlgo),
+	}
+
+	p.parsers[internal.FormatEPUB] = parseEPUB
+	p.parsers[internal.FormatFB2] = parseFB2
+	p.parsers[internal.FormatDOCX] = parseDOCX
+	p.parsers[internal.FormatODT] = parseODT
+	p.parsers[internal.FormatPDF] = parsePDF
+	p.parsers[internal.FormatDJVU] = func(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+		return parseDJVU(ctx, input, defaultDjvusedPath)
+	}
+
+	return p
+}
+
+// SupportedFormats returns formats this parser can handle
+func (p *Parser) SupportedFormats() []internal.DocumentFormat {
+	formats := make([]internal.DocumentFormat, 0, len(p.parsers))
+	for format := range p.parsers {
+		formats = append(formats, format)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// Parse extracts metadata from a document, detecting its format from content
+func (p *Parser) Parse(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	format := detect.FromExtension(input)
+	if result, err := detect.Detect(input); err == nil && result.Confidence >= detect.ConfidenceMedium {
+		format = result.Format
+	}
+	return p.ParseFormat(ctx, input, format)
+}
+
+// ParseFormat extracts metadata from a document of a known format
+func (p *Parser) ParseFormat(ctx context.Context, input string, format internal.DocumentFormat) (*internal.DocumentMetadata, error) {
+	if _, err := os.Stat(input); err != nil {
+		log.Error().Str("input", input).Msg("Input file does not exist")
+		return nil, fmt.Errorf("%w: %s", internal.ErrInvalidInput, input)
+	}
+
+	parse, ok := p.parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: no metadata parser for %s", internal.ErrUnsupportedFormat, format)
+	}
+
+	meta, err := parse(ctx, input)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("input", input).
+			Str("format", string(format)).
+			Msg("Metadata parsing failed")
+		return nil, fmt.Errorf("failed to parse %s metadata: %w", format, err)
+	}
+
+	if p.checksum != "" {
+		sum, err := Checksum(input, p.checksum)
+		if err != nil {
+			return nil, err
+		}
+		meta.Checksum = sum
+	}
+
+	log.Debug().
+		Str("input", input).
+		Str("format", string(format)).
+		Str("title", meta.Title).
+		Msg("Parsed document metadata")
+
+	return meta, nil
+}
+
+// Checksum computes a file checksum formatted as "<algo>:<hex>"
+func Checksum(path, algo string) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algo) {
+	case ChecksumSHA256:
+		h = sha256.New()
+	case ChecksumMD5:
+		h = md5.New()
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for checksum: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to compute checksum: %w", err)
+	}
+
+	return strings.ToLower(algo) + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readZipFile reads a named entry from an open ZIP archive
+func readZipFile(r *zip.Reader, name string) ([]byte, error) {
+	for _, file := range r.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// charsetReader decodes non-UTF-8 XML (e.g. windows-1251 FB2 files)
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %s: %w", charset, err)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// parseDate parses the date layouts found in document metadata
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	layouts := []string{
+		time.RFC3339Nano,
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// splitTags splits keyword lists separated by commas or semicolons
+func splitTags(value string) []string {
+	var tags []string
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag := strings.TrimSpace(part); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// setCustom stores a non-empty custom field
+func setCustom(meta *internal.DocumentMetadata, key, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if meta.Custom == nil {
+		meta.Custom = make(map[string]string)
+	}
+	meta.Custom[key] = value
+}
+
+// collapseSpace normalises whitespace in free-form text
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
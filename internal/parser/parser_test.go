@@ -0,0 +1,354 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valpere/yakateka/internal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// writeZip creates a ZIP file with the given name/content entries
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+}
+
+func TestParseEPUB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeZip(t, path, map[string]string{
+		"mimetype": "application/epub+zip",
+		"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Кобзар</dc:title>
+    <dc:creator id="a1">Тарас Шевченко</dc:creator>
+    <dc:creator id="e1">Some Editor</dc:creator>
+    <meta refines="#e1" property="role">edt</meta>
+    <dc:language>uk</dc:language>
+    <dc:subject>poetry</dc:subject>
+    <dc:subject>classics</dc:subject>
+    <dc:description>Collected poems</dc:description>
+    <dc:date>1840-04-18</dc:date>
+    <meta property="dcterms:modified">2020-01-02T03:04:05Z</meta>
+    <dc:publisher>Public Domain</dc:publisher>
+  </metadata>
+</package>`,
+	})
+
+	meta, err := NewParser("").Parse(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if meta.Title != "Кобзар" {
+		t.Errorf("Expected title 'Кобзар', got %q", meta.Title)
+	}
+	if meta.Author != "Тарас Шевченко" {
+		t.Errorf("Expected only the author role, got %q", meta.Author)
+	}
+	if meta.Language != "uk" {
+		t.Errorf("Expected language uk, got %q", meta.Language)
+	}
+	if len(meta.Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", meta.Tags)
+	}
+	if meta.Created.Year() != 1840 {
+		t.Errorf("Expected created year 1840, got %v", meta.Created)
+	}
+	if !meta.Modified.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected modified date %v", meta.Modified)
+	}
+	if meta.Custom["publisher"] != "Public Domain" {
+		t.Errorf("Expected publisher in custom fields, got %v", meta.Custom)
+	}
+}
+
+func TestParseFB2(t *testing.T) {
+	fb2 := `<?xml version="1.0" encoding="windows-1251"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+ <description>
+  <title-info>
+   <genre>prose_classic</genre>
+   <author><first-name>Іван</first-name><last-name>Франко</last-name></author>
+   <book-title>Захар Беркут</book-title>
+   <annotation><p>Історична <emphasis>повість</emphasis>.</p></annotation>
+   <keywords>history, carpathians</keywords>
+   <date value="1883-01-01">1883</date>
+   <lang>uk</lang>
+   <sequence name="Collected Works" number="3"/>
+  </title-info>
+  <document-info><date value="2010-05-06">6 May 2010</date></document-info>
+ </description>
+ <body><section><p>...</p></section></body>
+</FictionBook>`
+
+	encoded, err := charmap.Windows1251.NewEncoder().String(fb2)
+	if err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "book.fb2")
+	if err := os.WriteFile(path, []byte(encoded), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := NewParser("").ParseFormat(context.Background(), path, internal.FormatFB2)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if meta.Title != "Захар Беркут" {
+		t.Errorf("Expected decoded windows-1251 title, got %q", meta.Title)
+	}
+	if meta.Author != "Іван Франко" {
+		t.Errorf("Expected author 'Іван Франко', got %q", meta.Author)
+	}
+	if meta.Description != "Історична повість ." {
+		t.Errorf("Expected annotation without markup, got %q", meta.Description)
+	}
+	if meta.Category != "prose_classic" {
+		t.Errorf("Expected genre as category, got %q", meta.Category)
+	}
+	if len(meta.Tags) != 3 {
+		t.Errorf("Expected genre plus 2 keywords, got %v", meta.Tags)
+	}
+	if meta.Created.Year() != 1883 || meta.Modified.Year() != 2010 {
+		t.Errorf("Unexpected dates: created %v, modified %v", meta.Created, meta.Modified)
+	}
+	if meta.Custom["series"] != "Collected Works" || meta.Custom["series_index"] != "3" {
+		t.Errorf("Expected series in custom fields, got %v", meta.Custom)
+	}
+}
+
+func TestParseDOCX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.docx")
+	writeZip(t, path, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/document.xml":   `<w:document/>`,
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+  xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">
+  <dc:title>Quarterly Report</dc:title>
+  <dc:creator>Jane Doe</dc:creator>
+  <cp:keywords>finance; q3</cp:keywords>
+  <dc:language>en-US</dc:language>
+  <dcterms:created>2023-07-01T10:00:00Z</dcterms:created>
+  <dcterms:modified>2023-07-02T11:00:00Z</dcterms:modified>
+</cp:coreProperties>`,
+		"docProps/app.xml": `<Properties><Pages>12</Pages><Application>Microsoft Office Word</Application></Properties>`,
+	})
+
+	meta, err := NewParser("").Parse(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if meta.Title != "Quarterly Report" || meta.Author != "Jane Doe" || meta.Language != "en-US" {
+		t.Errorf("Unexpected core properties: %+v", meta)
+	}
+	if len(meta.Tags) != 2 || meta.Tags[1] != "q3" {
+		t.Errorf("Expected keywords split into tags, got %v", meta.Tags)
+	}
+	if meta.PageCount != 12 {
+		t.Errorf("Expected 12 pages, got %d", meta.PageCount)
+	}
+}
+
+func TestParseODT(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.odt")
+	writeZip(t, path, map[string]string{
+		"mimetype": "application/vnd.oasis.opendocument.text",
+		"meta.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <office:meta>
+    <dc:title>Meeting Notes</dc:title>
+    <meta:initial-creator>John Smith</meta:initial-creator>
+    <dc:language>en</dc:language>
+    <meta:keyword>meeting</meta:keyword>
+    <meta:keyword>notes</meta:keyword>
+    <meta:creation-date>2022-03-04T05:06:07</meta:creation-date>
+    <meta:document-statistic meta:page-count="3"/>
+    <meta:user-defined meta:name="Project">Yakateka</meta:user-defined>
+  </office:meta>
+</office:document-meta>`,
+	})
+
+	meta, err := NewParser("").Parse(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if meta.Title != "Meeting Notes" || meta.Author != "John Smith" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if len(meta.Tags) != 2 || meta.PageCount != 3 {
+		t.Errorf("Expected 2 tags and 3 pages, got %v / %d", meta.Tags, meta.PageCount)
+	}
+	if meta.Custom["Project"] != "Yakateka" {
+		t.Errorf("Expected user-defined field, got %v", meta.Custom)
+	}
+}
+
+func TestParsePDF(t *testing.T) {
+	pdf := `%PDF-1.4
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+4 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+5 0 obj
+<< /Title (Annual \(Draft\) Report) /Author <FEFF04260435043A> /Keywords (a, b)
+   /CreationDate (D:20210304050607+02'00') /Producer (Test) /Trapped /False >>
+endobj
+trailer
+<< /Size 6 /Root 1 0 R /Info 5 0 R >>
+%%EOF`
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, []byte(pdf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := NewParser(ChecksumSHA256).Parse(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if meta.Title != "Annual (Draft) Report" {
+		t.Errorf("Expected escaped literal title, got %q", meta.Title)
+	}
+	if meta.Author != "Цек" {
+		t.Errorf("Expected UTF-16 author, got %q", meta.Author)
+	}
+	if len(meta.Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", meta.Tags)
+	}
+	if meta.PageCount != 2 {
+		t.Errorf("Expected 2 pages, got %d", meta.PageCount)
+	}
+	if _, offset := meta.Created.Zone(); meta.Created.Year() != 2021 || offset != 2*3600 {
+		t.Errorf("Unexpected creation date %v", meta.Created)
+	}
+	if !strings.HasPrefix(meta.Checksum, "sha256:") {
+		t.Errorf("Expected sha256 checksum, got %q", meta.Checksum)
+	}
+}
+
+func TestParsePDFXMPFallback(t *testing.T) {
+	pdf := `%PDF-1.7
+1 0 obj
+<< /Type /Metadata /Subtype /XML >>
+stream
+<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description
+  xmp:CreateDate="2019-01-01T00:00:00Z">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP Title</rdf:li></rdf:Alt></dc:title>
+<dc:creator><rdf:Seq><rdf:li>First</rdf:li><rdf:li>Second</rdf:li></rdf:Seq></dc:creator>
+<dc:language><rdf:Bag><rdf:li>de</rdf:li></rdf:Bag></dc:language>
+</rdf:Description></rdf:RDF></x:xmpmeta>
+endstream
+endobj
+%%EOF`
+	path := filepath.Join(t.TempDir(), "xmp.pdf")
+	if err := os.WriteFile(path, []byte(pdf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := NewParser("").Parse(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if meta.Title != "XMP Title" || meta.Author != "First, Second" || meta.Language != "de" {
+		t.Errorf("Unexpected XMP metadata: %+v", meta)
+	}
+	if meta.Created.Year() != 2019 {
+		t.Errorf("Expected XMP create date, got %v", meta.Created)
+	}
+}
+
+func TestDjvuPageCount(t *testing.T) {
+	// Bundled document with two FORM:DJVU pages
+	page := "FORM\x00\x00\x00\x04DJVU"
+	data := "AT&TFORM\x00\x00\x00\x20DJVM" + "DIRM\x00\x00\x00\x00" + page + page
+	path := filepath.Join(t.TempDir(), "doc.djvu")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := djvuPageCount(path)
+	if err != nil {
+		t.Fatalf("djvuPageCount failed: %v", err)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages, got %d", pages)
+	}
+}
+
+func TestParseDjvuMetaLine(t *testing.T) {
+	key, value, ok := parseDjvuMetaLine(`Title	"A \"quoted\" title"`)
+	if !ok || key != "Title" || value != `A "quoted" title` {
+		t.Errorf("Unexpected parse: %q %q %v", key, value, ok)
+	}
+}
+
+func TestParseUnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewParser("").Parse(context.Background(), path); err == nil {
+		t.Error("Expected error for format without parser")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Checksum(path, ChecksumMD5)
+	if err != nil {
+		t.Fatalf("Checksum failed: %v", err)
+	}
+	if sum != "md5:900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("Unexpected md5: %s", sum)
+	}
+
+	if _, err := Checksum(path, "crc32"); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
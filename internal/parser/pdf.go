@@ -0,0 +1,395 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+var (
+	pdfInfoRefPattern  = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	pdfPagesPattern    = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfPagePattern     = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfCountPattern    = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfDateDigits      = regexp.MustCompile(`^\d+`)
+	xmpPacketPattern   = regexp.MustCompile(`(?s)<x:xmpmeta.*?</x:xmpmeta>`)
+	xmpListItemPattern = regexp.MustCompile(`(?s)<rdf:li[^>]*>(.*?)</rdf:li>`)
+)
+
+// pdfStandardInfoKeys are Info entries mapped to dedicated metadata fields
+var pdfStandardInfoKeys = map[string]bool{
+	"Title":        true,
+	"Author":       true,
+	"Subject":      true,
+	"Keywords":     true,
+	"CreationDate": true,
+	"ModDate":      true,
+	"Creator":      true,
+	"Producer":     true,
+}
+
+// parsePDF reads the document Info dictionary, falling back to XMP metadata
+// Objects inside compressed object streams are not decoded, so such files may
+// only yield XMP fields and an approximate page count
+func parsePDF(ctx context.Context, input string) (*internal.DocumentMetadata, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: missing %%PDF- header", internal.ErrInvalidInput)
+	}
+
+	meta := &internal.DocumentMetadata{}
+
+	info := pdfInfoDict(data)
+	meta.Title = collapseSpace(info["Title"])
+	meta.Author = collapseSpace(info["Author"])
+	meta.Description = collapseSpace(info["Subject"])
+	meta.Tags = splitTags(info["Keywords"])
+	meta.Created = parsePDFDate(info["CreationDate"])
+	meta.Modified = parsePDFDate(info["ModDate"])
+	setCustom(meta, "creator", info["Creator"])
+	setCustom(meta, "producer", info["Producer"])
+	for key, value := range info {
+		if !pdfStandardInfoKeys[key] {
+			setCustom(meta, key, value)
+		}
+	}
+
+	applyXMP(meta, data)
+	meta.PageCount = pdfPageCount(data)
+
+	return meta, nil
+}
+
+// pdfInfoDict locates the trailer /Info object and decodes its string entries
+func pdfInfoDict(data []byte) map[string]string {
+	refs := pdfInfoRefPattern.FindAllSubmatch(data, -1)
+	if len(refs) == 0 {
+		return map[string]string{}
+	}
+
+	// The last trailer wins (incremental updates append new trailers)
+	ref := refs[len(refs)-1]
+	objPattern := regexp.MustCompile(`(?:^|[^0-9])` + string(ref[1]) + `\s+` + string(ref[2]) + `\s+obj\s*<<`)
+	locs := objPattern.FindAllIndex(data, -1)
+	if len(locs) == 0 {
+		return map[string]string{}
+	}
+
+	start := locs[len(locs)-1][1] - 2 // Position of "<<"
+	return parsePDFDict(data[start:])
+}
+
+// parsePDFDict decodes the top-level string values of a PDF dictionary starting at "<<"
+// Nested dictionaries, arrays, numbers and references are skipped
+func parsePDFDict(data []byte) map[string]string {
+	result := make(map[string]string)
+	depth := 0
+	key := ""
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			depth++
+			if depth > 1 {
+				key = ""
+			}
+			i += 2
+		case c == '>' && i+1 < len(data) && data[i+1] == '>':
+			depth--
+			i += 2
+			if depth == 0 {
+				return result
+			}
+		case c == '(':
+			raw, n := readPDFLiteral(data[i:])
+			if depth == 1 && key != "" {
+				result[key] = decodePDFText(raw)
+			}
+			key = ""
+			i += n
+		case c == '<':
+			raw, n := readPDFHex(data[i:])
+			if depth == 1 && key != "" {
+				result[key] = decodePDFText(raw)
+			}
+			key = ""
+			i += n
+		case c == '/':
+			j := i + 1
+			for j < len(data) && !isPDFDelimiter(data[j]) {
+				j++
+			}
+			if depth == 1 {
+				if key == "" {
+					key = string(data[i+1 : j])
+				} else {
+					key = "" // Name used as a value
+				}
+			}
+			i = j
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			i++
+		default:
+			// Numbers, references, booleans, arrays: value we do not keep
+			if depth == 1 {
+				key = ""
+			}
+			i++
+		}
+	}
+
+	return result
+}
+
+// isPDFDelimiter reports whether c terminates a PDF name token
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f()<>[]{}/%", c) >= 0
+}
+
+// readPDFLiteral decodes a literal string "(...)" and returns bytes consumed
+func readPDFLiteral(data []byte) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, i + 1
+			}
+			out = append(out, c)
+		case '\\':
+			i++
+			if i >= len(data) {
+				return out, i
+			}
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// Line continuation
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					val := 0
+					k := 0
+					for ; k < 3 && i+k < len(data) && data[i+k] >= '0' && data[i+k] <= '7'; k++ {
+						val = val*8 + int(data[i+k]-'0')
+					}
+					out = append(out, byte(val))
+					i += k - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, len(data)
+}
+
+// readPDFHex decodes a hex string "<...>" and returns bytes consumed
+func readPDFHex(data []byte) ([]byte, int) {
+	end := bytes.IndexByte(data, '>')
+	if end < 0 {
+		return nil, len(data)
+	}
+
+	var digits []byte
+	for _, c := range data[1:end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out, end + 1
+}
+
+// decodePDFText decodes a PDF text string (UTF-16BE with BOM, or PDFDocEncoding)
+func decodePDFText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, (len(raw)-2)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	// Many producers write UTF-8 despite the spec; accept it when valid
+	if utf8.Valid(raw) {
+		return string(raw)
+	}
+
+	// PDFDocEncoding is close enough to Latin-1 for metadata purposes
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// parsePDFDate parses "D:YYYYMMDDHHmmSSOHH'mm'" dates
+func parsePDFDate(value string) time.Time {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	digits := pdfDateDigits.FindString(value)
+	if len(digits) < 4 {
+		return time.Time{}
+	}
+
+	// Missing trailing fields default to their minimum (month/day = 01)
+	const full = "00000101000000"
+	if len(digits) > len(full) {
+		digits = digits[:len(full)]
+	}
+	padded := digits + full[len(digits):]
+
+	loc := time.UTC
+	tz := strings.ReplaceAll(value[len(digits):], "'", "")
+	if len(tz) >= 3 && (tz[0] == '+' || tz[0] == '-') {
+		hours, _ := strconv.Atoi(tz[1:3])
+		minutes := 0
+		if len(tz) >= 5 {
+			minutes, _ = strconv.Atoi(tz[3:5])
+		}
+		offset := hours*3600 + minutes*60
+		if tz[0] == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+
+	t, err := time.ParseInLocation("20060102150405", padded, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// applyXMP fills fields missing from the Info dictionary from an uncompressed XMP packet
+func applyXMP(meta *internal.DocumentMetadata, data []byte) {
+	packet := xmpPacketPattern.Find(data)
+	if packet == nil {
+		return
+	}
+	xmp := string(packet)
+
+	if meta.Title == "" {
+		if items := xmpItems(xmp, "dc:title"); len(items) > 0 {
+			meta.Title = collapseSpace(items[0])
+		}
+	}
+	if meta.Author == "" {
+		meta.Author = strings.Join(xmpItems(xmp, "dc:creator"), ", ")
+	}
+	if meta.Description == "" {
+		if items := xmpItems(xmp, "dc:description"); len(items) > 0 {
+			meta.Description = collapseSpace(items[0])
+		}
+	}
+	if len(meta.Tags) == 0 {
+		meta.Tags = xmpItems(xmp, "dc:subject")
+	}
+	if meta.Language == "" {
+		if items := xmpItems(xmp, "dc:language"); len(items) > 0 {
+			meta.Language = items[0]
+		}
+	}
+	if meta.Created.IsZero() {
+		meta.Created = parseDate(xmpValue(xmp, "xmp:CreateDate"))
+	}
+	if meta.Modified.IsZero() {
+		meta.Modified = parseDate(xmpValue(xmp, "xmp:ModifyDate"))
+	}
+}
+
+// xmpItems returns rdf:li values of an element (Alt/Seq/Bag) or its plain text
+func xmpItems(xmp, element string) []string {
+	pattern := regexp.MustCompile(`(?s)<` + regexp.QuoteMeta(element) + `[^>]*>(.*?)</` + regexp.QuoteMeta(element) + `>`)
+	match := pattern.FindStringSubmatch(xmp)
+	if match == nil {
+		return nil
+	}
+
+	var items []string
+	for _, li := range xmpListItemPattern.FindAllStringSubmatch(match[1], -1) {
+		if v := strings.TrimSpace(html.UnescapeString(li[1])); v != "" {
+			items = append(items, v)
+		}
+	}
+	if len(items) == 0 {
+		if v := strings.TrimSpace(html.UnescapeString(match[1])); v != "" && !strings.Contains(v, "<") {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// xmpValue returns a simple XMP property written as element or attribute
+func xmpValue(xmp, property string) string {
+	if items := xmpItems(xmp, property); len(items) > 0 {
+		return items[0]
+	}
+	attr := regexp.MustCompile(regexp.QuoteMeta(property) + `="([^"]*)"`)
+	if match := attr.FindStringSubmatch(xmp); match != nil {
+		return html.UnescapeString(match[1])
+	}
+	return ""
+}
+
+// pdfPageCount returns the /Count of the root page tree, or the number of page objects
+func pdfPageCount(data []byte) int {
+	maxCount := 0
+	for _, obj := range bytes.Split(data, []byte("endobj")) {
+		if !pdfPagesPattern.Match(obj) {
+			continue
+		}
+		for _, m := range pdfCountPattern.FindAllSubmatch(obj, -1) {
+			if n, err := strconv.Atoi(string(m[1])); err == nil && n > maxCount {
+				maxCount = n
+			}
+		}
+	}
+	if maxCount > 0 {
+		return maxCount
+	}
+	return len(pdfPagePattern.FindAllIndex(data, -1))
+}
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"context"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// Checksum algorithms accepted by metadata.checksum
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumMD5    = "md5"
+)
+
+// formatParser extracts metadata from a single document format
+type formatParser func(ctx context.Context, input string) (*internal.DocumentMetadata, error)
+
+// Parser extracts DocumentMetadata from documents, dispatching on format
+// Implements internal.Parser
+type Parser struct {
+	parsers  map[internal.DocumentFormat]formatParser
+	checksum string // Checksum algorithm (sha256, md5); empty disables checksums
+}
+
+// DjVu tool used for metadata and page count (DjVuLibre)
+const defaultDjvusedPath = "djvused"
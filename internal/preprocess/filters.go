@@ -0,0 +1,253 @@
+package preprocess
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// toGray converts any image to 8-bit grayscale with a zero-origin rectangle
+func toGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Rect, img, bounds.Min, draw.Src)
+	return gray
+}
+
+// medianFilter removes salt-and-pepper noise with a 3x3 median
+// Edge pixels use clamped neighbours
+func medianFilter(src *image.Gray) *image.Gray {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewGray(src.Rect)
+	var window [9]uint8
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				yy := clamp(y+dy, 0, h-1)
+				for dx := -1; dx <= 1; dx++ {
+					xx := clamp(x+dx, 0, w-1)
+					window[n] = src.Pix[yy*src.Stride+xx]
+					n++
+				}
+			}
+			// Insertion sort is fastest for nine values
+			for i := 1; i < len(window); i++ {
+				for j := i; j > 0 && window[j] < window[j-1]; j-- {
+					window[j], window[j-1] = window[j-1], window[j]
+				}
+			}
+			dst.Pix[y*dst.Stride+x] = window[4]
+		}
+	}
+	return dst
+}
+
+// otsuLevel returns the global threshold maximising between-class variance
+func otsuLevel(img *image.Gray) uint8 {
+	var hist [256]int
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w]
+		for _, v := range row {
+			hist[v]++
+		}
+	}
+
+	total := w * h
+	if total == 0 {
+		return 128
+	}
+	sum := 0.0
+	for i, c := range hist {
+		sum += float64(i * c)
+	}
+
+	var sumBackground, best float64
+	weightBackground := 0
+	level := 0
+	for t := 0; t < 256; t++ {
+		weightBackground += hist[t]
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += float64(t * hist[t])
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		diff := meanBackground - meanForeground
+		variance := float64(weightBackground) * float64(weightForeground) * diff * diff
+		if variance > best {
+			best = variance
+			level = t
+		}
+	}
+	return uint8(level)
+}
+
+// applyThreshold maps pixels at or below level to black and the rest to white
+func applyThreshold(src *image.Gray, level uint8) *image.Gray {
+	dst := image.NewGray(src.Rect)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if src.Pix[y*src.Stride+x] > level {
+				dst.Pix[y*dst.Stride+x] = 255
+			}
+		}
+	}
+	return dst
+}
+
+// adaptiveThreshold binarises against the local mean, computed with an integral image
+// The window scales with the page so it spans a few text lines
+func adaptiveThreshold(src *image.Gray) *image.Gray {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	window := w / 32
+	if window < minAdaptiveWindow {
+		window = minAdaptiveWindow
+	}
+	half := window / 2
+
+	// integral[(y+1)*(w+1)+(x+1)] = sum of src over [0,x]x[0,y]
+	stride := w + 1
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var rowSum int64
+		for x := 0; x < w; x++ {
+			rowSum += int64(src.Pix[y*src.Stride+x])
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + rowSum
+		}
+	}
+
+	dst := image.NewGray(src.Rect)
+	for y := 0; y < h; y++ {
+		y0, y1 := clamp(y-half, 0, h-1), clamp(y+half, 0, h-1)
+		for x := 0; x < w; x++ {
+			x0, x1 := clamp(x-half, 0, w-1), clamp(x+half, 0, w-1)
+			count := int64((x1 - x0 + 1) * (y1 - y0 + 1))
+			sum := integral[(y1+1)*stride+x1+1] - integral[y0*stride+x1+1] -
+				integral[(y1+1)*stride+x0] + integral[y0*stride+x0]
+			if int64(src.Pix[y*src.Stride+x])*count > sum-adaptiveOffset*count {
+				dst.Pix[y*dst.Stride+x] = 255
+			}
+		}
+	}
+	return dst
+}
+
+// estimateSkew finds the text line angle in degrees by maximising the sharpness
+// of the horizontal projection profile of ink pixels
+// Positive angles mean lines descend to the right (clockwise skew)
+func estimateSkew(img *image.Gray) float64 {
+	level := otsuLevel(img)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	// Sample ink pixels; large pages are subsampled on a regular grid
+	var xs, ys []float64
+	step := 1
+	for (w/step)*(h/step) > maxSkewSamples*4 {
+		step++
+	}
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			if img.Pix[y*img.Stride+x] <= level {
+				xs = append(xs, float64(x)-cx)
+				ys = append(ys, float64(y)-cy)
+			}
+		}
+	}
+	if len(xs) == 0 || len(xs) == w*h/(step*step) {
+		return 0 // Blank or solid page
+	}
+
+	diagonal := math.Hypot(float64(w), float64(h))
+	bins := make([]int, int(diagonal)+2)
+	score := func(degrees float64) float64 {
+		for i := range bins {
+			bins[i] = 0
+		}
+		sin, cos := math.Sincos(degrees * math.Pi / 180)
+		offset := diagonal / 2
+		for i := range xs {
+			row := int(ys[i]*cos - xs[i]*sin + offset)
+			if row >= 0 && row < len(bins) {
+				bins[row]++
+			}
+		}
+		var s float64
+		for _, c := range bins {
+			s += float64(c) * float64(c)
+		}
+		return s
+	}
+
+	best, bestScore := 0.0, score(0)
+	search := func(from, to, step float64) {
+		for a := from; a <= to+step/2; a += step {
+			if s := score(a); s > bestScore {
+				best, bestScore = a, s
+			}
+		}
+	}
+	search(-maxSkewDegrees, maxSkewDegrees, coarseSkewStep)
+	search(best-coarseSkewStep, best+coarseSkewStep, fineSkewStep)
+
+	return math.Round(best*10) / 10
+}
+
+// rotate undoes a skew of degrees around the image centre with bilinear sampling
+// Uncovered corners are filled with white
+func rotate(src *image.Gray, degrees float64) *image.Gray {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewGray(src.Rect)
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(w-1)/2, float64(h-1)/2
+
+	for y := 0; y < h; y++ {
+		dy := float64(y) - cy
+		for x := 0; x < w; x++ {
+			dx := float64(x) - cx
+			sx := cx + dx*cos - dy*sin
+			sy := cy + dx*sin + dy*cos
+			dst.Pix[y*dst.Stride+x] = sampleBilinear(src, sx, sy)
+		}
+	}
+	return dst
+}
+
+// sampleBilinear interpolates src at a fractional position, white outside the image
+func sampleBilinear(src *image.Gray, x, y float64) uint8 {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if x < 0 || y < 0 || x > float64(w-1) || y > float64(h-1) {
+		return 255
+	}
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	p00 := float64(src.Pix[y0*src.Stride+x0])
+	p10 := float64(src.Pix[y0*src.Stride+x1])
+	p01 := float64(src.Pix[y1*src.Stride+x0])
+	p11 := float64(src.Pix[y1*src.Stride+x1])
+
+	top := p00 + (p10-p00)*fx
+	bottom := p01 + (p11-p01)*fx
+	return uint8(math.Round(top + (bottom-top)*fy))
+}
+
+// clamp limits v to [lo, hi]
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/image/bmp"  // Also registers the BMP decoder
+	"golang.org/x/image/tiff" // Also registers the TIFF decoder (ddjvu output)
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// NewPreprocessor creates a preprocessor from ocr.preprocess settings
+func NewPreprocessor(cfg internal.PreprocessConfig) (*Preprocessor, error) {
+	cfg.Threshold = strings.ToLower(strings.TrimSpace(cfg.Threshold))
+	switch cfg.Threshold {
+	case "", ThresholdNone, "off", "false":
+		cfg.Threshold = ThresholdNone
+	case ThresholdAuto, ThresholdOtsu, ThresholdAdaptive:
+	default:
+		return nil, fmt.Errorf("%w: unknown threshold method %q (use auto, otsu, adaptive or none)",
+			internal.ErrInvalidInput, cfg.Threshold)
+	}
+	return &Preprocessor{config: cfg}, nil
+}
+
+// Enabled reports whether any step beyond grayscale conversion is configured
+func (p *Preprocessor) Enabled() bool {
+	return p.config.Denoise || p.config.Deskew || p.config.Threshold != ThresholdNone
+}
+
+// Process runs the configured steps: grayscale, denoise, deskew, threshold
+func (p *Preprocessor) Process(img image.Image) (*image.Gray, *Report) {
+	gray := toGray(img)
+	report := &Report{
+		Steps:  []string{StepGrayscale},
+		Width:  gray.Rect.Dx(),
+		Height: gray.Rect.Dy(),
+	}
+
+	if p.config.Denoise {
+		gray = medianFilter(gray)
+		report.Steps = append(report.Steps, StepDenoise)
+	}
+
+	if p.config.Deskew {
+		angle := estimateSkew(gray)
+		report.SkewAngle = angle
+		if angle >= minSkewCorrection || angle <= -minSkewCorrection {
+			gray = rotate(gray, angle)
+		}
+		report.Steps = append(report.Steps, StepDeskew)
+	}
+
+	switch p.config.Threshold {
+	case ThresholdAuto, ThresholdOtsu:
+		level := otsuLevel(gray)
+		gray = applyThreshold(gray, level)
+		report.Level = int(level)
+		report.Threshold = p.config.Threshold
+		report.Steps = append(report.Steps, StepThreshold)
+	case ThresholdAdaptive:
+		gray = adaptiveThreshold(gray)
+		report.Threshold = p.config.Threshold
+		report.Steps = append(report.Steps, StepThreshold)
+	}
+
+	return gray, report
+}
+
+// ProcessFile reads an image, processes it and writes the result
+// The output encoding follows its extension (png, jpg, tiff, bmp; PNG otherwise)
+func (p *Preprocessor) ProcessFile(input, output string) (*Report, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", internal.ErrInvalidInput, input)
+	}
+	img, format, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot decode image %s: %v", internal.ErrUnsupportedFormat, input, err)
+	}
+
+	result, report := p.Process(img)
+
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := encode(out, result, filepath.Ext(output)); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("failed to encode %s: %w", output, err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	log.Debug().
+		Str("input", input).
+		Str("decoded_as", format).
+		Str("output", output).
+		Strs("steps", report.Steps).
+		Float64("skew", report.SkewAngle).
+		Msg("Preprocessed image")
+
+	return report, nil
+}
+
+// encode writes img in the format implied by ext
+func encode(w io.Writer, img image.Image, ext string) error {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "jpg", "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+	case "tif", "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	case "bmp":
+		return bmp.Encode(w, img)
+	default:
+		return png.Encode(w, img)
+	}
+}
@@ -0,0 +1,201 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// blankPage returns a white grayscale page
+func blankPage(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// skewedLines draws text-like horizontal bars rotated by degrees (descending to the right)
+func skewedLines(w, h int, degrees float64) *image.Gray {
+	img := blankPage(w, h)
+	slope := math.Tan(degrees * math.Pi / 180)
+	for y0 := 40; y0 < h-40; y0 += 24 {
+		for x := 30; x < w-30; x++ {
+			if (x/12)%5 == 4 {
+				continue // Word gaps
+			}
+			base := float64(y0) + float64(x-w/2)*slope
+			for t := 0; t < 6; t++ {
+				y := int(base) + t
+				if y >= 0 && y < h {
+					img.SetGray(x, y, color.Gray{Y: 20})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestOtsuLevel(t *testing.T) {
+	img := blankPage(100, 100)
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 100; x++ {
+			img.Pix[y*img.Stride+x] = 40
+		}
+	}
+	for y := 50; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			img.Pix[y*img.Stride+x] = 200
+		}
+	}
+
+	level := otsuLevel(img)
+	if level < 40 || level >= 200 {
+		t.Fatalf("otsu level = %d, want between the two modes", level)
+	}
+
+	binary := applyThreshold(img, level)
+	if binary.GrayAt(0, 0).Y != 0 || binary.GrayAt(0, 99).Y != 255 {
+		t.Errorf("threshold did not separate modes: %d %d", binary.GrayAt(0, 0).Y, binary.GrayAt(0, 99).Y)
+	}
+}
+
+func TestMedianFilterRemovesSaltNoise(t *testing.T) {
+	img := blankPage(20, 20)
+	img.SetGray(5, 5, color.Gray{Y: 0})
+	img.SetGray(12, 7, color.Gray{Y: 0})
+
+	clean := medianFilter(img)
+	for i, v := range clean.Pix {
+		if v != 255 {
+			t.Fatalf("pixel %d = %d after median, want 255", i, v)
+		}
+	}
+}
+
+func TestAdaptiveThresholdUnevenLighting(t *testing.T) {
+	// Background brightens left to right; dark text must survive on both sides
+	img := image.NewGray(image.Rect(0, 0, 200, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 200; x++ {
+			img.Pix[y*img.Stride+x] = uint8(90 + x/2)
+		}
+	}
+	for x := 10; x < 190; x++ {
+		for y := 28; y < 32; y++ {
+			img.Pix[y*img.Stride+x] = uint8(40 + x/2)
+		}
+	}
+
+	binary := adaptiveThreshold(img)
+	if binary.GrayAt(20, 30).Y != 0 || binary.GrayAt(180, 30).Y != 0 {
+		t.Error("text not kept black on both sides")
+	}
+	if binary.GrayAt(20, 5).Y != 255 || binary.GrayAt(180, 5).Y != 255 {
+		t.Error("background not white on both sides")
+	}
+}
+
+func TestDeskew(t *testing.T) {
+	img := skewedLines(600, 400, 3)
+
+	angle := estimateSkew(img)
+	if math.Abs(angle-3) > 0.3 {
+		t.Fatalf("estimated skew = %.1f, want ~3", angle)
+	}
+
+	straight := rotate(img, angle)
+	if residual := estimateSkew(straight); math.Abs(residual) > 0.3 {
+		t.Errorf("residual skew after rotation = %.1f, want ~0", residual)
+	}
+
+	if angle := estimateSkew(skewedLines(600, 400, -2)); math.Abs(angle+2) > 0.3 {
+		t.Errorf("estimated skew = %.1f, want ~-2", angle)
+	}
+}
+
+func TestNewPreprocessorThreshold(t *testing.T) {
+	for _, method := range []string{"", "none", "auto", "Otsu", "adaptive"} {
+		if _, err := NewPreprocessor(internal.PreprocessConfig{Threshold: method}); err != nil {
+			t.Errorf("threshold %q rejected: %v", method, err)
+		}
+	}
+	if _, err := NewPreprocessor(internal.PreprocessConfig{Threshold: "manual"}); err == nil {
+		t.Error("expected error for unknown threshold method")
+	}
+
+	p, _ := NewPreprocessor(internal.PreprocessConfig{})
+	if p.Enabled() {
+		t.Error("empty config should leave preprocessing disabled")
+	}
+}
+
+func TestProcessFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "scan.png")
+	output := filepath.Join(dir, "clean.png")
+
+	src := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	gray := skewedLines(600, 400, 2)
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			v := gray.GrayAt(x, y).Y
+			src.Set(x, y, color.RGBA{R: v, G: v / 2, B: v, A: 255})
+		}
+	}
+	f, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err := NewPreprocessor(internal.PreprocessConfig{Denoise: true, Deskew: true, Threshold: ThresholdAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := p.ProcessFile(input, output)
+	if err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+
+	want := []string{StepGrayscale, StepDenoise, StepDeskew, StepThreshold}
+	if len(report.Steps) != len(want) {
+		t.Fatalf("steps = %v, want %v", report.Steps, want)
+	}
+	if math.Abs(report.SkewAngle-2) > 0.3 {
+		t.Errorf("skew = %.1f, want ~2", report.SkewAngle)
+	}
+
+	f, err = os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("output is not a PNG: %v", err)
+	}
+	if result.Bounds().Dx() != 600 || result.Bounds().Dy() != 400 {
+		t.Errorf("output size = %v", result.Bounds())
+	}
+	for y := 0; y < 400; y += 7 {
+		for x := 0; x < 600; x += 7 {
+			if v := color.GrayModel.Convert(result.At(x, y)).(color.Gray).Y; v != 0 && v != 255 {
+				t.Fatalf("pixel (%d,%d) = %d, want binary output", x, y, v)
+			}
+		}
+	}
+
+	if _, err := p.ProcessFile(filepath.Join(dir, "missing.png"), output); err == nil {
+		t.Error("expected error for missing input")
+	}
+}
@@ -0,0 +1,47 @@
+package preprocess
+
+import (
+	"github.com/valpere/yakateka/internal"
+)
+
+// Threshold methods accepted in ocr.preprocess.threshold
+const (
+	ThresholdNone     = "none"     // Keep grayscale
+	ThresholdAuto     = "auto"     // Otsu's global threshold
+	ThresholdOtsu     = "otsu"     // Same as auto
+	ThresholdAdaptive = "adaptive" // Local mean threshold, for uneven lighting
+)
+
+// Preprocessing step names reported in Report.Steps
+const (
+	StepGrayscale = "grayscale"
+	StepDenoise   = "denoise"
+	StepDeskew    = "deskew"
+	StepThreshold = "threshold"
+)
+
+const (
+	maxSkewDegrees    = 15.0 // Largest skew searched for
+	coarseSkewStep    = 1.0  // First pass resolution (degrees)
+	fineSkewStep      = 0.1  // Second pass resolution around the coarse best
+	minSkewCorrection = 0.1  // Smaller angles are left alone
+	maxSkewSamples    = 200000
+	adaptiveOffset    = 10 // Pixels this much darker than their neighbourhood become ink
+	minAdaptiveWindow = 15
+)
+
+// Preprocessor cleans up page images before OCR
+// Grayscale conversion always runs; denoise, deskew and threshold follow PreprocessConfig
+type Preprocessor struct {
+	config internal.PreprocessConfig
+}
+
+// Report describes what Process did to an image
+type Report struct {
+	Steps     []string `json:"steps" yaml:"steps"`
+	SkewAngle float64  `json:"skew_angle" yaml:"skew_angle"`                   // Detected skew in degrees (positive = clockwise)
+	Threshold string   `json:"threshold,omitempty" yaml:"threshold,omitempty"` // Method used
+	Level     int      `json:"level,omitempty" yaml:"level,omitempty"`         // Global threshold level (Otsu)
+	Width     int      `json:"width" yaml:"width"`
+	Height    int      `json:"height" yaml:"height"`
+}
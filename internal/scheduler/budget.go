@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// WithBudget returns a context that expires with context.DeadlineExceeded once
+// tasks under it have run for timeout, not counting time spent waiting for a
+// Limiter slot: a file queued behind a capped converter keeps its whole timeout
+// for the conversion itself
+func WithBudget(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	b := &budget{Context: parent, done: make(chan struct{}), remaining: timeout}
+	b.stopParent = context.AfterFunc(parent, func() { b.finish(parent.Err()) })
+	b.mu.Lock()
+	b.start()
+	b.mu.Unlock()
+	return b, func() { b.finish(context.Canceled) }
+}
+
+func (b *budget) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *budget) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *budget) Value(key any) any {
+	if key == (budgetKey{}) {
+		return b
+	}
+	return b.Context.Value(key)
+}
+
+// start runs the clock; b.mu must be held
+func (b *budget) start() {
+	b.started = time.Now()
+	b.timer = time.AfterFunc(b.remaining, func() { b.finish(context.DeadlineExceeded) })
+}
+
+// pause stops the clock for a Limiter wait
+func (b *budget) pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.waiting++
+	if b.timer != nil && b.timer.Stop() {
+		b.remaining -= time.Since(b.started)
+		b.timer = nil
+	}
+}
+
+// resume restarts the clock once no Limiter wait is in progress
+func (b *budget) resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.waiting--
+	if b.waiting == 0 && b.err == nil && b.timer == nil {
+		b.start()
+	}
+}
+
+// finish ends the context with err, unless it already ended
+func (b *budget) finish(err error) {
+	b.mu.Lock()
+	if b.err != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.err = err
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	close(b.done)
+	b.mu.Unlock()
+	b.stopParent()
+}
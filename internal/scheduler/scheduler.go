@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NewPool creates a pool running at most jobs tasks at once
+// jobs <= 0 means one task per CPU
+func NewPool(jobs int) *Pool {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	return &Pool{jobs: jobs}
+}
+
+// Jobs returns the concurrency of the pool
+func (p *Pool) Jobs() int {
+	return p.jobs
+}
+
+// Run calls task for every index in [0, n) with at most Jobs() calls in flight
+// Once ctx is cancelled no new tasks are started; tasks already running receive
+// the cancelled ctx and are expected to return promptly. Run returns when all
+// started tasks have finished, together with the number of tasks that were started.
+func (p *Pool) Run(ctx context.Context, n int, task func(ctx context.Context, i int)) int {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := p.jobs
+	if workers > n {
+		workers = n
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				task(ctx, i)
+			}
+		}()
+	}
+
+	started := 0
+	for i := 0; i < n; i++ {
+		// Check first so a cancelled context always wins over a ready worker
+		if ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case indexes <- i:
+				started++
+				continue
+			}
+		}
+		log.Warn().
+			Err(ctx.Err()).
+			Int("started", started).
+			Int("total", n).
+			Msg("Scheduler cancelled, not starting remaining tasks")
+		break
+	}
+	close(indexes)
+	wg.Wait()
+
+	return started
+}
+
+// NewLimiter creates a limiter with the given per-key limits
+// Non-positive limits are ignored (unlimited)
+func NewLimiter(limits map[string]int) *Limiter {
+	l := &Limiter{sems: make(map[string]chan struct{})}
+	for key, limit := range limits {
+		l.SetLimit(key, limit)
+	}
+	return l
+}
+
+// SetLimit sets the maximum concurrent holders for key
+// Must be called before the key is in use; limit <= 0 removes the cap
+func (l *Limiter) SetLimit(key string, limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 {
+		delete(l.sems, key)
+		return
+	}
+	l.sems[key] = make(chan struct{}, limit)
+
+	log.Debug().
+		Str("key", key).
+		Int("limit", limit).
+		Msg("Set concurrency limit")
+}
+
+// Acquire blocks until a slot for key is available or ctx is done
+// The returned release function must be called exactly once on success
+// The wait does not count against the timeout of a WithBudget context
+func (l *Limiter) Acquire(ctx context.Context, key string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	sem, ok := l.sems[key]
+	l.mu.Unlock()
+
+	if !ok {
+		return func() {}, nil
+	}
+
+	if b, ok := ctx.Value(budgetKey{}).(*budget); ok {
+		b.pause()
+		defer b.resume()
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolRunsAllTasks(t *testing.T) {
+	pool := NewPool(4)
+
+	var count int32
+	started := pool.Run(context.Background(), 20, func(ctx context.Context, i int) {
+		atomic.AddInt32(&count, 1)
+	})
+
+	if started != 20 {
+		t.Errorf("Expected 20 started tasks, got %d", started)
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 executed tasks, got %d", count)
+	}
+}
+
+func TestPoolRespectsJobs(t *testing.T) {
+	pool := NewPool(2)
+
+	var inFlight, maxInFlight int32
+	pool.Run(context.Background(), 10, func(ctx context.Context, i int) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	})
+
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 tasks in flight, got %d", maxInFlight)
+	}
+}
+
+func TestPoolStopsOnCancel(t *testing.T) {
+	pool := NewPool(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := pool.Run(ctx, 10, func(ctx context.Context, i int) {
+		if i == 2 {
+			cancel()
+		}
+	})
+
+	if started >= 10 {
+		t.Errorf("Expected cancellation to stop dispatch, started %d", started)
+	}
+}
+
+func TestNewPoolDefaultsToCPUs(t *testing.T) {
+	if NewPool(0).Jobs() < 1 {
+		t.Error("Expected at least one job for default pool")
+	}
+}
+
+func TestLimiterCapsConcurrency(t *testing.T) {
+	limiter := NewLimiter(map[string]int{"soffice": 1})
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.Background(), "soffice")
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&inFlight, 1)
+			if n > atomic.LoadInt32(&maxInFlight) {
+				atomic.StoreInt32(&maxInFlight, n)
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 1 {
+		t.Errorf("Expected exactly 1 holder at a time, got %d", maxInFlight)
+	}
+}
+
+func TestLimiterUnlimitedKey(t *testing.T) {
+	limiter := NewLimiter(map[string]int{"soffice": 1})
+
+	release1, err := limiter.Acquire(context.Background(), "pandoc")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	release2, err := limiter.Acquire(context.Background(), "pandoc")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	release1()
+	release2()
+}
+
+func TestLimiterAcquireCancelled(t *testing.T) {
+	limiter := NewLimiter(map[string]int{"calibre": 1})
+
+	release, err := limiter.Acquire(context.Background(), "calibre")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := limiter.Acquire(ctx, "calibre"); err == nil {
+		t.Error("Expected error when context expires while waiting")
+	}
+}
+
+func TestBudgetExpires(t *testing.T) {
+	ctx, cancel := WithBudget(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the budget to run out")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", ctx.Err())
+	}
+}
+
+func TestBudgetFollowsParent(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := WithBudget(parent, time.Minute)
+	defer cancel()
+
+	cancelParent()
+	<-ctx.Done()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", ctx.Err())
+	}
+}
+
+func TestBudgetExcludesLimiterWait(t *testing.T) {
+	limiter := NewLimiter(map[string]int{"tool": 1})
+
+	// Holding the slot for 30ms each, the last task waits 60ms under a 50ms budget
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := WithBudget(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			release, err := limiter.Acquire(ctx, "tool")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			time.Sleep(30 * time.Millisecond)
+			release()
+			errs[i] = ctx.Err()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Task %d: expected the wait not to count, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pool runs independent tasks with a bounded number in flight
+type Pool struct {
+	jobs int // Maximum concurrent tasks (>= 1)
+}
+
+// Limiter caps concurrent use of named resources (converters, helpers)
+// Keys without a configured limit are unlimited
+type Limiter struct {
+	mu   sync.Mutex
+	sems map[string]chan struct{} // key -> counting semaphore
+}
+
+// budget is a context that times out once it has run for its timeout, with
+// the clock stopped while a task under it waits for a Limiter slot
+type budget struct {
+	context.Context // Parent
+
+	done       chan struct{}
+	stopParent func() bool // Stops forwarding the parent's cancellation
+
+	mu        sync.Mutex
+	err       error
+	remaining time.Duration // Time left when the clock last started
+	started   time.Time     // When the clock last started
+	timer     *time.Timer   // Nil while the clock is stopped
+	waiting   int           // Limiter waits in progress
+}
+
+// budgetKey finds the budget of a context
+type budgetKey struct{}
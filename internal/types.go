@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"errors"
+	"io"
 	"time"
 )
 
@@ -12,6 +13,9 @@ var (
 	ErrUnsupportedFormat     = errors.New("unsupported format")
 	ErrInvalidInput          = errors.New("invalid input")
 	ErrConversionFailed      = errors.New("conversion failed")
+	ErrNoTextExtracted       = errors.New("no text extracted")
+	ErrOutputExists          = errors.New("output already exists")
+	ErrInvalidOutput         = errors.New("invalid output")
 )
 
 // DocumentFormat represents a document format type
@@ -61,6 +65,7 @@ type OCRConfig struct {
 	Engine     string           `mapstructure:"engine"`
 	Languages  []string         `mapstructure:"languages"`
 	DPI        int              `mapstructure:"dpi"`
+	Fallback   bool             `mapstructure:"fallback"` // OCR text targets that come out empty
 	Preprocess PreprocessConfig `mapstructure:"preprocess"`
 }
 
@@ -102,6 +107,7 @@ type MetadataConfig struct {
 	Checksum string `mapstructure:"checksum"`
 	Sidecar  bool   `mapstructure:"sidecar"`
 	Embed    bool   `mapstructure:"embed"`
+	Preserve bool   `mapstructure:"preserve"` // Carry source metadata over to converted files
 }
 
 // OutputConfig represents output formatting configuration
@@ -118,29 +124,32 @@ type LoggingConfig struct {
 
 // DocumentMetadata represents metadata extracted from or to be written to a document
 type DocumentMetadata struct {
-	Title       string            `json:"title,omitempty"`
-	Author      string            `json:"author,omitempty"`
-	Language    string            `json:"language,omitempty"`
-	Category    string            `json:"category,omitempty"`
-	Tags        []string          `json:"tags,omitempty"`
-	Description string            `json:"description,omitempty"`
-	Created     time.Time         `json:"created,omitempty"`
-	Modified    time.Time         `json:"modified,omitempty"`
-	PageCount   int               `json:"page_count,omitempty"`
-	Checksum    string            `json:"checksum,omitempty"`
-	Custom      map[string]string `json:"custom,omitempty"`
+	Title       string            `json:"title,omitempty" yaml:"title,omitempty"`
+	Author      string            `json:"author,omitempty" yaml:"author,omitempty"`
+	Language    string            `json:"language,omitempty" yaml:"language,omitempty"`
+	Category    string            `json:"category,omitempty" yaml:"category,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Created     time.Time         `json:"created,omitzero" yaml:"created,omitempty"`
+	Modified    time.Time         `json:"modified,omitzero" yaml:"modified,omitempty"`
+	PageCount   int               `json:"page_count,omitempty" yaml:"page_count,omitempty"`
+	Checksum    string            `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	Custom      map[string]string `json:"custom,omitempty" yaml:"custom,omitempty"`
 }
 
 // ConversionOptions represents options for document conversion
 type ConversionOptions struct {
-	InputFormat  DocumentFormat    `json:"input_format"`
-	OutputFormat DocumentFormat    `json:"output_format"`
-	Quality      string            `json:"quality,omitempty"`
-	DPI          int               `json:"dpi,omitempty"`
-	OCR          bool              `json:"ocr,omitempty"`
-	OCRLanguages []string          `json:"ocr_languages,omitempty"`
-	Via          string            `json:"via,omitempty"` // Converter to use (pandoc, libreoffice, etc.)
-	Extra        map[string]string `json:"extra,omitempty"`
+	InputFormat      DocumentFormat    `json:"input_format"`
+	OutputFormat     DocumentFormat    `json:"output_format"`
+	Quality          string            `json:"quality,omitempty"`
+	DPI              int               `json:"dpi,omitempty"`
+	OCR              bool              `json:"ocr,omitempty"`
+	OCRLanguages     []string          `json:"ocr_languages,omitempty"`
+	OCRFallback      bool              `json:"ocr_fallback,omitempty"` // Retry empty txt/md results with OCR
+	Via              string            `json:"via,omitempty"` // Converter to use (pandoc, libreoffice, etc.)
+	PreserveMetadata bool              `json:"preserve_metadata,omitempty"`
+	Pages            string            `json:"pages,omitempty"` // Page range, e.g. 1-5,8 (helpers with protocol v2)
+	Extra            map[string]string `json:"extra,omitempty"`
 }
 
 // ExtractionOptions represents options for content extraction
@@ -172,6 +181,38 @@ type Converter interface {
 	SupportedOutputFormats() []DocumentFormat
 }
 
+// ConversionCapability is one conversion a converter performs, rated for a mode
+type ConversionCapability struct {
+	From    DocumentFormat
+	To      DocumentFormat
+	Speed   float64 // Relative speed (> 0, higher = faster)
+	Quality float64 // Relative quality (> 0, higher = better)
+}
+
+// CapabilityProvider is implemented by converters that support specific format
+// pairs rather than every input × output combination (e.g. helpers)
+type CapabilityProvider interface {
+	// Capabilities returns supported conversions rated for mode (fast, normal, quality)
+	Capabilities(mode string) []ConversionCapability
+}
+
+// Fingerprinter is implemented by converters that can identify the tools (and
+// their versions) that would perform a conversion, so cached outputs are not
+// reused after an upgrade
+type Fingerprinter interface {
+	Fingerprint(from, to DocumentFormat, mode string) string
+}
+
+// StreamConverter is implemented by converters that can read the source from a
+// reader and write the result to a writer, without temp files
+type StreamConverter interface {
+	// CanStream reports whether a conversion can run on streams
+	CanStream(from, to DocumentFormat) bool
+
+	// ConvertStream converts the document read from r, writing the result to w
+	ConvertStream(ctx context.Context, r io.Reader, w io.Writer, opts ConversionOptions) error
+}
+
 // Parser is the interface for document parsers
 type Parser interface {
 	// Parse extracts structure and metadata from a document
@@ -0,0 +1,43 @@
+package validate
+
+import (
+	"github.com/valpere/yakateka/internal"
+)
+
+// Limits on what is read to validate an output
+const (
+	headerSize  = 1024     // PDF and PostScript headers may follow a little leading junk
+	trailerSize = 1024     // PDF startxref and %%EOF sit in the last kilobyte
+	maxPartSize = 64 << 20 // Largest container part parsed as XML
+)
+
+// checker validates the content of a file that claims to be one format
+type checker func(path string) error
+
+// checkers by format; other formats only need a non-empty output
+var checkers = map[internal.DocumentFormat]checker{
+	internal.FormatPDF:   checkPDF,
+	internal.FormatPS:    checkPS,
+	internal.FormatEPUB:  checkEPUB,
+	internal.FormatDOCX:  checkDOCX,
+	internal.FormatODT:   checkODT,
+	internal.FormatFB2:   checkFB2,
+	internal.FormatHTML:  checkHTML,
+	internal.FormatJSON:  checkJSON,
+	internal.FormatTXT:   checkText,
+	internal.FormatMD:    checkText,
+	internal.FormatCSV:   checkText,
+	internal.FormatRST:   checkText,
+	internal.FormatLaTeX: checkText,
+	internal.FormatYAML:  checkText,
+}
+
+// textFormats may legitimately be empty: a document without a text layer
+// extracts to nothing, which the OCR fallback deals with
+var textFormats = map[internal.DocumentFormat]bool{
+	internal.FormatTXT: true,
+	internal.FormatMD:  true,
+}
+
+// odtMimetype is the stored mimetype entry of an ODF text document
+const odtMimetype = "application/vnd.oasis.opendocument.text"
@@ -0,0 +1,386 @@
+package validate
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// xrefStream matches the object header of a PDF 1.5 cross-reference stream
+var xrefStream = regexp.MustCompile(`^\d+\s+\d+\s+obj\b`)
+
+// Output checks that a converted file parses as the format it was converted to
+// The error wraps internal.ErrInvalidOutput and names the problem. Formats
+// without a structural check only need to be non-empty
+func Output(path string, format internal.DocumentFormat) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: no %s file was written", internal.ErrInvalidOutput, format)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrInvalidOutput, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s output is a directory", internal.ErrInvalidOutput, format)
+	}
+	if info.Size() == 0 {
+		if textFormats[format] {
+			return nil
+		}
+		return fmt.Errorf("%w: empty %s file", internal.ErrInvalidOutput, format)
+	}
+
+	check, ok := checkers[format]
+	if !ok {
+		return nil
+	}
+	if err := check(path); err != nil {
+		return fmt.Errorf("%w: %s: %v", internal.ErrInvalidOutput, format, err)
+	}
+	return nil
+}
+
+// checkPDF requires a %PDF- header and a trailer whose startxref points at a
+// cross-reference table or stream, which truncated files lack
+func checkPDF(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	head, err := readAt(file, 0, headerSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return errors.New("missing %PDF- header")
+	}
+
+	tail, err := readAt(file, max(0, info.Size()-trailerSize), trailerSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return errors.New("missing %%EOF marker, the file is probably truncated")
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return errors.New("no startxref in the trailer")
+	}
+	fields := bytes.Fields(tail[i+len("startxref"):])
+	if len(fields) == 0 {
+		return errors.New("startxref has no offset")
+	}
+	offset, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil || offset <= 0 || offset >= info.Size() {
+		return fmt.Errorf("startxref offset %q is outside the file", fields[0])
+	}
+
+	xref, err := readAt(file, offset, 64)
+	if err != nil {
+		return err
+	}
+	xref = bytes.TrimLeft(xref, " \t\r\n")
+	if !bytes.HasPrefix(xref, []byte("xref")) && !xrefStream.Match(xref) {
+		return fmt.Errorf("startxref offset %d does not point at a cross-reference table", offset)
+	}
+	return nil
+}
+
+// checkPS requires a PostScript header, plain (%!) or that of a binary EPS
+func checkPS(path string) error {
+	head, err := readHead(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(head, []byte("%!")) && !bytes.HasPrefix(head, []byte{0xC5, 0xD0, 0xD3, 0xC6}) {
+		return errors.New("missing %! header")
+	}
+	return nil
+}
+
+// checkEPUB follows META-INF/container.xml to the package document and parses it
+func checkEPUB(path string) error {
+	r, err := openZip(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	entry := findEntry(&r.Reader, "META-INF/container.xml")
+	if entry == nil {
+		return errors.New("missing META-INF/container.xml")
+	}
+	data, err := readEntry(entry)
+	if err != nil {
+		return err
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return fmt.Errorf("META-INF/container.xml: %v", err)
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return errors.New("META-INF/container.xml names no package document")
+	}
+
+	opf := container.Rootfiles[0].FullPath
+	entry = findEntry(&r.Reader, opf)
+	if entry == nil {
+		return fmt.Errorf("package document %s is missing", opf)
+	}
+	return checkXMLEntry(entry, "package")
+}
+
+// checkDOCX requires the OOXML content types and a well-formed main document part
+func checkDOCX(path string) error {
+	r, err := openZip(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, part := range []struct{ name, root string }{
+		{"[Content_Types].xml", "Types"},
+		{"word/document.xml", "document"},
+	} {
+		entry := findEntry(&r.Reader, part.name)
+		if entry == nil {
+			return fmt.Errorf("missing %s", part.name)
+		}
+		if err := checkXMLEntry(entry, part.root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkODT requires the ODF text mimetype and a well-formed content.xml
+func checkODT(path string) error {
+	r, err := openZip(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	entry := findEntry(&r.Reader, "mimetype")
+	if entry == nil {
+		return errors.New("missing mimetype entry")
+	}
+	data, err := readEntry(entry)
+	if err != nil {
+		return err
+	}
+	if mimetype := strings.TrimSpace(string(data)); mimetype != odtMimetype {
+		return fmt.Errorf("mimetype is %q, not %s", mimetype, odtMimetype)
+	}
+
+	entry = findEntry(&r.Reader, "content.xml")
+	if entry == nil {
+		return errors.New("missing content.xml")
+	}
+	return checkXMLEntry(entry, "document-content")
+}
+
+// checkFB2 requires well-formed XML with a FictionBook root element
+func checkFB2(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return checkXML(file, "FictionBook")
+}
+
+// checkHTML requires markup: HTML is parsed as leniently as browsers do, so
+// what is left to catch is binary or plain-text output
+func checkHTML(path string) error {
+	head, err := readHead(path)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return errors.New("binary content, not markup")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	z := html.NewTokenizer(file)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return errors.New("no HTML elements found")
+			}
+			return z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			return nil
+		}
+	}
+}
+
+// checkJSON requires a single well-formed JSON value
+func checkJSON(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("trailing data after the JSON value")
+	}
+	return nil
+}
+
+// checkText requires valid UTF-8 without NUL bytes
+func checkText(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var offset int64
+	for {
+		c, size, err := r.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 at byte %d", offset)
+		}
+		if c == 0 {
+			return fmt.Errorf("NUL byte at %d, not text", offset)
+		}
+		offset += int64(size)
+	}
+}
+
+// checkXML requires a well-formed XML document whose root element has the given local name
+func checkXML(r io.Reader, root string) error {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	found := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok && found == "" {
+			found = start.Name.Local
+		}
+	}
+	switch found {
+	case root:
+		return nil
+	case "":
+		return errors.New("no root element")
+	default:
+		return fmt.Errorf("root element is <%s>, not <%s>", found, root)
+	}
+}
+
+// checkXMLEntry parses a ZIP entry with checkXML
+func checkXMLEntry(entry *zip.File, root string) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %v", entry.Name, err)
+	}
+	defer rc.Close()
+	if err := checkXML(io.LimitReader(rc, maxPartSize), root); err != nil {
+		return fmt.Errorf("%s: %v", entry.Name, err)
+	}
+	return nil
+}
+
+// openZip opens a ZIP container, reporting a broken one as such
+func openZip(path string) (*zip.ReadCloser, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("not a readable ZIP container: %v", err)
+	}
+	return r, nil
+}
+
+// findEntry returns the named ZIP entry, or nil
+func findEntry(r *zip.Reader, name string) *zip.File {
+	for _, file := range r.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// readEntry reads a ZIP entry of at most maxPartSize bytes
+func readEntry(entry *zip.File) ([]byte, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", entry.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartSize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", entry.Name, err)
+	}
+	return data, nil
+}
+
+// readHead reads the first headerSize bytes of a file
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readAt(file, 0, headerSize)
+}
+
+// readAt reads up to n bytes at offset; fewer are returned at the end of the file
+func readAt(file *os.File, offset int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valpere/yakateka/internal"
+)
+
+// zipContent builds a ZIP archive with the given entries in order
+func zipContent(t *testing.T, entries [][2]string) string {
+	t.Helper()
+	var b strings.Builder
+	w := zip.NewWriter(&b)
+	for _, e := range entries {
+		fw, err := w.Create(e[0])
+		if err != nil {
+			t.Fatalf("Failed to add zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(e[1])); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return b.String()
+}
+
+// pdfContent builds a one-object PDF whose startxref points at its xref table
+func pdfContent() string {
+	body := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"
+	xref := len(body)
+	return body + "xref\n0 2\n0000000000 65535 f \n0000000009 00000 n \n" +
+		"trailer\n<< /Size 2 /Root 1 0 R >>\n" + fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xref)
+}
+
+func TestOutput(t *testing.T) {
+	pdf := pdfContent()
+	container := `<?xml version="1.0"?><container xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`
+	opf := `<?xml version="1.0"?><package xmlns="http://www.idpf.org/2007/opf" version="3.0"><metadata/></package>`
+
+	tests := []struct {
+		name    string
+		format  internal.DocumentFormat
+		content string
+		reason  string // Expected in the error; empty when the output is valid
+	}{
+		{"pdf", internal.FormatPDF, pdf, ""},
+		{"truncated pdf", internal.FormatPDF, pdf[:len(pdf)-30], "%%EOF"},
+		{"pdf with a stale startxref", internal.FormatPDF, strings.Replace(pdf, "startxref\n4", "startxref\n1", 1), "cross-reference"},
+		{"not a pdf", internal.FormatPDF, "<html></html>", "%PDF-"},
+		{"empty pdf", internal.FormatPDF, "", "empty pdf"},
+		{"postscript", internal.FormatPS, "%!PS-Adobe-3.0\nshowpage\n", ""},
+		{"epub", internal.FormatEPUB, zipContent(t, [][2]string{{"mimetype", "application/epub+zip"}, {"META-INF/container.xml", container}, {"OEBPS/content.opf", opf}}), ""},
+		{"epub without its package document", internal.FormatEPUB, zipContent(t, [][2]string{{"META-INF/container.xml", container}}), "OEBPS/content.opf is missing"},
+		{"epub without container.xml", internal.FormatEPUB, zipContent(t, [][2]string{{"OEBPS/content.opf", opf}}), "container.xml"},
+		{"epub that is not a zip", internal.FormatEPUB, "PK\x03\x04broken", "ZIP"},
+		{"docx", internal.FormatDOCX, zipContent(t, [][2]string{{"[Content_Types].xml", "<Types/>"}, {"word/document.xml", "<w:document xmlns:w=\"w\"><w:body/></w:document>"}}), ""},
+		{"docx with a broken document part", internal.FormatDOCX, zipContent(t, [][2]string{{"[Content_Types].xml", "<Types/>"}, {"word/document.xml", "<w:document xmlns:w=\"w\"><w:body>"}}), "word/document.xml"},
+		{"odt", internal.FormatODT, zipContent(t, [][2]string{{"mimetype", "application/vnd.oasis.opendocument.text"}, {"content.xml", "<office:document-content xmlns:office=\"o\"/>"}}), ""},
+		{"odt with the wrong mimetype", internal.FormatODT, zipContent(t, [][2]string{{"mimetype", "application/vnd.oasis.opendocument.spreadsheet"}, {"content.xml", "<office:document-content xmlns:office=\"o\"/>"}}), "mimetype"},
+		{"fb2", internal.FormatFB2, `<?xml version="1.0" encoding="windows-1251"?><FictionBook><body><p>` + "\xcf\xf0\xe8\xe2\xb3\xf2" + `</p></body></FictionBook>`, ""},
+		{"unclosed fb2", internal.FormatFB2, `<FictionBook><body><p>text</body></FictionBook>`, "fb2"},
+		{"html", internal.FormatHTML, "<!DOCTYPE html><p>Loose <b>markup", ""},
+		{"html without markup", internal.FormatHTML, "just text", "no HTML elements"},
+		{"binary html", internal.FormatHTML, "%PDF-1.4\x00\x01<<", "binary"},
+		{"json", internal.FormatJSON, `{"title": "Book"}`, ""},
+		{"json with trailing data", internal.FormatJSON, `{"title": "Book"} }`, "trailing"},
+		{"text", internal.FormatTXT, "Привіт, світ!\n", ""},
+		{"empty text", internal.FormatTXT, "", ""},
+		{"latin-1 text", internal.FormatTXT, "caf\xe9", "invalid UTF-8 at byte 3"},
+		{"cp1251 markdown", internal.FormatMD, "# \xcf\xf0\xe8\xe2\xb3\xf2\n", "invalid UTF-8 at byte 2"},
+		{"text with NUL bytes", internal.FormatTXT, "ab\x00cd", "NUL byte at 2"},
+		{"unchecked format", internal.FormatMOBI, "BOOKMOBI", ""},
+		{"empty unchecked format", internal.FormatMOBI, "", "empty mobi"},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("%d.%s", i, tt.format))
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := Output(path, tt.format)
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("Expected a valid output, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, internal.ErrInvalidOutput) || !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("Expected an invalid output mentioning %q, got %v", tt.reason, err)
+			}
+		})
+	}
+}
+
+func TestOutputMissing(t *testing.T) {
+	err := Output(filepath.Join(t.TempDir(), "missing.pdf"), internal.FormatPDF)
+	if !errors.Is(err, internal.ErrInvalidOutput) {
+		t.Errorf("Expected a missing output to be invalid, got %v", err)
+	}
+}